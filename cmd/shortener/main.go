@@ -10,12 +10,20 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/accounts"
+	"github.com/zauremazhikovayandex/url/internal/analytics"
+	"github.com/zauremazhikovayandex/url/internal/apikeys"
 	"github.com/zauremazhikovayandex/url/internal/app"
+	"github.com/zauremazhikovayandex/url/internal/auth"
 	"github.com/zauremazhikovayandex/url/internal/config"
 	"github.com/zauremazhikovayandex/url/internal/db/postgres"
-	"github.com/zauremazhikovayandex/url/internal/db/storage"
+	"github.com/zauremazhikovayandex/url/internal/deletion"
+	"github.com/zauremazhikovayandex/url/internal/grpcapi"
+	"github.com/zauremazhikovayandex/url/internal/links"
 	"github.com/zauremazhikovayandex/url/internal/logger"
 	"github.com/zauremazhikovayandex/url/internal/services"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"github.com/zauremazhikovayandex/url/internal/tracing"
 	"log"
 	"math/big"
 	"net"
@@ -23,6 +31,7 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -34,6 +43,13 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		panic(err)
 	}
@@ -101,6 +117,30 @@ func makeSelfSignedTLSConfig() (*tls.Config, error) {
 	}, nil
 }
 
+// newIDGenerator создает генератор коротких id по стратегии из конфигурации.
+// Счетчик берется из последовательности PostgreSQL, а для остальных хранилищ —
+// из памяти с затравкой от текущего времени, чтобы после перезапуска не
+// повторять уже выданные значения (редкие совпадения снимаются повтором).
+func newIDGenerator() (links.IDGenerator, error) {
+	cfg := config.AppConfig
+	var counter links.CounterSource = links.NewAtomicCounter(uint64(time.Now().UnixMilli()))
+	if cfg.StorageType == "DB" {
+		counter = links.CounterFunc(postgres.NextSequenceID)
+	}
+
+	switch cfg.IDStrategy {
+	case links.StrategyRandom:
+		return links.NewRandomGenerator(cfg.IDLength), nil
+	case links.StrategyCounter:
+		return links.NewCounterGenerator(counter), nil
+	case links.StrategyHash:
+		return links.NewHashGenerator(cfg.IDLength), nil
+	case links.StrategySqids:
+		return links.NewSqidsGenerator(counter, cfg.IDSalt, cfg.IDLength), nil
+	}
+	return nil, fmt.Errorf("unknown ID strategy %q", cfg.IDStrategy)
+}
+
 func run() error {
 	// Печать сведений о сборке
 	printBuildInfo()
@@ -108,56 +148,157 @@ func run() error {
 	//Init Config
 	config.InitConfig()
 
-	//Init File Storage
-	storage.InitStorage()
+	//Init Logger
+	logger.New("info")
 
-	//Prepare DB
-	instance, err := postgres.SQLInstance()
+	//Init JWT keys
+	if err := auth.InitKeys(); err != nil {
+		return fmt.Errorf("jwt keys init err: %w", err)
+	}
+	// SIGHUP перечитывает настройки и ключи JWT, включая kid ключа подписи:
+	// так ключи ротируются без перезапуска
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := auth.ReloadKeys(); err != nil {
+				log.Println("JWT keys reload error:", err)
+				continue
+			}
+			log.Println("JWT keys reloaded")
+		}
+	}()
+
+	//Init Tracing
+	shutdownTracing, err := tracing.Init(context.Background(), config.AppConfig.TraceExporter, config.AppConfig.TraceFile)
 	if err != nil {
-		fmt.Println("DB prepare issues", err)
-	} else {
-		postgres.PrepareDB(instance)
+		return fmt.Errorf("tracing init err: %w", err)
 	}
 
-	//Init Logger
-	logger.New("info")
+	//Init Storage (memory, file или DB)
+	st, err := store.New()
+	if err != nil {
+		return fmt.Errorf("storage init err: %w", err)
+	}
 
 	// Create server
 	addr := config.AppConfig.ServerAddr
 	fmt.Println("Running server on", addr)
-	urlService := &services.PostgresURLService{}
+	var baseService services.URLService = services.NewURLService(store.Instrumented(st, strings.ToLower(config.AppConfig.StorageType)))
+	var cached *services.CachingURLService
+	if config.AppConfig.CacheSize > 0 {
+		cached = services.WithCache(baseService, config.AppConfig.CacheSize, config.AppConfig.CacheTTL)
+		baseService = cached
+	}
+	urlService := services.WithTracing(services.WithMetrics(baseService))
+
+	idGen, err := newIDGenerator()
+	if err != nil {
+		return err
+	}
+
+	// Асинхронная очередь удаления ссылок
+	deletes, err := deletion.NewQueue(urlService, deletion.Options{
+		Workers:       config.AppConfig.DeleteWorkers,
+		BatchSize:     config.AppConfig.DeleteBatchSize,
+		FlushInterval: config.AppConfig.DeleteFlushInterval,
+		QueueSize:     config.AppConfig.DeleteQueueSize,
+		Path:          config.AppConfig.DeleteQueueFile,
+	})
+	if err != nil {
+		return fmt.Errorf("delete queue init err: %w", err)
+	}
+
+	// API-ключи
+	keyStore, err := apikeys.NewStore()
+	if err != nil {
+		return fmt.Errorf("api keys init err: %w", err)
+	}
+	// Учетные записи с логином и паролем
+	accountStore, err := accounts.NewStore()
+	if err != nil {
+		return fmt.Errorf("accounts init err: %w", err)
+	}
+	accountService, err := accounts.NewService(accountStore)
+	if err != nil {
+		return fmt.Errorf("accounts init err: %w", err)
+	}
+	// Click analytics
+	clickStats := analytics.NewStore()
+	clicks := analytics.NewRecorder(clickStats, 4096, 256, time.Second)
+
+	// Background jobs; статистика удаляется вместе с истекшими ссылками
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	go services.RunReaper(bgCtx, urlService, config.AppConfig.ReaperInterval, clickStats.Delete)
+
+	// Отозванные при выходе сессии
+	auth.SetRevocations(auth.NewRevocationStore(bgCtx))
+
+	// gRPC API на отдельном порту
+	grpcLis, err := net.Listen("tcp", config.AppConfig.GRPCAddr)
+	if err != nil {
+		stopBackground()
+		return fmt.Errorf("grpc listen err: %w", err)
+	}
+	apiKeys := apikeys.NewService(keyStore)
+	grpcSrv := grpcapi.NewServer(urlService, grpcapi.WithDeleteQueue(deletes), grpcapi.WithIDGenerator(idGen), grpcapi.WithAPIKeys(apiKeys))
+	go func() {
+		log.Println("gRPC server on", config.AppConfig.GRPCAddr)
+		if err := grpcSrv.Serve(grpcLis); err != nil {
+			log.Println("gRPC server error:", err)
+		}
+	}()
+
+	// Базовый контекст запросов; отменяется, если запросы не успели завершиться при остановке
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: app.InitHandlers(urlService),
+		Addr:        addr,
+		Handler:     app.InitHandlers(urlService, app.WithAnalytics(clicks, clickStats), app.WithDeleteQueue(deletes), app.WithIDGenerator(idGen), app.WithAPIKeys(apiKeys), app.WithAccounts(accountService)),
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
 	}
 
-	// Gracefully shutdown
+	// Gracefully shutdown; shutdownDone закрывается после освобождения всех ресурсов
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		stop := make(chan os.Signal, 1)
 		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 		<-stop
 		log.Println("Shutting down server...")
-
-		// Save to file
-		filePath := config.AppConfig.FileStorage
-		if filePath != "" {
-			if err := storage.Store.ShutdownSaveToFile(filePath); err != nil {
-				log.Printf("Failed to save store: %v", err)
-			} else {
-				log.Printf("Store saved to: %s", filePath)
-			}
-		}
-
-		// Close PG connection
-		if instance != nil {
-			instance.CloseSQLInstance()
-		}
+		stopBackground()
 
 		// Shutdown server
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Printf("Shutdown error: %v", err)
+			cancelRequests()
+		}
+		grpcSrv.GracefulStop()
+
+		// Drain pending deletes
+		deleteCtx, deleteCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := deletes.Close(deleteCtx); err != nil {
+			log.Printf("Failed to drain delete queue: %v", err)
+		}
+		deleteCancel()
+
+		// Flush click analytics and counters of cached redirects
+		clicks.Close()
+		if cached != nil {
+			cached.Close()
+		}
+
+		// Save to file / close PG connection
+		if err := st.Close(); err != nil {
+			log.Printf("Failed to close store: %v", err)
+		}
+
+		// Flush spans
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
 		}
 	}()
 
@@ -190,5 +331,7 @@ func run() error {
 		}
 	}
 
+	// Ждем, пока данные будут сохранены
+	<-shutdownDone
 	return nil
 }
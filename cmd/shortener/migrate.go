@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/db/postgres"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"os"
+	"strconv"
+)
+
+// migrateUsage — справка по подкоманде migrate.
+const migrateUsage = "usage: shortener migrate up|down [N]|status [flags]"
+
+// runMigrate выполняет подкоманду "shortener migrate up|down [N]|status".
+// Оставшиеся аргументы разбираются как обычные флаги конфигурации (-d, -c и т.д.).
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	action, rest := args[0], args[1:]
+
+	steps := 1
+	if action == "down" && len(rest) > 0 {
+		if n, err := strconv.Atoi(rest[0]); err == nil {
+			if n < 1 {
+				return errors.New(migrateUsage)
+			}
+			steps, rest = n, rest[1:]
+		}
+	}
+
+	os.Args = append([]string{os.Args[0]}, rest...)
+	config.InitConfig()
+	logger.New("info")
+
+	if config.AppConfig.PGConfig.DBConnection == "" {
+		return errors.New("migrate: database DSN is not set (-d or DATABASE_DSN)")
+	}
+	instance, err := postgres.SQLInstance()
+	if err != nil {
+		return err
+	}
+	defer instance.CloseSQLInstance()
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		applied, err := postgres.MigrateUp(ctx, instance.PgSQL)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied migrations: %v\n", applied)
+		if err := postgres.ApplyDedupScope(ctx, instance.PgSQL, config.AppConfig.DedupScope); err != nil {
+			return err
+		}
+		fmt.Printf("Dedup scope: %s\n", config.AppConfig.DedupScope)
+	case "down":
+		reverted, err := postgres.MigrateDown(ctx, instance.PgSQL, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted migrations: %v\n", reverted)
+	case "status":
+		states, err := postgres.MigrationStatus(ctx, instance.PgSQL)
+		if err != nil {
+			return err
+		}
+		for _, st := range states {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s  %s\n", st.Version, st.Name, applied)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
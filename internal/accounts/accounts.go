@@ -0,0 +1,164 @@
+// Package accounts реализует регистрацию пользователей с логином и паролем.
+// Зарегистрированный пользователь — это userID, к которому привязаны имя и
+// хеш пароля; ссылки и токены работают с ним так же, как с анонимным.
+package accounts
+
+import (
+	"context"
+	"errors"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// minPassword и maxPassword — допустимая длина пароля в байтах.
+	minPassword = 8
+	maxPassword = 256
+)
+
+var (
+	// ErrUsernameTaken — имя пользователя уже занято.
+	ErrUsernameTaken = errors.New("username is already taken")
+	// ErrInvalidUsername — имя не подходит под usernamePattern.
+	ErrInvalidUsername = errors.New("username must be 3-32 characters: letters, digits, '.', '_' or '-'")
+	// ErrInvalidPassword — длина пароля вне допустимых границ.
+	ErrInvalidPassword = errors.New("password must be 8-256 bytes")
+	// ErrInvalidCredentials — неверное имя пользователя или пароль.
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+// usernamePattern — допустимое имя пользователя после приведения к нижнему регистру.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{2,31}$`)
+
+// Account — зарегистрированный пользователь.
+type Account struct {
+	// ID — userID владельца ссылок и токенов.
+	ID       string
+	Username string
+	// PasswordHash — хеш пароля в формате HashPassword.
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+// Store описывает хранилище учетных записей.
+type Store interface {
+	// Create сохраняет новую учетную запись; занятое имя — ErrUsernameTaken.
+	Create(ctx context.Context, a Account) error
+	// GetByUsername возвращает учетную запись по имени; found == false, если ее нет.
+	GetByUsername(ctx context.Context, username string) (a Account, found bool, err error)
+	// Exists сообщает, принадлежит ли userID зарегистрированному пользователю.
+	Exists(ctx context.Context, userID string) (bool, error)
+}
+
+// NewStore создает хранилище учетных записей в соответствии с
+// config.AppConfig.StorageType. Для файлового хранилища записи сохраняются
+// рядом с файлом ссылок.
+func NewStore() (Store, error) {
+	switch config.AppConfig.StorageType {
+	case "DB":
+		return &SQLStore{}, nil
+	case "File":
+		return NewFileStore(config.AppConfig.FileStorage + ".accounts")
+	}
+	return NewMemoryStore(), nil
+}
+
+// Service регистрирует пользователей и проверяет их пароли.
+type Service struct {
+	store Store
+	// dummyHash проверяется, когда имени нет, чтобы время ответа не выдавало,
+	// зарегистрировано ли имя.
+	dummyHash string
+	// hashSlots ограничивает число одновременных вычислений argon2id: каждое
+	// занимает argonMemory памяти, и без ограничения поток входов исчерпал бы ее.
+	hashSlots chan struct{}
+}
+
+// NewService создает сервис учетных записей поверх store. Пароли хешируются
+// не более чем в runtime.NumCPU() потоков, остальные запросы ждут очереди.
+func NewService(store Store) (*Service, error) {
+	dummy, err := HashPassword("dummy-password")
+	if err != nil {
+		return nil, err
+	}
+	return &Service{store: store, dummyHash: dummy, hashSlots: make(chan struct{}, runtime.NumCPU())}, nil
+}
+
+// acquireHash занимает слот для вычисления argon2id; пока слотов нет, ждет
+// освобождения или отмены ctx.
+func (s *Service) acquireHash(ctx context.Context) (release func(), err error) {
+	select {
+	case s.hashSlots <- struct{}{}:
+		return func() { <-s.hashSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// NormalizeUsername приводит имя к виду, в котором оно хранится.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// Register создает учетную запись с новым userID.
+func (s *Service) Register(ctx context.Context, username, password string) (Account, error) {
+	username = NormalizeUsername(username)
+	if !usernamePattern.MatchString(username) {
+		return Account{}, ErrInvalidUsername
+	}
+	if len(password) < minPassword || len(password) > maxPassword {
+		return Account{}, ErrInvalidPassword
+	}
+	release, err := s.acquireHash(ctx)
+	if err != nil {
+		return Account{}, err
+	}
+	hash, err := HashPassword(password)
+	release()
+	if err != nil {
+		return Account{}, err
+	}
+
+	a := Account{ID: auth.NewUserID(), Username: username, PasswordHash: hash, CreatedAt: time.Now().UTC()}
+	if err := s.store.Create(ctx, a); err != nil {
+		return Account{}, err
+	}
+	return a, nil
+}
+
+// Login проверяет имя и пароль; при несовпадении возвращает ErrInvalidCredentials.
+func (s *Service) Login(ctx context.Context, username, password string) (Account, error) {
+	if len(password) > maxPassword {
+		return Account{}, ErrInvalidCredentials
+	}
+	a, found, err := s.store.GetByUsername(ctx, NormalizeUsername(username))
+	if err != nil {
+		return Account{}, err
+	}
+	hash := a.PasswordHash
+	if !found {
+		hash = s.dummyHash
+	}
+	release, err := s.acquireHash(ctx)
+	if err != nil {
+		return Account{}, err
+	}
+	ok, err := VerifyPassword(password, hash)
+	release()
+	if err != nil {
+		return Account{}, err
+	}
+	if !found || !ok {
+		return Account{}, ErrInvalidCredentials
+	}
+	return a, nil
+}
+
+// IsRegistered сообщает, принадлежит ли userID зарегистрированному пользователю.
+func (s *Service) IsRegistered(ctx context.Context, userID string) (bool, error) {
+	return s.store.Exists(ctx, userID)
+}
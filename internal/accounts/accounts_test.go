@@ -0,0 +1,107 @@
+package accounts
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+
+	other, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "у каждого хеша своя соль")
+
+	ok, err := VerifyPassword("correct horse", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = VerifyPassword("wrong horse", hash)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = VerifyPassword("correct horse", "$argon2id$garbage")
+	assert.Error(t, err)
+}
+
+func TestServiceRegisterLogin(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewService(NewMemoryStore())
+	require.NoError(t, err)
+
+	a, err := s.Register(ctx, " Alice ", "s3cret-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", a.Username)
+	assert.NotEmpty(t, a.ID)
+
+	_, err = s.Register(ctx, "ALICE", "another-pass")
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+	_, err = s.Register(ctx, "a!", "s3cret-pass")
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+	_, err = s.Register(ctx, "bob", "short")
+	assert.ErrorIs(t, err, ErrInvalidPassword)
+
+	got, err := s.Login(ctx, "alice", "s3cret-pass")
+	require.NoError(t, err)
+	assert.Equal(t, a.ID, got.ID)
+	_, err = s.Login(ctx, "alice", "wrong-pass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = s.Login(ctx, "nobody", "s3cret-pass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	registered, err := s.IsRegistered(ctx, a.ID)
+	require.NoError(t, err)
+	assert.True(t, registered)
+	registered, err = s.IsRegistered(ctx, "anonymous")
+	require.NoError(t, err)
+	assert.False(t, registered)
+}
+
+func TestServiceWaitsForHashSlot(t *testing.T) {
+	s, err := NewService(NewMemoryStore())
+	require.NoError(t, err)
+	_, err = s.Register(context.Background(), "alice", "s3cret-pass")
+	require.NoError(t, err)
+
+	// все слоты заняты: вход ждет, пока не истечет контекст
+	for i := 0; i < cap(s.hashSlots); i++ {
+		s.hashSlots <- struct{}{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = s.Login(ctx, "alice", "s3cret-pass")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = s.Register(ctx, "bob", "s3cret-pass")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	<-s.hashSlots
+	_, err = s.Login(context.Background(), "alice", "s3cret-pass")
+	assert.NoError(t, err)
+}
+
+func TestFileStoreReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "url_history.json.accounts")
+
+	fs, err := NewFileStore(path)
+	require.NoError(t, err)
+	require.NoError(t, fs.Create(ctx, Account{ID: "u1", Username: "alice", PasswordHash: "h"}))
+
+	fs, err = NewFileStore(path)
+	require.NoError(t, err)
+	a, found, err := fs.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "u1", a.ID)
+	exists, err := fs.Exists(ctx, "u1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.ErrorIs(t, fs.Create(ctx, Account{ID: "u2", Username: "alice"}), ErrUsernameTaken)
+}
@@ -0,0 +1,76 @@
+package accounts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore хранит учетные записи в памяти и после каждой регистрации
+// переписывает JSON-файл целиком.
+type FileStore struct {
+	*MemoryStore
+	path string
+	// mu упорядочивает записи файла
+	mu sync.Mutex
+}
+
+// NewFileStore загружает учетные записи из path; отсутствующий файл — пустое хранилище.
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Account
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, a := range list {
+		f.byUsername[a.Username] = a
+		f.ids[a.ID] = struct{}{}
+	}
+	return f, nil
+}
+
+// Create сохраняет новую учетную запись.
+func (f *FileStore) Create(ctx context.Context, a Account) error {
+	if err := f.MemoryStore.Create(ctx, a); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// save атомарно переписывает файл текущим набором учетных записей.
+func (f *FileStore) save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := json.Marshal(f.snapshot())
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
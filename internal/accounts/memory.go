@@ -0,0 +1,59 @@
+package accounts
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore хранит учетные записи в памяти; они теряются при перезапуске.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	byUsername map[string]Account
+	ids        map[string]struct{}
+}
+
+// NewMemoryStore создает пустое хранилище учетных записей в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{byUsername: make(map[string]Account), ids: make(map[string]struct{})}
+}
+
+// Create сохраняет новую учетную запись.
+func (m *MemoryStore) Create(_ context.Context, a Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.byUsername[a.Username]; ok {
+		return ErrUsernameTaken
+	}
+	m.byUsername[a.Username] = a
+	m.ids[a.ID] = struct{}{}
+	return nil
+}
+
+// GetByUsername возвращает учетную запись по имени.
+func (m *MemoryStore) GetByUsername(_ context.Context, username string) (Account, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.byUsername[username]
+	return a, ok, nil
+}
+
+// Exists сообщает, принадлежит ли userID учетной записи.
+func (m *MemoryStore) Exists(_ context.Context, userID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.ids[userID]
+	return ok, nil
+}
+
+// snapshot возвращает копию всех учетных записей.
+func (m *MemoryStore) snapshot() []Account {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]Account, 0, len(m.byUsername))
+	for _, a := range m.byUsername {
+		res = append(res, a)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Username < res[j].Username })
+	return res
+}
@@ -0,0 +1,33 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/zauremazhikovayandex/url/internal/db/postgres"
+)
+
+// SQLStore хранит учетные записи в таблице accounts PostgreSQL.
+type SQLStore struct{}
+
+// Create сохраняет новую учетную запись; нарушение уникальности имени — ErrUsernameTaken.
+func (s *SQLStore) Create(ctx context.Context, a Account) error {
+	err := postgres.InsertAccount(ctx, postgres.Account(a))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
+// GetByUsername возвращает учетную запись по имени.
+func (s *SQLStore) GetByUsername(ctx context.Context, username string) (Account, bool, error) {
+	a, found, err := postgres.SelectAccountByUsername(ctx, username)
+	return Account(a), found, err
+}
+
+// Exists сообщает, принадлежит ли userID учетной записи.
+func (s *SQLStore) Exists(ctx context.Context, userID string) (bool, error) {
+	return postgres.AccountExists(ctx, userID)
+}
@@ -0,0 +1,124 @@
+// Package analytics собирает статистику переходов по коротким ссылкам.
+package analytics
+
+import (
+	"context"
+	"errors"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Click — одна запись о переходе по короткой ссылке.
+type Click struct {
+	LinkID         string
+	Timestamp      time.Time
+	Referrer       string
+	UserAgent      string
+	IPBucket       string
+	AcceptLanguage string
+}
+
+// Granularity — шаг временного ряда статистики.
+type Granularity string
+
+// Допустимые шаги временного ряда.
+const (
+	Hour Granularity = "hour"
+	Day  Granularity = "day"
+)
+
+// ErrBadGranularity сигнализирует о неизвестном шаге временного ряда.
+var ErrBadGranularity = errors.New("granularity must be hour or day")
+
+// ParseGranularity разбирает шаг ряда; пустая строка означает Day.
+func ParseGranularity(s string) (Granularity, error) {
+	switch Granularity(s) {
+	case "", Day:
+		return Day, nil
+	case Hour:
+		return Hour, nil
+	}
+	return "", ErrBadGranularity
+}
+
+// Truncate округляет t вниз до начала шага (в UTC).
+func (g Granularity) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	if g == Hour {
+		return t.Truncate(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Point — значение временного ряда.
+type Point struct {
+	Time   time.Time `json:"time"`
+	Clicks int64     `json:"clicks"`
+}
+
+// Stats — агрегированная статистика ссылки.
+type Stats struct {
+	// Total — число переходов за все время.
+	Total int64
+	// Series — переходы по шагам в запрошенном интервале [from, to).
+	Series []Point
+}
+
+// Store описывает хранилище статистики переходов.
+type Store interface {
+	// AddClicks сохраняет пачку переходов.
+	AddClicks(ctx context.Context, clicks []Click) error
+	// Stats возвращает статистику ссылки за интервал [from, to).
+	Stats(ctx context.Context, linkID string, g Granularity, from, to time.Time) (Stats, error)
+	// Delete удаляет статистику ссылок, например после их окончательного удаления.
+	Delete(ctx context.Context, linkIDs []string) error
+}
+
+// NewStore создает хранилище статистики в соответствии с config.AppConfig.StorageType.
+// Для файлового хранилища статистика ведется в памяти.
+func NewStore() Store {
+	if config.AppConfig.StorageType == "DB" {
+		return &SQLStore{}
+	}
+	return NewMemoryStore()
+}
+
+// FromRequest собирает запись о переходе из HTTP-запроса.
+func FromRequest(r *http.Request, linkID string) Click {
+	return Click{
+		LinkID:         linkID,
+		Timestamp:      time.Now().UTC(),
+		Referrer:       r.Referer(),
+		UserAgent:      r.UserAgent(),
+		IPBucket:       IPBucket(clientIP(r)),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+	}
+}
+
+// clientIP возвращает адрес клиента из X-Real-IP или RemoteAddr.
+func clientIP(r *http.Request) string {
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// IPBucket огрубляет адрес до подсети: /24 для IPv4 и /48 для IPv6,
+// чтобы не хранить адреса клиентов целиком.
+func IPBucket(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
@@ -0,0 +1,80 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPBucket(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", IPBucket("203.0.113.77"))
+	assert.Equal(t, "2001:db8:abcd::/48", IPBucket("2001:db8:abcd:12::1"))
+	assert.Equal(t, "", IPBucket("not-an-ip"))
+}
+
+func TestRecorderFlushesToStore(t *testing.T) {
+	st := NewMemoryStore()
+	rec := NewRecorder(st, 16, 4, time.Hour)
+
+	// недавний день, чтобы счетчики не выходили за statsRetention
+	base := time.Now().UTC().Truncate(24 * time.Hour).Add(-48*time.Hour + 10*time.Hour + 15*time.Minute)
+	for i := 0; i < 3; i++ {
+		rec.Record(Click{LinkID: "a", Timestamp: base.Add(time.Duration(i) * time.Hour)})
+	}
+	rec.Record(Click{LinkID: "b", Timestamp: base})
+	// Close досбрасывает неполную пачку
+	rec.Close()
+
+	stats, err := st.Stats(context.Background(), "a", Hour, base.Add(-time.Hour), base.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, stats.Total)
+	require.Len(t, stats.Series, 3)
+	assert.Equal(t, base.Truncate(time.Hour), stats.Series[0].Time)
+
+	stats, err = st.Stats(context.Background(), "a", Day, base.Add(-48*time.Hour), base.Add(48*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, stats.Series, 1)
+	assert.EqualValues(t, 3, stats.Series[0].Clicks)
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	rec := &Recorder{ch: make(chan Click, 1)}
+	rec.Record(Click{LinkID: "a"})
+	rec.Record(Click{LinkID: "a"})
+	assert.EqualValues(t, 1, rec.Dropped())
+}
+
+func TestMemoryStoreDelete(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStore()
+	now := time.Now().UTC()
+	require.NoError(t, st.AddClicks(ctx, []Click{{LinkID: "a", Timestamp: now}, {LinkID: "b", Timestamp: now}}))
+
+	// после удаления ссылки тот же id начинает статистику с нуля
+	require.NoError(t, st.Delete(ctx, []string{"a"}))
+	stats, err := st.Stats(ctx, "a", Hour, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, stats.Total)
+	assert.Empty(t, stats.Series)
+
+	stats, err = st.Stats(ctx, "b", Hour, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, stats.Total)
+}
+
+func TestMemoryStoreRetention(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStore()
+	now := time.Now().UTC()
+	old := now.Add(-statsRetention - 24*time.Hour)
+	require.NoError(t, st.AddClicks(ctx, []Click{{LinkID: "a", Timestamp: old}, {LinkID: "a", Timestamp: now}}))
+
+	stats, err := st.Stats(ctx, "a", Day, old.Add(-24*time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, stats.Total)
+	require.Len(t, stats.Series, 1)
+	assert.Equal(t, Day.Truncate(now), stats.Series[0].Time)
+}
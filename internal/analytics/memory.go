@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// statsRetention — сколько хранятся почасовые счетчики в памяти; общее
+// число переходов хранится, пока не удалена ссылка.
+const statsRetention = 90 * 24 * time.Hour
+
+// MemoryStore хранит переходы в памяти в виде почасовых счетчиков,
+// поэтому объем памяти растет с числом ссылок и часов, а не переходов.
+// Счетчики старше statsRetention удаляются.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	hourly map[string]map[int64]int64
+	total  map[string]int64
+	// pruneAt — когда в следующий раз удалить устаревшие счетчики
+	pruneAt time.Time
+}
+
+// NewMemoryStore создает пустое хранилище статистики в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		hourly: make(map[string]map[int64]int64),
+		total:  make(map[string]int64),
+	}
+}
+
+// AddClicks учитывает пачку переходов.
+func (m *MemoryStore) AddClicks(_ context.Context, clicks []Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range clicks {
+		buckets, ok := m.hourly[c.LinkID]
+		if !ok {
+			buckets = make(map[int64]int64)
+			m.hourly[c.LinkID] = buckets
+		}
+		buckets[Hour.Truncate(c.Timestamp).Unix()]++
+		m.total[c.LinkID]++
+	}
+	if now := time.Now(); now.After(m.pruneAt) {
+		m.pruneLocked(now.Add(-statsRetention))
+		m.pruneAt = now.Add(time.Hour)
+	}
+	return nil
+}
+
+// pruneLocked удаляет счетчики за часы раньше before; вызывается под мьютексом.
+func (m *MemoryStore) pruneLocked(before time.Time) {
+	for linkID, buckets := range m.hourly {
+		for hour := range buckets {
+			if time.Unix(hour, 0).Before(Hour.Truncate(before)) {
+				delete(buckets, hour)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(m.hourly, linkID)
+		}
+	}
+}
+
+// Delete удаляет статистику ссылок linkIDs.
+func (m *MemoryStore) Delete(_ context.Context, linkIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range linkIDs {
+		delete(m.hourly, id)
+		delete(m.total, id)
+	}
+	return nil
+}
+
+// Stats возвращает статистику ссылки за интервал [from, to).
+func (m *MemoryStore) Stats(_ context.Context, linkID string, g Granularity, from, to time.Time) (Stats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	series := make(map[int64]int64)
+	for hour, n := range m.hourly[linkID] {
+		t := time.Unix(hour, 0).UTC()
+		if t.Before(Hour.Truncate(from)) || !t.Before(to) {
+			continue
+		}
+		series[g.Truncate(t).Unix()] += n
+	}
+
+	res := Stats{Total: m.total[linkID], Series: make([]Point, 0, len(series))}
+	for ts, n := range series {
+		res.Series = append(res.Series, Point{Time: time.Unix(ts, 0).UTC(), Clicks: n})
+	}
+	sort.Slice(res.Series, func(i, j int) bool { return res.Series[i].Time.Before(res.Series[j].Time) })
+	return res, nil
+}
@@ -0,0 +1,108 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Recorder асинхронно передает переходы в Store через буферизованный канал.
+// Record никогда не блокирует обработчик редиректа: при переполнении буфера
+// запись отбрасывается и учитывается в Dropped.
+type Recorder struct {
+	sink          Store
+	ch            chan Click
+	batchSize     int
+	flushInterval time.Duration
+	dropped       atomic.Int64
+	stop          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewRecorder создает и запускает Recorder. Пачка сбрасывается в sink,
+// когда набирается batchSize записей или проходит flushInterval.
+func NewRecorder(sink Store, bufferSize, batchSize int, flushInterval time.Duration) *Recorder {
+	r := &Recorder{
+		sink:          sink,
+		ch:            make(chan Click, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		stop:          make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// Record ставит переход в очередь без блокировки.
+func (r *Recorder) Record(c Click) {
+	if r == nil {
+		return
+	}
+	select {
+	case r.ch <- c:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped возвращает число отброшенных из-за переполнения записей.
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close останавливает прием и сбрасывает накопленные записи.
+func (r *Recorder) Close() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// run накапливает записи и сбрасывает их пачками.
+func (r *Recorder) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Click, 0, r.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := r.sink.AddClicks(ctx, batch); err != nil {
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Analytics flush ERROR: %s", err)})
+		}
+		batch = make([]Click, 0, r.batchSize)
+	}
+
+	for {
+		select {
+		case c := <-r.ch:
+			batch = append(batch, c)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-r.stop:
+			// досбрасываем то, что уже в буфере
+			for {
+				select {
+				case c := <-r.ch:
+					batch = append(batch, c)
+					if len(batch) >= r.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package analytics
+
+import (
+	"context"
+	"github.com/zauremazhikovayandex/url/internal/db/postgres"
+	"time"
+)
+
+// SQLStore хранит переходы в таблице url_clicks PostgreSQL.
+type SQLStore struct{}
+
+// AddClicks сохраняет пачку переходов одной операцией COPY.
+func (s *SQLStore) AddClicks(ctx context.Context, clicks []Click) error {
+	rows := make([]postgres.Click, 0, len(clicks))
+	for _, c := range clicks {
+		rows = append(rows, postgres.Click(c))
+	}
+	return postgres.InsertClicks(ctx, rows)
+}
+
+// Delete удаляет переходы по ссылкам linkIDs.
+func (s *SQLStore) Delete(ctx context.Context, linkIDs []string) error {
+	return postgres.DeleteClicks(ctx, linkIDs)
+}
+
+// Stats возвращает статистику ссылки за интервал [from, to).
+func (s *SQLStore) Stats(ctx context.Context, linkID string, g Granularity, from, to time.Time) (Stats, error) {
+	total, points, err := postgres.SelectClickStats(ctx, linkID, string(g), from, to)
+	if err != nil {
+		return Stats{}, err
+	}
+	res := Stats{Total: total, Series: make([]Point, 0, len(points))}
+	for _, p := range points {
+		res.Series = append(res.Series, Point{Time: p.Time.UTC(), Clicks: p.Clicks})
+	}
+	return res, nil
+}
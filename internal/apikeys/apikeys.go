@@ -0,0 +1,184 @@
+// Package apikeys выпускает и проверяет долгоживущие API-ключи пользователей.
+// Ключ показывается владельцу один раз при создании, в хранилище остается
+// только хеш его секрета.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"strings"
+	"time"
+)
+
+const (
+	// Prefix — префикс API-ключа, по которому он отличается от JWT.
+	Prefix = "sk_"
+	// idBytes и secretBytes — длина случайных частей ключа.
+	idBytes     = 8
+	secretBytes = 32
+	// maxName — предельная длина названия ключа.
+	maxName = 100
+	// touchEvery — как часто обновляется время последнего использования ключа.
+	touchEvery = time.Minute
+)
+
+var (
+	// ErrInvalidKey — ключ не найден, отозван или его секрет не совпал.
+	ErrInvalidKey = errors.New("invalid api key")
+	// ErrNoScopes — ключ создается без прав.
+	ErrNoScopes = errors.New("at least one scope is required")
+	// ErrNameTooLong — название ключа длиннее maxName.
+	ErrNameTooLong = fmt.Errorf("name must be at most %d bytes", maxName)
+)
+
+// Key — выпущенный API-ключ без секрета.
+type Key struct {
+	ID     string
+	UserID string
+	Name   string
+	// Hash — SHA-256 секрета в hex.
+	Hash       string
+	Scopes     []auth.Scope
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// Store описывает хранилище API-ключей.
+type Store interface {
+	// Create сохраняет новый ключ.
+	Create(ctx context.Context, k Key) error
+	// Get возвращает ключ по id; found == false, если ключа нет.
+	Get(ctx context.Context, id string) (k Key, found bool, err error)
+	// ListByUser возвращает ключи пользователя в порядке создания.
+	ListByUser(ctx context.Context, userID string) ([]Key, error)
+	// Delete удаляет ключи ids пользователя userID и возвращает число удаленных.
+	Delete(ctx context.Context, userID string, ids []string) (int, error)
+	// Touch отмечает время последнего использования ключа.
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+// NewStore создает хранилище ключей в соответствии с config.AppConfig.StorageType.
+// Для файлового хранилища ключи сохраняются рядом с файлом ссылок.
+func NewStore() (Store, error) {
+	switch config.AppConfig.StorageType {
+	case "DB":
+		return &SQLStore{}, nil
+	case "File":
+		return NewFileStore(config.AppConfig.FileStorage + ".keys")
+	}
+	return NewMemoryStore(), nil
+}
+
+// Service выпускает, отзывает и проверяет API-ключи.
+type Service struct {
+	store Store
+}
+
+var _ auth.KeyAuthenticator = (*Service)(nil)
+
+// NewService создает сервис ключей поверх store.
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+// Create выпускает ключ пользователю userID и возвращает его вместе с токеном —
+// строкой, которую клиент передает в Authorization: Bearer. Токен больше нигде
+// не сохраняется.
+func (s *Service) Create(ctx context.Context, userID, name string, scopes []auth.Scope) (Key, string, error) {
+	if len(scopes) == 0 {
+		return Key{}, "", ErrNoScopes
+	}
+	if len(name) > maxName {
+		return Key{}, "", ErrNameTooLong
+	}
+
+	id := make([]byte, idBytes)
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(id); err != nil {
+		return Key{}, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+
+	k := Key{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Name:      name,
+		Hash:      hashSecret(encoded),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.store.Create(ctx, k); err != nil {
+		return Key{}, "", err
+	}
+	return k, Prefix + k.ID + "_" + encoded, nil
+}
+
+// List возвращает ключи пользователя.
+func (s *Service) List(ctx context.Context, userID string) ([]Key, error) {
+	return s.store.ListByUser(ctx, userID)
+}
+
+// Revoke удаляет ключи ids пользователя; чужие и несуществующие id пропускаются.
+func (s *Service) Revoke(ctx context.Context, userID string, ids []string) (int, error) {
+	return s.store.Delete(ctx, userID, ids)
+}
+
+// Authenticate проверяет токен ключа и возвращает владельца и права.
+// Для строк без Prefix возвращает auth.ErrNotAPIKey.
+func (s *Service) Authenticate(ctx context.Context, token string) (string, []auth.Scope, error) {
+	id, secret, err := parseToken(token)
+	if err != nil {
+		return "", nil, err
+	}
+	k, found, err := s.store.Get(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if !found || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashSecret(secret))) != 1 {
+		return "", nil, ErrInvalidKey
+	}
+
+	now := time.Now().UTC()
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= touchEvery {
+		if err := s.store.Touch(ctx, k.ID, now); err != nil {
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("API key touch ERROR: %s", err)})
+		}
+	}
+	return k.UserID, k.Scopes, nil
+}
+
+// parseToken разбирает токен вида sk_<id>_<secret>.
+func parseToken(token string) (id, secret string, err error) {
+	rest, ok := strings.CutPrefix(token, Prefix)
+	if !ok {
+		return "", "", auth.ErrNotAPIKey
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || len(id) != 2*idBytes || secret == "" {
+		return "", "", ErrInvalidKey
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", "", ErrInvalidKey
+	}
+	return id, secret, nil
+}
+
+// hashSecret возвращает SHA-256 секрета в hex. Секрет случайный и длинный,
+// поэтому медленный хеш паролей ему не нужен.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
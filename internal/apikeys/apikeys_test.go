@@ -0,0 +1,77 @@
+package apikeys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.New("error")
+	os.Exit(m.Run())
+}
+
+func TestServiceAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	s := NewService(store)
+
+	k, token, err := s.Create(ctx, "u1", "ci", []auth.Scope{auth.ScopeShorten})
+	require.NoError(t, err)
+
+	stored, _, err := store.Get(ctx, k.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, token, stored.Hash, "секрет не хранится в открытом виде")
+
+	userID, scopes, err := s.Authenticate(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", userID)
+	assert.Equal(t, []auth.Scope{auth.ScopeShorten}, scopes)
+
+	_, _, err = s.Authenticate(ctx, token[:len(token)-1]+"A")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, _, err = s.Authenticate(ctx, "sk_nothex_secret")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, _, err = s.Authenticate(ctx, "eyJhbGciOi.x.y")
+	assert.ErrorIs(t, err, auth.ErrNotAPIKey)
+
+	// чужой ключ не отзывается
+	n, err := s.Revoke(ctx, "u2", []string{k.ID})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = s.Revoke(ctx, "u1", []string{k.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, _, err = s.Authenticate(ctx, token)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, _, err = s.Create(ctx, "u1", "", nil)
+	assert.ErrorIs(t, err, ErrNoScopes)
+}
+
+func TestFileStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json.keys")
+
+	f, err := NewFileStore(path)
+	require.NoError(t, err)
+	_, token, err := NewService(f).Create(ctx, "u1", "cli", []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err)
+
+	reopened, err := NewFileStore(path)
+	require.NoError(t, err)
+	userID, _, err := NewService(reopened).Authenticate(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", userID)
+
+	keys, err := reopened.ListByUser(ctx, "u1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+}
@@ -0,0 +1,93 @@
+package apikeys
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore хранит ключи в памяти и после каждого изменения переписывает
+// JSON-файл целиком: ключей немного, а меняются они редко.
+type FileStore struct {
+	*MemoryStore
+	path string
+	// mu упорядочивает записи файла
+	mu sync.Mutex
+}
+
+// NewFileStore загружает ключи из path; отсутствующий файл — пустое хранилище.
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		f.keys[k.ID] = k
+	}
+	return f, nil
+}
+
+// Create сохраняет новый ключ.
+func (f *FileStore) Create(ctx context.Context, k Key) error {
+	if err := f.MemoryStore.Create(ctx, k); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// Delete удаляет ключи ids пользователя userID.
+func (f *FileStore) Delete(ctx context.Context, userID string, ids []string) (int, error) {
+	n, err := f.MemoryStore.Delete(ctx, userID, ids)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	return n, f.save()
+}
+
+// Touch отмечает время последнего использования ключа.
+func (f *FileStore) Touch(ctx context.Context, id string, at time.Time) error {
+	if err := f.MemoryStore.Touch(ctx, id, at); err != nil {
+		return err
+	}
+	return f.save()
+}
+
+// save атомарно переписывает файл текущим набором ключей.
+func (f *FileStore) save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := json.Marshal(f.snapshot())
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,91 @@
+package apikeys
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore хранит ключи в памяти; они теряются при перезапуске.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryStore создает пустое хранилище ключей в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]Key)}
+}
+
+// Create сохраняет новый ключ.
+func (m *MemoryStore) Create(_ context.Context, k Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[k.ID] = k
+	return nil
+}
+
+// Get возвращает ключ по id.
+func (m *MemoryStore) Get(_ context.Context, id string) (Key, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	k, ok := m.keys[id]
+	return k, ok, nil
+}
+
+// ListByUser возвращает ключи пользователя в порядке создания.
+func (m *MemoryStore) ListByUser(_ context.Context, userID string) ([]Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var res []Key
+	for _, k := range m.keys {
+		if k.UserID == userID {
+			res = append(res, k)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].CreatedAt.Before(res[j].CreatedAt)
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+// Delete удаляет ключи ids пользователя userID.
+func (m *MemoryStore) Delete(_ context.Context, userID string, ids []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, id := range ids {
+		if k, ok := m.keys[id]; ok && k.UserID == userID {
+			delete(m.keys, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Touch отмечает время последнего использования ключа.
+func (m *MemoryStore) Touch(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[id]; ok {
+		k.LastUsedAt = &at
+		m.keys[id] = k
+	}
+	return nil
+}
+
+// snapshot возвращает копию всех ключей.
+func (m *MemoryStore) snapshot() []Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]Key, 0, len(m.keys))
+	for _, k := range m.keys {
+		res = append(res, k)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
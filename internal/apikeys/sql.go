@@ -0,0 +1,77 @@
+package apikeys
+
+import (
+	"context"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/db/postgres"
+	"time"
+)
+
+// SQLStore хранит ключи в таблице api_keys PostgreSQL.
+type SQLStore struct{}
+
+// Create сохраняет новый ключ.
+func (s *SQLStore) Create(ctx context.Context, k Key) error {
+	scopes := make([]string, 0, len(k.Scopes))
+	for _, sc := range k.Scopes {
+		scopes = append(scopes, string(sc))
+	}
+	return postgres.InsertAPIKey(ctx, postgres.APIKey{
+		ID:        k.ID,
+		UserID:    k.UserID,
+		Name:      k.Name,
+		Hash:      k.Hash,
+		Scopes:    scopes,
+		CreatedAt: k.CreatedAt,
+	})
+}
+
+// Get возвращает ключ по id.
+func (s *SQLStore) Get(ctx context.Context, id string) (Key, bool, error) {
+	row, found, err := postgres.SelectAPIKey(ctx, id)
+	if err != nil || !found {
+		return Key{}, found, err
+	}
+	return fromRow(row), true, nil
+}
+
+// ListByUser возвращает ключи пользователя в порядке создания.
+func (s *SQLStore) ListByUser(ctx context.Context, userID string) ([]Key, error) {
+	rows, err := postgres.SelectAPIKeysByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Key, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, fromRow(row))
+	}
+	return res, nil
+}
+
+// Delete удаляет ключи ids пользователя userID.
+func (s *SQLStore) Delete(ctx context.Context, userID string, ids []string) (int, error) {
+	n, err := postgres.DeleteAPIKeys(ctx, userID, ids)
+	return int(n), err
+}
+
+// Touch отмечает время последнего использования ключа.
+func (s *SQLStore) Touch(ctx context.Context, id string, at time.Time) error {
+	return postgres.TouchAPIKey(ctx, id, at)
+}
+
+// fromRow переводит строку api_keys в Key.
+func fromRow(row postgres.APIKey) Key {
+	k := Key{
+		ID:         row.ID,
+		UserID:     row.UserID,
+		Name:       row.Name,
+		Hash:       row.Hash,
+		Scopes:     make([]auth.Scope, 0, len(row.Scopes)),
+		CreatedAt:  row.CreatedAt.UTC(),
+		LastUsedAt: row.LastUsedAt,
+	}
+	for _, s := range row.Scopes {
+		k.Scopes = append(k.Scopes, auth.Scope(s))
+	}
+	return k
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/accounts"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"net/http"
+	"time"
+)
+
+// credentialsRequest — тело POST /api/auth/register и POST /api/auth/login.
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// accountResponse — ответ на регистрацию и вход. Merged — число ссылок,
+// перешедших от анонимного пользователя запроса.
+type accountResponse struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Merged    int       `json:"merged"`
+}
+
+// PostAuthRegister создает учетную запись и входит в нее. Ссылки анонимного
+// пользователя запроса переходят к новой учетной записи.
+func (h *Handler) PostAuthRegister(w http.ResponseWriter, r *http.Request) {
+	h.authenticate(w, r, "/api/auth/register", http.StatusCreated, h.accounts.Register)
+}
+
+// PostAuthLogin входит в учетную запись по имени и паролю. Если запрос пришел
+// от анонимного пользователя, его ссылки переходят к учетной записи.
+func (h *Handler) PostAuthLogin(w http.ResponseWriter, r *http.Request) {
+	h.authenticate(w, r, "/api/auth/login", http.StatusOK, h.accounts.Login)
+}
+
+// authenticate — общая часть регистрации и входа: проверяет учетные данные
+// через fn, забирает ссылки анонимного пользователя и открывает новую сессию.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request, uri string, status int,
+	fn func(ctx context.Context, username, password string) (accounts.Account, error)) {
+	timeStart := time.Now()
+
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON")
+		logger.Logging.WriteToLog(timeStart, uri, "POST", http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	account, err := fn(r.Context(), req.Username, req.Password)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, uri) {
+			return
+		}
+		switch {
+		case errors.Is(err, accounts.ErrInvalidCredentials):
+			auth.Unauthorized(w, true)
+			logger.Logging.WriteToLog(timeStart, uri, "POST", http.StatusUnauthorized, "Invalid credentials")
+		case errors.Is(err, accounts.ErrInvalidUsername), errors.Is(err, accounts.ErrInvalidPassword):
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			logger.Logging.WriteToLog(timeStart, uri, "POST", http.StatusBadRequest, "Invalid credentials format")
+		case errors.Is(err, accounts.ErrUsernameTaken):
+			writeJSONError(w, http.StatusConflict, err.Error())
+			logger.Logging.WriteToLog(timeStart, uri, "POST", http.StatusConflict, "Username taken")
+		default:
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Account ERROR: %s", err)})
+			http.Error(w, "server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	merged, err := h.claimAnonymousURLs(r.Context(), account.ID)
+	if err != nil {
+		// сессия анонимного пользователя сохраняется, и при повторном входе
+		// ссылки будут перенесены снова
+		if handleContextErr(w, r, timeStart, uri) {
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Merge URLs ERROR: %s", err)})
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	token, exp, err := auth.NewSession(account.ID)
+	if err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Token issue ERROR: %s", err)})
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	if r.Header.Get("Authorization") == "" {
+		auth.SetSessionCookie(w, token, exp)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(accountResponse{
+		UserID:    account.ID,
+		Username:  account.Username,
+		Token:     token,
+		ExpiresAt: exp.UTC(),
+		Merged:    merged,
+	})
+	logger.Logging.WriteToLog(timeStart, uri, "POST", status, fmt.Sprintf("Signed in, merged %d", merged))
+}
+
+// claimAnonymousURLs передает учетной записи accountID ссылки пользователя,
+// вошедшего по JWT, если он анонимный, и завершает его сессию. Ссылки
+// другой учетной записи и владельцев API-ключей не переносятся.
+func (h *Handler) claimAnonymousURLs(ctx context.Context, accountID string) (int, error) {
+	claims := auth.GetClaims(ctx)
+	if claims == nil || claims.UserID == accountID {
+		return 0, nil
+	}
+	registered, err := h.accounts.IsRegistered(ctx, claims.UserID)
+	if err != nil || registered {
+		return 0, err
+	}
+
+	merged, err := h.urlService.MergeUserURLs(ctx, claims.UserID, accountID)
+	if err != nil {
+		return 0, err
+	}
+	if err := auth.Logout(ctx, claims); err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Anonymous logout ERROR: %s", err)})
+	}
+	return merged, nil
+}
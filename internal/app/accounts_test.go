@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/accounts"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+)
+
+func TestAccountsMergeAnonymousURLs(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	config.AppConfig.JWTTokenExp = time.Hour
+	auth.SetRevocations(auth.NewMemoryRevocations())
+	accts, err := accounts.NewService(accounts.NewMemoryStore())
+	require.NoError(t, err)
+	router := InitHandlers(h.urlService, WithAccounts(accts))
+
+	send := func(method, target, body string, cookie *http.Cookie) *http.Response {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Result()
+	}
+	signIn := func(target, body string, cookie *http.Cookie, status int) (accountResponse, *http.Cookie) {
+		resp := send(http.MethodPost, target, body, cookie)
+		require.Equal(t, status, resp.StatusCode)
+		require.Len(t, resp.Cookies(), 1)
+		var out accountResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out, resp.Cookies()[0]
+	}
+	shorten := func(url string) *http.Cookie {
+		resp := send(http.MethodPost, "/api/shorten", `{"url":"`+url+`"}`, nil)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		return resp.Cookies()[0]
+	}
+	userURLs := func(cookie *http.Cookie) []URLPair {
+		resp := send(http.MethodGet, "/api/user/urls", "", cookie)
+		if resp.StatusCode == http.StatusNoContent {
+			return nil
+		}
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var out []URLPair
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out
+	}
+
+	// регистрация забирает ссылки анонимного пользователя и завершает его сессию
+	anon := shorten("https://example.com/first")
+	reg, session := signIn("/api/auth/register", `{"username":"Alice","password":"s3cret-pass"}`, anon, http.StatusCreated)
+	assert.Equal(t, "alice", reg.Username)
+	assert.Equal(t, 1, reg.Merged)
+	assert.Len(t, userURLs(session), 1)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/user/urls", "", anon).StatusCode)
+
+	// вход с другого анонимного устройства
+	anon = shorten("https://example.com/second")
+	login, session := signIn("/api/auth/login", `{"username":"alice","password":"s3cret-pass"}`, anon, http.StatusOK)
+	assert.Equal(t, reg.UserID, login.UserID)
+	assert.Equal(t, 1, login.Merged)
+	assert.Len(t, userURLs(session), 2)
+
+	// ссылки другой учетной записи не переносятся
+	_, bob := signIn("/api/auth/register", `{"username":"bob","password":"bob-password"}`, nil, http.StatusCreated)
+	login, _ = signIn("/api/auth/login", `{"username":"alice","password":"s3cret-pass"}`, bob, http.StatusOK)
+	assert.Zero(t, login.Merged)
+	assert.Len(t, userURLs(session), 2)
+
+	resp := send(http.MethodPost, "/api/auth/login", `{"username":"alice","password":"wrong-pass"}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, resp.Cookies(), "без входа анонимный пользователь не создается")
+	resp = send(http.MethodPost, "/api/auth/register", `{"username":"ALICE","password":"s3cret-pass"}`, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp = send(http.MethodPost, "/api/auth/register", `{"username":"carol","password":"short"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
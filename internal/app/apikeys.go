@@ -0,0 +1,148 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/apikeys"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"net/http"
+	"time"
+)
+
+// apiKeyRequest — тело POST /api/user/keys.
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// apiKeyResponse — API-ключ в ответах; Key заполняется только при создании.
+type apiKeyResponse struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name,omitempty"`
+	Scopes     []auth.Scope `json:"scopes"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+	Key        string       `json:"key,omitempty"`
+}
+
+// toAPIKeyResponse переводит ключ в ответ API.
+func toAPIKeyResponse(k apikeys.Key) apiKeyResponse {
+	return apiKeyResponse{ID: k.ID, Name: k.Name, Scopes: k.Scopes, CreatedAt: k.CreatedAt, LastUsedAt: k.LastUsedAt}
+}
+
+// RequireScope пропускает запрос, только если у него есть право scope;
+// иначе отвечает 403.
+func RequireScope(scope auth.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasScope(r.Context(), scope) {
+				writeJSONError(w, http.StatusForbidden, fmt.Sprintf("scope %q is required", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PostAPIKey выпускает API-ключ текущему пользователю. Секрет ключа
+// возвращается только в этом ответе.
+func (h *Handler) PostAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserID(r.Context())
+	timeStart := time.Now()
+
+	var req apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON")
+		logger.Logging.WriteToLog(timeStart, "/api/user/keys", "POST", http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	scopes, err := auth.ParseScopes(req.Scopes)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		logger.Logging.WriteToLog(timeStart, "/api/user/keys", "POST", http.StatusBadRequest, "Invalid scopes")
+		return
+	}
+
+	k, token, err := h.apiKeys.Create(r.Context(), userID, req.Name, scopes)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, "/api/user/keys") {
+			return
+		}
+		if errors.Is(err, apikeys.ErrNoScopes) || errors.Is(err, apikeys.ErrNameTooLong) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			logger.Logging.WriteToLog(timeStart, "/api/user/keys", "POST", http.StatusBadRequest, "Invalid key request")
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Create API key ERROR: %s", err)})
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	res := toAPIKeyResponse(k)
+	res.Key = token
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(res)
+	logger.Logging.WriteToLog(timeStart, "/api/user/keys", "POST", http.StatusCreated, "key="+k.ID)
+}
+
+// GetAPIKeys возвращает ключи текущего пользователя без секретов;
+// 204, если ключей нет.
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserID(r.Context())
+	timeStart := time.Now()
+
+	keys, err := h.apiKeys.List(r.Context(), userID)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, "/api/user/keys") {
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("List API keys ERROR: %s", err)})
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		logger.Logging.WriteToLog(timeStart, "/api/user/keys", "GET", http.StatusNoContent, "No keys")
+		return
+	}
+
+	res := make([]apiKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, toAPIKeyResponse(k))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(res)
+	logger.Logging.WriteToLog(timeStart, "/api/user/keys", "GET", http.StatusOK, fmt.Sprintf("keys=%d", len(res)))
+}
+
+// DeleteAPIKeys отзывает ключи текущего пользователя по JSON-массиву id;
+// чужие и несуществующие id пропускаются.
+func (h *Handler) DeleteAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserID(r.Context())
+	timeStart := time.Now()
+
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil || len(ids) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON or empty ID list")
+		logger.Logging.WriteToLog(timeStart, "/api/user/keys", "DELETE", http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	n, err := h.apiKeys.Revoke(r.Context(), userID, ids)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, "/api/user/keys") {
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Revoke API keys ERROR: %s", err)})
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	logger.Logging.WriteToLog(timeStart, "/api/user/keys", "DELETE", http.StatusNoContent, fmt.Sprintf("revoked=%d", n))
+}
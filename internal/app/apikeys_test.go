@@ -0,0 +1,82 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/apikeys"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+)
+
+func TestAPIKeys(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	config.AppConfig.JWTTokenExp = time.Hour
+	srv := httptest.NewServer(InitHandlers(h.urlService, WithAPIKeys(apikeys.NewService(apikeys.NewMemoryStore()))))
+	defer srv.Close()
+
+	jwt, err := auth.GenerateToken("owner")
+	require.NoError(t, err)
+
+	do := func(method, path, token, body string) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/user/keys", jwt, `{"name":"cli","scopes":["read","read"]}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Empty(t, resp.Cookies(), "при входе по заголовку cookie не выдается")
+	var created apiKeyResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.Equal(t, []auth.Scope{auth.ScopeRead}, created.Scopes)
+	require.True(t, strings.HasPrefix(created.Key, apikeys.Prefix))
+
+	resp = do(http.MethodPost, "/api/user/keys", jwt, `{"scopes":["admin"]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// ключ только на чтение
+	resp = do(http.MethodGet, "/api/user/urls", created.Key, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = do(http.MethodPost, "/api/shorten", created.Key, `{"url":"https://example.com"}`)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = do(http.MethodGet, "/api/user/keys", created.Key, "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// список без секретов
+	resp = do(http.MethodGet, "/api/user/keys", jwt, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var listed []apiKeyResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	require.Len(t, listed, 1)
+	assert.Equal(t, created.ID, listed[0].ID)
+	assert.Empty(t, listed[0].Key)
+	assert.NotNil(t, listed[0].LastUsedAt)
+
+	// неверные учетные данные не подменяются анонимным пользователем
+	resp = do(http.MethodGet, "/api/user/urls", created.Key+"x", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+	resp = do(http.MethodGet, "/api/user/urls", "not-a-jwt", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// отзыв
+	resp = do(http.MethodDelete, "/api/user/keys", jwt, `["`+created.ID+`"]`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = do(http.MethodGet, "/api/user/urls", created.Key, "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -3,31 +3,164 @@ package app
 
 import (
 	"github.com/go-chi/chi/v5"
+	"github.com/zauremazhikovayandex/url/internal/accounts"
+	"github.com/zauremazhikovayandex/url/internal/analytics"
+	"github.com/zauremazhikovayandex/url/internal/apikeys"
 	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/deletion"
+	"github.com/zauremazhikovayandex/url/internal/links"
 	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/metrics"
 	"github.com/zauremazhikovayandex/url/internal/services"
+	"github.com/zauremazhikovayandex/url/internal/tracing"
+	"net/http"
 )
 
 // Handler - структура сервиса
 type Handler struct {
 	urlService services.URLService
+	clicks     *analytics.Recorder
+	clickStats analytics.Store
+	deletes    *deletion.Queue
+	idGen      links.IDGenerator
+	apiKeys    *apikeys.Service
+	accounts   *accounts.Service
+	policies   map[RouteGroup]auth.Policy
+}
+
+// RouteGroup — группа маршрутов с общей политикой аутентификации.
+type RouteGroup string
+
+const (
+	// GroupPublic — сокращение ссылок, переходы и служебные эндпоинты.
+	GroupPublic RouteGroup = "public"
+	// GroupUser — эндпоинты /api/user/* и /api/auth/*, работающие с данными
+	// и сессией пользователя.
+	GroupUser RouteGroup = "user"
+	// GroupAccount — регистрация и вход по имени и паролю; анонимный
+	// пользователь запроса, если он есть, передает ссылки учетной записи.
+	GroupAccount RouteGroup = "account"
+)
+
+// defaultAuthPolicies — политики групп маршрутов, если они не заданы через WithAuthPolicy.
+var defaultAuthPolicies = map[RouteGroup]auth.Policy{
+	GroupPublic:  auth.PolicyOptional,
+	GroupUser:    auth.PolicyRequired,
+	GroupAccount: auth.PolicyNone,
+}
+
+// Option настраивает необязательные зависимости Handler.
+type Option func(*Handler)
+
+// WithAnalytics включает запись переходов через recorder и
+// эндпоинт статистики поверх stats.
+func WithAnalytics(recorder *analytics.Recorder, stats analytics.Store) Option {
+	return func(h *Handler) {
+		h.clicks = recorder
+		h.clickStats = stats
+	}
+}
+
+// WithDeleteQueue включает асинхронное удаление ссылок через очередь q;
+// без нее DELETE /api/user/urls удаляет синхронно.
+func WithDeleteQueue(q *deletion.Queue) Option {
+	return func(h *Handler) {
+		h.deletes = q
+	}
+}
+
+// WithIDGenerator задает стратегию генерации коротких id; по умолчанию — случайные id из 8 символов.
+func WithIDGenerator(gen links.IDGenerator) Option {
+	return func(h *Handler) {
+		h.idGen = gen
+	}
+}
+
+// WithAPIKeys включает вход по API-ключам и эндпоинты /api/user/keys.
+func WithAPIKeys(keys *apikeys.Service) Option {
+	return func(h *Handler) {
+		h.apiKeys = keys
+	}
+}
+
+// WithAccounts включает регистрацию и вход по имени и паролю:
+// POST /api/auth/register и POST /api/auth/login.
+func WithAccounts(accts *accounts.Service) Option {
+	return func(h *Handler) {
+		h.accounts = accts
+	}
+}
+
+// WithAuthPolicy задает политику аутентификации группы маршрутов g.
+func WithAuthPolicy(g RouteGroup, p auth.Policy) Option {
+	return func(h *Handler) {
+		h.policies[g] = p
+	}
 }
 
 // InitHandlers Инициализация хендлеров
-func InitHandlers(urlService services.URLService) *chi.Mux {
-	h := &Handler{urlService: urlService}
+func InitHandlers(urlService services.URLService, opts ...Option) *chi.Mux {
+	h := &Handler{urlService: urlService, idGen: links.NewRandomGenerator(8), policies: make(map[RouteGroup]auth.Policy)}
+	for g, p := range defaultAuthPolicies {
+		h.policies[g] = p
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
 	r := chi.NewRouter()
-	r.Use(auth.Middleware)
+	r.Use(tracing.HTTPMiddleware)
+	r.Use(metrics.HTTPMiddleware)
+	var keyAuth auth.KeyAuthenticator
+	if h.apiKeys != nil {
+		keyAuth = h.apiKeys
+	}
+	r.Use(auth.Identify(keyAuth))
 	r.Use(h.GzipMiddleware)
 	r.Use(logger.RequestLogger)
 
-	r.Post("/", h.PostHandler)
-	r.Post("/api/shorten", h.PostShortenHandler)
-	r.Post("/api/shorten/batch", h.PostShortenHandlerBatch)
-	r.Get("/{id}", h.GetHandler)
-	r.Get("/api/user/urls", h.GetUserURLs)
-	r.Delete("/api/user/urls", h.DeleteUserURLs)
-	r.Get("/ping", h.GetDBPing)
+	// handle регистрирует обработчик группы g с политикой аутентификации группы,
+	// спаном и дедлайном маршрута
+	handle := func(g RouteGroup, method, pattern, name string, fn http.HandlerFunc, mws ...func(http.Handler) http.Handler) {
+		mws = append([]func(http.Handler) http.Handler{h.policies[g].Middleware()}, mws...)
+		mws = append(mws, Deadline(routeTimeout(method, pattern)))
+		r.With(mws...).Method(method, pattern, tracing.Handler(name, fn))
+	}
+
+	shorten, read, del := RequireScope(auth.ScopeShorten), RequireScope(auth.ScopeRead), RequireScope(auth.ScopeDelete)
+	handle(GroupPublic, http.MethodPost, "/", "PostHandler", h.PostHandler, shorten)
+	handle(GroupPublic, http.MethodPost, "/api/shorten", "PostShortenHandler", h.PostShortenHandler, shorten)
+	handle(GroupPublic, http.MethodPost, "/api/shorten/batch", "PostShortenHandlerBatch", h.PostShortenHandlerBatch, shorten)
+	handle(GroupPublic, http.MethodPost, "/api/shorten/bulk", "PostShortenBulk", h.PostShortenBulk, shorten)
+	handle(GroupPublic, http.MethodGet, "/{id}", "GetHandler", h.GetHandler)
+	handle(GroupUser, http.MethodGet, "/api/user/urls", "GetUserURLs", h.GetUserURLs, read)
+	handle(GroupUser, http.MethodGet, "/api/user/urls/export", "GetUserURLsExport", h.GetUserURLsExport, read)
+	handle(GroupUser, http.MethodDelete, "/api/user/urls", "DeleteUserURLs", h.DeleteUserURLs, del)
+	handle(GroupPublic, http.MethodGet, "/ping", "GetDBPing", h.GetDBPing)
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
+	handle(GroupPublic, http.MethodGet, "/api/internal/stats", "GetInternalStats", h.GetInternalStats, TrustedSubnet(config.AppConfig.TrustedSubnet))
+	if config.AppConfig.JWKSEnabled {
+		handle(GroupPublic, http.MethodGet, "/.well-known/jwks.json", "GetJWKS", h.GetJWKS)
+	}
+	if h.clickStats != nil {
+		handle(GroupUser, http.MethodGet, "/api/user/urls/{id}/stats", "GetURLStats", h.GetURLStats, read)
+	}
+	handle(GroupUser, http.MethodPost, "/api/auth/refresh", "PostAuthRefresh", h.PostAuthRefresh)
+	handle(GroupUser, http.MethodPost, "/api/auth/logout", "PostAuthLogout", h.PostAuthLogout)
+	if h.accounts != nil {
+		// подбор паролей и массовая регистрация ограничиваются по IP
+		throttle := RateLimit(authRateLimit, authRateWindow)
+		handle(GroupAccount, http.MethodPost, "/api/auth/register", "PostAuthRegister", h.PostAuthRegister, throttle)
+		handle(GroupAccount, http.MethodPost, "/api/auth/login", "PostAuthLogin", h.PostAuthLogin, throttle)
+	}
+	if h.apiKeys != nil {
+		// ключами нельзя управлять по API-ключу: у ключей нет права ScopeKeys
+		keys := RequireScope(auth.ScopeKeys)
+		handle(GroupUser, http.MethodPost, "/api/user/keys", "PostAPIKey", h.PostAPIKey, keys)
+		handle(GroupUser, http.MethodGet, "/api/user/keys", "GetAPIKeys", h.GetAPIKeys, keys)
+		handle(GroupUser, http.MethodDelete, "/api/user/keys", "DeleteAPIKeys", h.DeleteAPIKeys, keys)
+	}
 
 	return r
 }
@@ -2,17 +2,22 @@ package app
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/zauremazhikovayandex/url/internal/config"
-	"github.com/zauremazhikovayandex/url/internal/db/postgres"
 	"github.com/zauremazhikovayandex/url/internal/logger"
 	"github.com/zauremazhikovayandex/url/internal/services"
+	"github.com/zauremazhikovayandex/url/internal/store"
 )
 
 // ---- мок URLService, чтобы не ходить в БД ----
@@ -20,17 +25,35 @@ type noopService struct{}
 
 var _ services.URLService = (*noopService)(nil)
 
-func (noopService) GetOriginalURL(context.Context, string) (string, error)          { return "", nil }
-func (noopService) GetURLsByUserID(context.Context, string) ([]postgres.URL, error) { return nil, nil }
-func (noopService) GetShortIDByOriginalURL(context.Context, string) (string, error) { return "", nil }
-func (noopService) SaveURL(context.Context, string, string, string) error           { return nil }
-func (noopService) DeleteForUser(context.Context, string, string) error             { return nil }
-func (noopService) BatchDelete(context.Context, []string, string) error             { return nil }
+func (noopService) GetOriginalURL(context.Context, string) (string, error) { return "", nil }
+func (noopService) GetURL(context.Context, string) (store.URL, error)      { return store.URL{}, nil }
+func (noopService) GetURLsByUserID(context.Context, string, store.ListOptions) (store.Page, error) {
+	return store.Page{}, nil
+}
+func (noopService) ExportUserURLs(context.Context, string, func(store.URL) error) error {
+	return nil
+}
+func (noopService) GetShortIDByOriginalURL(context.Context, string, string) (string, error) {
+	return "", nil
+}
+func (noopService) SaveURL(context.Context, store.URL) error { return nil }
+func (noopService) SaveURLs(_ context.Context, urls []store.URL) ([]error, error) {
+	return make([]error, len(urls)), nil
+}
+func (noopService) AddClicks(context.Context, map[string]int64) error          { return nil }
+func (noopService) MergeUserURLs(context.Context, string, string) (int, error) { return 0, nil }
+func (noopService) DeleteForUser(context.Context, string, string) error        { return nil }
+func (noopService) BatchDelete(context.Context, []string, string) error        { return nil }
+func (noopService) DeleteMany(context.Context, []store.Deletion) error         { return nil }
+func (noopService) PurgeExpired(context.Context) ([]string, error)             { return nil, nil }
+func (noopService) CountURLs(context.Context) (int, error)                     { return 0, nil }
+func (noopService) CountUsers(context.Context) (int, error)                    { return 0, nil }
 
 func BenchmarkPostShortenJSON(b *testing.B) {
 	config.InitConfig()
 	logger.New("info")
 	config.AppConfig.StorageType = "DB"
+	config.AppConfig.JWTSecretKey = "benchmark-secret-at-least-32-bytes!"
 
 	srv := httptest.NewServer(InitHandlers(noopService{}))
 	defer srv.Close()
@@ -57,3 +80,142 @@ func BenchmarkPostShortenJSON(b *testing.B) {
 		resp.Body.Close()
 	}
 }
+
+// dbLatency имитирует время запроса к PostgreSQL.
+const dbLatency = 200 * time.Microsecond
+
+// slowRedirectService отвечает на чтение ссылки с задержкой dbLatency.
+type slowRedirectService struct{ noopService }
+
+func (slowRedirectService) GetOriginalURL(context.Context, string) (string, error) {
+	time.Sleep(dbLatency)
+	return "https://example.com/target", nil
+}
+
+func (slowRedirectService) GetURL(_ context.Context, id string) (store.URL, error) {
+	time.Sleep(dbLatency)
+	return store.URL{ID: id, OriginalURL: "https://example.com/target"}, nil
+}
+
+// benchmarkRedirect гоняет GET /{id} по небольшому набору горячих ссылок.
+func benchmarkRedirect(b *testing.B, svc services.URLService) {
+	config.InitConfig()
+	logger.New("info")
+
+	srv := httptest.NewServer(InitHandlers(svc))
+	defer srv.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resp, err := client.Get(fmt.Sprintf("%s/hot%d", srv.URL, i%64))
+		if err != nil {
+			b.Fatalf("request failed: %v", err)
+		}
+		if resp.StatusCode != http.StatusTemporaryRedirect {
+			b.Fatalf("unexpected status: %d", resp.StatusCode)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func BenchmarkRedirectNoCache(b *testing.B) {
+	benchmarkRedirect(b, slowRedirectService{})
+}
+
+func BenchmarkRedirectCached(b *testing.B) {
+	svc := services.WithCache(slowRedirectService{}, 1024, time.Minute)
+	defer svc.Close()
+	benchmarkRedirect(b, svc)
+}
+
+// ndjsonSource генерирует NDJSON-тело импорта на лету, не держа его в памяти.
+type ndjsonSource struct {
+	lines, next int
+	buf         []byte
+}
+
+func (s *ndjsonSource) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.next == s.lines {
+			return 0, io.EOF
+		}
+		s.buf = fmt.Appendf(s.buf[:0], `{"correlation_id":"%d","original_url":"https://legacy.example/%d"}`+"\n", s.next, s.next)
+		s.next++
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// heapProbeService замеряет занятую кучу при сохранении каждой пачки импорта.
+type heapProbeService struct {
+	noopService
+	mu   sync.Mutex
+	peak uint64
+}
+
+func (s *heapProbeService) SaveURLs(ctx context.Context, urls []store.URL) ([]error, error) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	s.mu.Lock()
+	s.peak = max(s.peak, ms.HeapInuse)
+	s.mu.Unlock()
+	return s.noopService.SaveURLs(ctx, urls)
+}
+
+// BenchmarkBulkImport потоково импортирует 10 тыс. — 1 млн строк. Метрика
+// peak-heap-KB — максимальный размер кучи во время импорта — не должна расти
+// вместе с числом строк.
+func BenchmarkBulkImport(b *testing.B) {
+	config.InitConfig()
+	logger.New("error")
+
+	for _, lines := range []int{10_000, 100_000, 1_000_000} {
+		for _, compressed := range []bool{false, true} {
+			b.Run(fmt.Sprintf("lines=%d/gzip=%t", lines, compressed), func(b *testing.B) {
+				svc := &heapProbeService{}
+				srv := httptest.NewServer(InitHandlers(svc))
+				defer srv.Close()
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					var body io.Reader = &ndjsonSource{lines: lines}
+					if compressed {
+						pr, pw := io.Pipe()
+						go func(src io.Reader) {
+							zw := gzip.NewWriter(pw)
+							_, err := io.Copy(zw, src)
+							if err == nil {
+								err = zw.Close()
+							}
+							pw.CloseWithError(err)
+						}(body)
+						body = pr
+					}
+
+					req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/shorten/bulk", body)
+					req.Header.Set("Content-Type", "application/x-ndjson")
+					if compressed {
+						req.Header.Set("Content-Encoding", "gzip")
+					}
+					resp, err := http.DefaultClient.Do(req)
+					if err != nil {
+						b.Fatalf("request failed: %v", err)
+					}
+					if resp.StatusCode != http.StatusOK {
+						b.Fatalf("unexpected status: %d", resp.StatusCode)
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				b.StopTimer()
+				b.ReportMetric(float64(svc.peak)/1024, "peak-heap-KB")
+			})
+		}
+	}
+}
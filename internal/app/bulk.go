@@ -0,0 +1,198 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/services"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+const (
+	// bulkMaxLine — предельная длина строки импорта; более длинные строки отклоняются.
+	bulkMaxLine = 16 << 10
+	// bulkDefaultChunk — размер пачки импорта, если BulkChunkSize не задан.
+	bulkDefaultChunk = 1000
+)
+
+// bulkRequestItem — строка запроса потокового импорта.
+type bulkRequestItem struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+	Alias         string `json:"alias,omitempty"`
+}
+
+// bulkResponseItem — строка ответа потокового импорта; line — номер строки запроса.
+type bulkResponseItem struct {
+	Line          int    `json:"line"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+// bulkEntry — строка запроса в текущей пачке; rejected — причина отказа до сохранения.
+type bulkEntry struct {
+	line     int
+	item     services.BatchItem
+	rejected string
+}
+
+// PostShortenBulk принимает ссылки в формате NDJSON (по объекту на строку) и
+// сохраняет их пачками по BulkChunkSize. Результат каждой строки возвращается
+// тоже в NDJSON и отправляется клиенту сразу после сохранения пачки, поэтому
+// память не зависит от размера запроса. Если импорт прерван ошибкой хранилища,
+// последней строкой ответа идет элемент со статусом error без line.
+func (h *Handler) PostShortenBulk(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserID(r.Context())
+
+	timeStart := time.Now()
+	ctx := r.Context()
+
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/x-ndjson" {
+		http.Error(w, "Content-Type must be application/x-ndjson", http.StatusBadRequest)
+		logger.Logging.WriteToLog(timeStart, "/api/shorten/bulk", "POST", http.StatusBadRequest, "Invalid Content-Type")
+		return
+	}
+
+	chunkSize := config.AppConfig.BulkChunkSize
+	if chunkSize <= 0 {
+		chunkSize = bulkDefaultChunk
+	}
+
+	// Ответ пишется, пока тело запроса еще читается: без полного дуплекса
+	// HTTP/1 сервер перестает читать тело после начала ответа
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Bulk import full duplex unavailable: %s", err)})
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+
+	var total, ok int
+	chunk := make([]bulkEntry, 0, chunkSize)
+	items := make([]services.BatchItem, 0, chunkSize)
+
+	// flush сохраняет текущую пачку и пишет результаты ее строк в порядке запроса
+	flush := func() error {
+		items = items[:0]
+		for _, e := range chunk {
+			if e.rejected == "" {
+				items = append(items, e.item)
+			}
+		}
+		var results []services.BatchResult
+		if len(items) > 0 {
+			var err error
+			results, err = services.ShortenBatch(ctx, h.urlService, h.idGen, userID, items)
+			if err != nil {
+				return err
+			}
+		}
+
+		k := 0
+		for _, e := range chunk {
+			out := bulkResponseItem{Line: e.line, CorrelationID: e.item.CorrelationID, Status: services.BatchInvalid, Error: e.rejected}
+			if e.rejected == "" {
+				res := results[k]
+				k++
+				out.Status, out.Error = res.Status, res.Reason
+				if res.OK() {
+					out.ShortURL = fmt.Sprintf("%s/%s", config.AppConfig.BaseURL, res.ID)
+					ok++
+				}
+			}
+			if err := enc.Encode(out); err != nil {
+				return err
+			}
+		}
+		total += len(chunk)
+		chunk = chunk[:0]
+		// Flush недоступен, если обертки ответа его не поддерживают; тогда данные уйдут позже
+		_ = rc.Flush()
+		return nil
+	}
+
+	br := bufio.NewReaderSize(r.Body, bulkMaxLine)
+	lineNo := 0
+	for {
+		line, tooLong, readErr := readLine(br)
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			// тело оборвано — сохраняем то, что успели прочитать
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Bulk import read ERROR: %s", readErr)})
+		}
+		lineNo++
+
+		switch {
+		case tooLong:
+			chunk = append(chunk, bulkEntry{line: lineNo, rejected: fmt.Sprintf("line exceeds %d bytes", bulkMaxLine)})
+		case len(bytes.TrimSpace(line)) > 0:
+			var req bulkRequestItem
+			if err := json.Unmarshal(line, &req); err != nil {
+				chunk = append(chunk, bulkEntry{line: lineNo, rejected: "invalid JSON"})
+				break
+			}
+			chunk = append(chunk, bulkEntry{line: lineNo, item: services.BatchItem{
+				CorrelationID: req.CorrelationID,
+				OriginalURL:   req.OriginalURL,
+				Alias:         req.Alias,
+			}})
+		}
+
+		if len(chunk) >= chunkSize || (readErr != nil && len(chunk) > 0) {
+			if err := flush(); err != nil {
+				h.abortBulk(r, timeStart, enc, err)
+				return
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	logger.Logging.WriteToLog(timeStart, "/api/shorten/bulk", "POST", http.StatusOK, fmt.Sprintf("ok=%d total=%d", ok, total))
+}
+
+// abortBulk завершает прерванный импорт: при отмене запроса пишет в лог код 499,
+// иначе сообщает клиенту об ошибке последней строкой ответа.
+func (h *Handler) abortBulk(r *http.Request, timeStart time.Time, enc *json.Encoder, err error) {
+	if ctxErr := r.Context().Err(); ctxErr != nil {
+		code := logger.StatusClientClosedRequest
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
+		}
+		logger.Logging.WriteToLog(timeStart, "/api/shorten/bulk", "POST", code, "Bulk import interrupted")
+		return
+	}
+	logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Bulk import storage ERROR: %s", err)})
+	_ = enc.Encode(bulkResponseItem{Status: services.BatchError, Error: "import aborted: storage error"})
+	logger.Logging.WriteToLog(timeStart, "/api/shorten/bulk", "POST", http.StatusInternalServerError, "Bulk import aborted")
+}
+
+// readLine читает строку без завершающего перевода строки. Строка длиннее
+// буфера br дочитывается и отбрасывается, в этом случае tooLong = true.
+// Результат действителен до следующего чтения из br.
+func readLine(br *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		part, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			tooLong = true
+			continue
+		}
+		if tooLong {
+			return nil, true, err
+		}
+		return bytes.TrimRight(part, "\r\n"), false, err
+	}
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"net/http"
+	"time"
+)
+
+// Deadline ограничивает время обработки запроса: контекст запроса
+// отменяется по истечении timeout. При timeout <= 0 ограничения нет.
+func Deadline(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// defaultRouteTimeouts — дедлайны маршрутов, для которых общий дедлайн не подходит;
+// потоковые импорт и выгрузка по умолчанию не ограничены.
+var defaultRouteTimeouts = map[string]time.Duration{
+	"POST /api/shorten/bulk":    0,
+	"GET /api/user/urls/export": 0,
+}
+
+// routeTimeout возвращает дедлайн маршрута "METHOD pattern" из конфигурации,
+// а если он не задан — дедлайн маршрута по умолчанию или общий дедлайн запроса.
+func routeTimeout(method, pattern string) time.Duration {
+	conf := config.AppConfig
+	if d, ok := conf.RouteTimeouts[method+" "+pattern]; ok {
+		return d
+	}
+	if d, ok := defaultRouteTimeouts[method+" "+pattern]; ok {
+		return d
+	}
+	return conf.RequestTimeout
+}
+
+// handleContextErr проверяет, не отменен ли запрос. Если клиент отключился,
+// ответ не пишется, а в лог попадает код 499; при истекшем дедлайне
+// клиент получает 504. Возвращает true, если запрос обработан.
+func handleContextErr(w http.ResponseWriter, r *http.Request, timeStart time.Time, uri string) bool {
+	switch err := r.Context().Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, "Request timeout", http.StatusGatewayTimeout)
+		logger.Logging.WriteToLog(timeStart, uri, r.Method, http.StatusGatewayTimeout, "Deadline exceeded")
+		return true
+	case errors.Is(err, context.Canceled):
+		logger.Logging.WriteToLog(timeStart, uri, r.Method, logger.StatusClientClosedRequest, "Client closed request")
+		return true
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,9 +14,11 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/zauremazhikovayandex/url/internal/config"
-	"github.com/zauremazhikovayandex/url/internal/db/storage"
+	"github.com/zauremazhikovayandex/url/internal/links"
 	"github.com/zauremazhikovayandex/url/internal/logger"
 	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/services"
+	"github.com/zauremazhikovayandex/url/internal/store"
 )
 
 type discardDriver struct{}
@@ -27,6 +30,9 @@ func (discardDriver) Error(*message.LogMessage) {}
 func (discardDriver) Fatal(*message.LogMessage) {}
 func (discardDriver) Panic(*message.LogMessage) {}
 
+// exampleStore — хранилище в памяти, используемое примерами.
+var exampleStore store.Store
+
 type noopAccessLogger struct{}
 
 func (noopAccessLogger) WriteToLog(time.Time, string, string, int, string) {}
@@ -35,7 +41,6 @@ func (noopAccessLogger) WriteToLog(time.Time, string, string, int, string) {}
 func setupMemoryApp() (*Handler, func()) {
 	// save globals
 	prevCfg := config.AppConfig
-	prevStore := storage.Store
 	prevLog := logger.Log
 	prevLogging := logger.Logging
 
@@ -47,7 +52,7 @@ func setupMemoryApp() (*Handler, func()) {
 		FileStorage:    "",
 		PGConfig:       &config.PostgresConfig{DBConnection: "", DBTimeout: 10},
 		StorageType:    "Memory",
-		JWTSecretKey:   "example",
+		JWTSecretKey:   "example-secret-0123456789abcdefgh",
 		JWTTokenExp:    0,
 		JWTCookieName:  "auth_token",
 	}
@@ -58,17 +63,15 @@ func setupMemoryApp() (*Handler, func()) {
 	logger.Logging = noopAccessLogger{}
 
 	// fresh in-mem store
-	storage.Store = &storage.Storage{}
-	storage.InitStorage()
+	exampleStore = store.NewMemoryStore()
 
 	teardown := func() {
 		config.AppConfig = prevCfg
-		storage.Store = prevStore
 		logger.Log = prevLog
 		logger.Logging = prevLogging
 	}
 
-	return &Handler{}, teardown
+	return &Handler{urlService: services.NewURLService(exampleStore), idGen: links.NewRandomGenerator(8)}, teardown
 }
 
 func routerForGet(h *Handler) http.Handler {
@@ -162,7 +165,7 @@ func ExampleHandler_GetHandler() {
 
 	id := "fixedID1"
 	original := "https://example.com/landing"
-	storage.Store.Set(id, original)
+	_ = exampleStore.Save(context.Background(), store.URL{ID: id, OriginalURL: original})
 
 	r := routerForGet(h)
 	req := httptest.NewRequest(http.MethodGet, "/"+id, nil)
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// exportFlushEvery — через сколько строк выгрузки данные досылаются клиенту.
+const exportFlushEvery = 256
+
+// exportRow — строка выгрузки ссылок пользователя.
+type exportRow struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Deleted     bool       `json:"deleted"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxClicks   int        `json:"max_clicks,omitempty"`
+	Clicks      int64      `json:"clicks"`
+}
+
+// exportEncoder пишет строки выгрузки в одном из форматов.
+type exportEncoder interface {
+	begin() error
+	write(row exportRow) error
+	end() error
+}
+
+// exportFormat описывает формат выгрузки.
+type exportFormat struct {
+	contentType string
+	ext         string
+	encoder     func(w io.Writer) exportEncoder
+}
+
+// exportFormats — поддерживаемые форматы выгрузки по значению параметра format.
+var exportFormats = map[string]exportFormat{
+	"csv":    {contentType: "text/csv; charset=utf-8", ext: "csv", encoder: func(w io.Writer) exportEncoder { return &csvExport{w: csv.NewWriter(w)} }},
+	"json":   {contentType: "application/json", ext: "json", encoder: func(w io.Writer) exportEncoder { return &jsonExport{w: w} }},
+	"ndjson": {contentType: "application/x-ndjson", ext: "ndjson", encoder: func(w io.Writer) exportEncoder { return &ndjsonExport{enc: json.NewEncoder(w)} }},
+}
+
+// csvExport пишет выгрузку в CSV с заголовком; пустые ячейки — значения не заданы.
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) begin() error {
+	return e.w.Write([]string{"short_url", "original_url", "created_at", "deleted", "expires_at", "max_clicks", "clicks"})
+}
+
+func (e *csvExport) write(row exportRow) error {
+	var expiresAt, maxClicks string
+	if row.ExpiresAt != nil {
+		expiresAt = row.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if row.MaxClicks > 0 {
+		maxClicks = strconv.Itoa(row.MaxClicks)
+	}
+	err := e.w.Write([]string{
+		row.ShortURL,
+		row.OriginalURL,
+		row.CreatedAt.UTC().Format(time.RFC3339),
+		strconv.FormatBool(row.Deleted),
+		expiresAt,
+		maxClicks,
+		strconv.FormatInt(row.Clicks, 10),
+	})
+	if err != nil {
+		return err
+	}
+	// csv.Writer буферизует строки — досылаем их, чтобы не держать в памяти
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonExport пишет выгрузку JSON-массивом, элемент за элементом.
+type jsonExport struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonExport) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonExport) write(row exportRow) error {
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.count++
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonExport) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// ndjsonExport пишет выгрузку NDJSON, по объекту на строку.
+type ndjsonExport struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExport) begin() error { return nil }
+
+func (e *ndjsonExport) write(row exportRow) error { return e.enc.Encode(row) }
+
+func (e *ndjsonExport) end() error { return nil }
+
+// GetUserURLsExport выгружает все ссылки пользователя, включая удаленные, в
+// формате из параметра format (csv, json или ndjson; по умолчанию json) как
+// файл для скачивания. Строки передаются клиенту по мере чтения из хранилища.
+// Если хранилище отказало до первой строки, клиент получает 500; после начала
+// ответа ошибка только прерывает выгрузку.
+func (h *Handler) GetUserURLsExport(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserID(r.Context())
+	timeStart := time.Now()
+
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "json"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "format must be one of csv, json, ndjson")
+		logger.Logging.WriteToLog(timeStart, "/api/user/urls/export", "GET", http.StatusBadRequest, "Invalid export format")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	enc := format.encoder(w)
+	rows := 0
+
+	// start пишет заголовки файла при первой строке или в конце пустой выгрузки
+	start := func() error {
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="urls-%s.%s"`, time.Now().UTC().Format("20060102"), format.ext))
+		w.WriteHeader(http.StatusOK)
+		return enc.begin()
+	}
+
+	err := h.urlService.ExportUserURLs(r.Context(), userID, func(u store.URL) error {
+		if rows == 0 {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		rows++
+		err := enc.write(exportRow{
+			ShortURL:    config.AppConfig.BaseURL + "/" + u.ID,
+			OriginalURL: u.OriginalURL,
+			CreatedAt:   u.CreatedAt,
+			Deleted:     u.Deleted,
+			ExpiresAt:   u.ExpiresAt,
+			MaxClicks:   u.MaxClicks,
+			Clicks:      u.Clicks,
+		})
+		if err == nil && rows%exportFlushEvery == 0 {
+			_ = rc.Flush()
+		}
+		return err
+	})
+	if err != nil {
+		if rows == 0 {
+			if handleContextErr(w, r, timeStart, "/api/user/urls/export") {
+				return
+			}
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Export ERROR: %s", err)})
+			http.Error(w, "server error", http.StatusInternalServerError)
+			logger.Logging.WriteToLog(timeStart, "/api/user/urls/export", "GET", http.StatusInternalServerError, "Export failed")
+			return
+		}
+		// заголовки уже отправлены — клиент получит оборванный файл
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Export interrupted after %d rows: %s", rows, err)})
+		return
+	}
+
+	if rows == 0 {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	if err := enc.end(); err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Export ERROR: %s", err)})
+		return
+	}
+	logger.Logging.WriteToLog(timeStart, "/api/user/urls/export", "GET", http.StatusOK, fmt.Sprintf("format=%s rows=%d", name, rows))
+}
@@ -3,57 +3,67 @@ package app
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/zauremazhikovayandex/url/internal/analytics"
 	"github.com/zauremazhikovayandex/url/internal/auth"
 	"github.com/zauremazhikovayandex/url/internal/config"
 	"github.com/zauremazhikovayandex/url/internal/db/postgres"
-	"github.com/zauremazhikovayandex/url/internal/db/storage"
 	"github.com/zauremazhikovayandex/url/internal/gzip"
+	"github.com/zauremazhikovayandex/url/internal/links"
 	"github.com/zauremazhikovayandex/url/internal/logger"
 	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/metrics"
+	"github.com/zauremazhikovayandex/url/internal/services"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"github.com/zauremazhikovayandex/url/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
 // URLPair описывает пару короткой и оригинальной ссылок в ответах API.
+// Поля срока жизни выводятся, только если они заданы для ссылки.
 type URLPair struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxClicks   int        `json:"max_clicks,omitempty"`
+	Clicks      int64      `json:"clicks,omitempty"`
 }
 
-// generateShortID - Генерация ID
-func generateShortID(n int) (string, error) {
-	b := make([]byte, n)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(b)[:n], nil
+// writeJSONError отвечает JSON-объектом {"error": "..."} с указанным статусом.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
 }
 
-// isValidURL - Проверка на корректный URL
-func isValidURL(rawURL string) bool {
-	parsed, err := url.ParseRequestURI(rawURL)
-	if err != nil {
-		return false
+// saveURL сохраняет ссылку под алиасом u.ID, а без алиаса — под сгенерированным id
+// с повтором при коллизии. Возвращает итоговый id.
+func (h *Handler) saveURL(ctx context.Context, u store.URL) (string, error) {
+	if u.ID != "" {
+		return u.ID, h.urlService.SaveURL(ctx, u)
 	}
-	return parsed.Scheme == "http" || parsed.Scheme == "https"
+	return services.SaveWithGeneratedID(ctx, h.urlService, h.idGen, u)
 }
 
 // resolveURLInsertError - Находим ID из БД по URL
 func resolveURLInsertError(ctx context.Context, w http.ResponseWriter, r *http.Request, h *Handler, timeStart time.Time, originalURL string, err error) {
-	if errors.Is(err, postgres.ErrDuplicateOriginalURL) {
+	if handleContextErr(w, r, timeStart, originalURL) {
+		return
+	}
+
+	if errors.Is(err, store.ErrDuplicateURL) {
 		// Получаем уже существующий ID
-		existingID, getErr := h.urlService.GetShortIDByOriginalURL(ctx, originalURL)
+		existingID, getErr := h.urlService.GetShortIDByOriginalURL(ctx, auth.GetUserID(ctx), originalURL)
 		if getErr != nil || existingID == "" {
 			// если ID не найден — логируем и всё равно отдаём 409, но с минимальным текстом
 			http.Error(w, "Conflict", http.StatusConflict)
@@ -89,9 +99,7 @@ func (h *Handler) PostHandler(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserID(r.Context())
 
 	timeStart := time.Now()
-	storageType := config.AppConfig.StorageType
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx := r.Context()
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil || len(body) == 0 {
@@ -102,32 +110,24 @@ func (h *Handler) PostHandler(w http.ResponseWriter, r *http.Request) {
 
 	originalURL := strings.TrimSpace(string(body))
 
-	if !isValidURL(originalURL) {
+	if !links.IsValidURL(originalURL) {
 		http.Error(w, "Invalid URL format", http.StatusBadRequest)
 		logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusBadRequest, "Invalid URL format")
 		return
 	}
 
-	id, err := generateShortID(8)
-	if err != nil || id == "" {
+	id, err := h.saveURL(ctx, store.URL{OriginalURL: originalURL, UserID: userID})
+	if errors.Is(err, services.ErrIDGeneration) {
+		logger.Log.Error(&message.LogMessage{Message: err.Error()})
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusInternalServerError, "Failed to generate short ID")
 		return
 	}
-
-	var shortURL string
-
-	if storageType == "DB" {
-		err = h.urlService.SaveURL(ctx, id, originalURL, userID)
-		if err != nil {
-			resolveURLInsertError(ctx, w, r, h, timeStart, originalURL, err)
-			return
-		}
-		shortURL = fmt.Sprintf("%s/%s", config.AppConfig.BaseURL, id)
-	} else {
-		storage.Store.Set(id, originalURL)
-		shortURL = fmt.Sprintf("%s/%s", config.AppConfig.BaseURL, id)
+	if err != nil {
+		resolveURLInsertError(ctx, w, r, h, timeStart, originalURL, err)
+		return
 	}
+	shortURL := fmt.Sprintf("%s/%s", config.AppConfig.BaseURL, id)
 
 	// Успешный ответ
 	w.Header().Set("Content-Type", "text/plain")
@@ -145,13 +145,16 @@ func (h *Handler) PostShortenHandler(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserID(r.Context())
 
 	timeStart := time.Now()
-	storageType := config.AppConfig.StorageType
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx := r.Context()
 
-	// Структура для чтения входного JSON
+	// Структура для чтения входного JSON; alias — необязательный пользовательский id,
+	// expires_at / ttl_seconds / max_clicks — необязательные ограничения срока жизни
 	type RequestPayload struct {
-		URL string `json:"url"`
+		URL        string     `json:"url"`
+		Alias      string     `json:"alias,omitempty"`
+		ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+		TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+		MaxClicks  int        `json:"max_clicks,omitempty"`
 	}
 
 	// Структура для ответа
@@ -176,27 +179,49 @@ func (h *Handler) PostShortenHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	originalURL := strings.TrimSpace(payload.URL)
-	if !isValidURL(originalURL) {
+	if !links.IsValidURL(originalURL) {
 		http.Error(w, "Invalid URL format", http.StatusBadRequest)
 		logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusBadRequest, "Invalid URL format")
 		return
 	}
 
-	id, err := generateShortID(8)
-	if err != nil || id == "" {
+	expiresAt, err := links.ResolveExpiry(payload.ExpiresAt, payload.TTLSeconds, payload.MaxClicks, time.Now())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusBadRequest, "Invalid expiry")
+		return
+	}
+
+	alias := strings.TrimSpace(payload.Alias)
+	if alias != "" {
+		if err := links.ValidateAlias(alias); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusBadRequest, "Invalid alias")
+			return
+		}
+	}
+
+	id, err := h.saveURL(ctx, store.URL{
+		ID:          alias,
+		OriginalURL: originalURL,
+		UserID:      userID,
+		ExpiresAt:   expiresAt,
+		MaxClicks:   payload.MaxClicks,
+	})
+	if errors.Is(err, services.ErrIDGeneration) {
+		logger.Log.Error(&message.LogMessage{Message: err.Error()})
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusInternalServerError, "Failed to generate short ID")
 		return
 	}
-
-	if storageType == "DB" {
-		err = h.urlService.SaveURL(ctx, id, originalURL, userID)
-		if err != nil {
-			resolveURLInsertError(ctx, w, r, h, timeStart, originalURL, err)
+	if err != nil {
+		if alias != "" && errors.Is(err, store.ErrIDConflict) {
+			writeJSONError(w, http.StatusConflict, links.ErrAliasTaken.Error())
+			logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusConflict, "Alias taken: "+alias)
 			return
 		}
-	} else {
-		storage.Store.Set(id, originalURL)
+		resolveURLInsertError(ctx, w, r, h, timeStart, originalURL, err)
+		return
 	}
 
 	shortURL := fmt.Sprintf("%s/%s", config.AppConfig.BaseURL, id)
@@ -209,24 +234,28 @@ func (h *Handler) PostShortenHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusCreated, shortURL)
 }
 
-// PostShortenHandlerBatch принимает массив JSON и возвращает массив коротких ссылок.
+// PostShortenHandlerBatch принимает массив JSON и возвращает итог по каждому элементу:
+// created или exists с короткой ссылкой, invalid или error с причиной.
+// Код ответа: 201 — все элементы получили ссылку (в том числе существующую),
+// 207 — часть элементов не обработана, 400 — не обработан ни один.
 func (h *Handler) PostShortenHandlerBatch(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserID(r.Context())
 
 	timeStart := time.Now()
-	storageType := config.AppConfig.StorageType
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx := r.Context()
 
 	// Структуры запроса и ответа
 	type BatchRequestItem struct {
 		CorrelationID string `json:"correlation_id"`
 		OriginalURL   string `json:"original_url"`
+		Alias         string `json:"alias,omitempty"`
 	}
 
 	type BatchResponseItem struct {
 		CorrelationID string `json:"correlation_id"`
-		ShortURL      string `json:"short_url"`
+		ShortURL      string `json:"short_url,omitempty"`
+		Status        string `json:"status"`
+		Error         string `json:"error,omitempty"`
 	}
 
 	var requests []BatchRequestItem
@@ -244,56 +273,61 @@ func (h *Handler) PostShortenHandlerBatch(w http.ResponseWriter, r *http.Request
 		logger.Logging.WriteToLog(timeStart, "", "POST", http.StatusBadRequest, "Invalid JSON array")
 		return
 	}
+	if len(requests) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "empty batch")
+		logger.Logging.WriteToLog(timeStart, "", "POST", http.StatusBadRequest, "Empty batch")
+		return
+	}
 
-	var responses []BatchResponseItem
-
-	for _, item := range requests {
-		originalURL := strings.TrimSpace(item.OriginalURL)
+	items := make([]services.BatchItem, len(requests))
+	for i, item := range requests {
+		items[i] = services.BatchItem{CorrelationID: item.CorrelationID, OriginalURL: item.OriginalURL, Alias: item.Alias}
+	}
 
-		if !isValidURL(originalURL) {
-			// Пропускаем или логируем ошибочный элемент (можно изменить поведение при необходимости)
-			logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusBadRequest, fmt.Sprintf("Invalid URL format for correlation_id=%s", item.CorrelationID))
-			continue
+	results, err := services.ShortenBatch(ctx, h.urlService, h.idGen, userID, items)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, "/api/shorten/batch") {
+			return
 		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Batch storage ERROR: %s", err)})
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Logging.WriteToLog(timeStart, "/api/shorten/batch", "POST", http.StatusInternalServerError, "Batch storage error")
+		return
+	}
 
-		id, err := generateShortID(8)
-		if err != nil || id == "" {
-			logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusInternalServerError, fmt.Sprintf("Failed to generate ID for correlation_id=%s", item.CorrelationID))
-			continue
+	responses := make([]BatchResponseItem, len(results))
+	var ok, created int
+	for i, res := range results {
+		responses[i] = BatchResponseItem{CorrelationID: res.CorrelationID, Status: res.Status, Error: res.Reason}
+		if res.OK() {
+			responses[i].ShortURL = fmt.Sprintf("%s/%s", config.AppConfig.BaseURL, res.ID)
+			ok++
 		}
-
-		if storageType == "DB" {
-			err = h.urlService.SaveURL(ctx, id, originalURL, userID)
-			if err != nil {
-				logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Storage ERROR for correlation_id=%s: %s", item.CorrelationID, err)})
-				continue
-			}
-		} else {
-			storage.Store.Set(id, originalURL)
+		if res.Status == services.BatchCreated {
+			created++
 		}
+	}
 
-		shortURL := fmt.Sprintf("%s/%s", config.AppConfig.BaseURL, id)
-
-		responses = append(responses, BatchResponseItem{
-			CorrelationID: item.CorrelationID,
-			ShortURL:      shortURL,
-		})
-		logger.Logging.WriteToLog(timeStart, originalURL, "POST", http.StatusCreated, shortURL)
+	status := http.StatusMultiStatus
+	switch {
+	case ok == 0:
+		status = http.StatusBadRequest
+	case ok == len(results):
+		status = http.StatusCreated
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(responses); err != nil {
 		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Failed to write batch response: %s", err)})
 	}
+	logger.Logging.WriteToLog(timeStart, "/api/shorten/batch", "POST", status, fmt.Sprintf("created=%d ok=%d total=%d", created, ok, len(results)))
 }
 
 // GetHandler выполняет редирект 307 по id короткой ссылки.
 func (h *Handler) GetHandler(w http.ResponseWriter, r *http.Request) {
 	timeStart := time.Now()
-	storageType := config.AppConfig.StorageType
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx := r.Context()
 
 	id := chi.URLParam(r, "id")
 	if id == "" {
@@ -302,59 +336,135 @@ func (h *Handler) GetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if storageType == "DB" {
-		originalURL, err := h.urlService.GetOriginalURL(ctx, id)
-		if err != nil {
-			if errors.Is(err, postgres.ErrURLDeleted) {
-				http.Error(w, "URL deleted", http.StatusGone)
-				logger.Logging.WriteToLog(timeStart, "", "GET", http.StatusGone, "URL deleted")
-				return
-			}
-			http.Error(w, "URL not found", http.StatusBadRequest)
-			logger.Logging.WriteToLog(timeStart, "", "GET", http.StatusBadRequest, "URL not found")
+	originalURL, err := h.urlService.GetOriginalURL(ctx, id)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, id) {
 			return
 		}
-		logger.Logging.WriteToLog(timeStart, originalURL, "GET", http.StatusTemporaryRedirect, id)
-		http.Redirect(w, r, originalURL, http.StatusTemporaryRedirect)
-	} else {
-		originalURL, ok := storage.Store.Get(id)
-		if !ok || originalURL == "" {
-			http.Error(w, "URL not found", http.StatusBadRequest)
-			logger.Logging.WriteToLog(timeStart, "", "GET", http.StatusBadRequest, "URL not found")
+		if errors.Is(err, store.ErrDeleted) {
+			http.Error(w, "URL deleted", http.StatusGone)
+			logger.Logging.WriteToLog(timeStart, "", "GET", http.StatusGone, "URL deleted")
 			return
 		}
-		logger.Logging.WriteToLog(timeStart, originalURL, "GET", http.StatusTemporaryRedirect, id)
-		http.Redirect(w, r, originalURL, http.StatusTemporaryRedirect)
+		if errors.Is(err, store.ErrExpired) {
+			http.Error(w, "URL expired", http.StatusGone)
+			logger.Logging.WriteToLog(timeStart, "", "GET", http.StatusGone, "URL expired")
+			return
+		}
+		http.Error(w, "URL not found", http.StatusBadRequest)
+		logger.Logging.WriteToLog(timeStart, "", "GET", http.StatusBadRequest, "URL not found")
+		return
 	}
+	h.clicks.Record(analytics.FromRequest(r, id))
+	logger.Logging.WriteToLog(timeStart, originalURL, "GET", http.StatusTemporaryRedirect, id)
+	http.Redirect(w, r, originalURL, http.StatusTemporaryRedirect)
 }
 
-// GetUserURLs возвращает список ссылок пользователя (короткая ↔ оригинальная).
+// GetUserURLs возвращает страницу ссылок пользователя. Параметры запроса:
+// limit — размер страницы (по умолчанию 100, не больше 1000), cursor — курсор
+// из ссылки rel="next" заголовка Link, sort — created_at или -created_at,
+// q — подстрока исходного URL, domain — домен исходного URL вместе с поддоменами.
 func (h *Handler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserID(r.Context())
+	timeStart := time.Now()
+
+	query := r.URL.Query()
+	opts, err := parseListOptions(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		logger.Logging.WriteToLog(timeStart, "/api/user/urls", "GET", http.StatusBadRequest, err.Error())
+		return
+	}
 
-	urls, err := h.urlService.GetURLsByUserID(r.Context(), userID)
+	page, err := h.urlService.GetURLsByUserID(r.Context(), userID, opts)
 	if err != nil {
+		if handleContextErr(w, r, timeStart, "/api/user/urls") {
+			return
+		}
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 
-	if len(urls) == 0 {
+	if len(page.URLs) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	var response []URLPair
-	for _, u := range urls {
+	response := make([]URLPair, 0, len(page.URLs))
+	for _, u := range page.URLs {
 		response = append(response, URLPair{
 			ShortURL:    config.AppConfig.BaseURL + "/" + u.ID,
 			OriginalURL: u.OriginalURL,
+			ExpiresAt:   u.ExpiresAt,
+			MaxClicks:   u.MaxClicks,
+			Clicks:      u.Clicks,
 		})
 	}
 
+	pageLinks := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(query, ""))}
+	if page.Next != nil {
+		pageLinks = append(pageLinks, fmt.Sprintf(`<%s>; rel="next"`, pageURL(query, page.Next.String())))
+	}
+	w.Header().Set("Link", strings.Join(pageLinks, ", "))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// domainPattern — допустимый фильтр по домену.
+var domainPattern = regexp.MustCompile(`^[a-z0-9-]+(\.[a-z0-9-]+)*$`)
+
+// parseListOptions разбирает параметры пагинации, сортировки и фильтрации списка ссылок.
+func parseListOptions(q url.Values) (store.ListOptions, error) {
+	opts := store.ListOptions{Limit: store.DefaultPageSize}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > store.MaxPageSize {
+			return opts, fmt.Errorf("limit must be between 1 and %d", store.MaxPageSize)
+		}
+		opts.Limit = n
+	}
+	if v := q.Get("cursor"); v != "" {
+		c, err := store.ParseCursor(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.After = c
+	}
+	switch q.Get("sort") {
+	case "", "created_at":
+	case "-created_at":
+		opts.Desc = true
+	default:
+		return opts, errors.New("sort must be created_at or -created_at")
+	}
+	opts.Contains = q.Get("q")
+	if v := strings.ToLower(strings.TrimSpace(q.Get("domain"))); v != "" {
+		if !domainPattern.MatchString(v) {
+			return opts, errors.New("invalid domain")
+		}
+		opts.Domain = v
+	}
+	return opts, nil
+}
+
+// pageURL возвращает адрес страницы списка ссылок с теми же параметрами и курсором cursor.
+func pageURL(q url.Values, cursor string) string {
+	next := url.Values{}
+	for k, v := range q {
+		next[k] = v
+	}
+	next.Del("cursor")
+	if cursor != "" {
+		next.Set("cursor", cursor)
+	}
+	u := config.AppConfig.BaseURL + "/api/user/urls"
+	if len(next) > 0 {
+		u += "?" + next.Encode()
+	}
+	return u
+}
+
 // GzipMiddleware распаковывает входящий gzip и при необходимости сжимает ответ.
 func (h *Handler) GzipMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -375,7 +485,14 @@ func (h *Handler) GzipMiddleware(next http.Handler) http.Handler {
 		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
 			cw := gzip.NewCompressWriter(w)
 			ow = cw
-			defer cw.Close()
+			defer func() {
+				_, span := tracing.Tracer().Start(r.Context(), "gzip.Close")
+				cw.Close()
+				raw, compressed := cw.Sizes()
+				span.SetAttributes(attribute.Int64("gzip.raw_bytes", raw), attribute.Int64("gzip.compressed_bytes", compressed))
+				span.End()
+				metrics.ObserveGzip(raw, compressed)
+			}()
 		}
 
 		next.ServeHTTP(ow, r)
@@ -395,6 +512,7 @@ func (h *Handler) GetDBPing(w http.ResponseWriter, r *http.Request) {
 // DeleteUserURLs помечает на удаление список ссылок пользователя.
 func (h *Handler) DeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserID(r.Context())
+	timeStart := time.Now()
 
 	if r.Header.Get("Content-Type") != "application/json" {
 		http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
@@ -408,76 +526,23 @@ func (h *Handler) DeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	done := make(chan struct{})
-	defer close(done)
-
-	// Генератор
-	idCh := func() <-chan string {
-		ch := make(chan string)
-		go func() {
-			defer close(ch)
-			for _, id := range ids {
-				select {
-				case <-done:
-					return
-				case ch <- id:
-				}
-			}
-		}()
-		return ch
-	}()
-
-	// Рабочий
-	worker := func(idCh <-chan string) <-chan string {
-		out := make(chan string)
-		go func() {
-			defer close(out)
-			for id := range idCh {
-				select {
-				case <-done:
-					return
-				case out <- id:
-				}
-			}
-		}()
-		return out
-	}
-
-	// Fan-out
-	workers := 20
-	var chans []<-chan string
-	for i := 0; i < workers; i++ {
-		chans = append(chans, worker(idCh))
-	}
-
-	// Fan-in
-	merged := make(chan string)
-	var wg sync.WaitGroup
-	wg.Add(len(chans))
-	for _, ch := range chans {
-		go func(c <-chan string) {
-			defer wg.Done()
-			for id := range c {
-				select {
-				case <-done:
-					return
-				case merged <- id:
-				}
+	if h.deletes != nil {
+		if err := h.deletes.Enqueue(ctx, userID, ids); err != nil {
+			if handleContextErr(w, r, timeStart, "/api/user/urls") {
+				return
 			}
-		}(ch)
-	}
-	go func() {
-		wg.Wait()
-		close(merged)
-	}()
-
-	// Сборка результата
-	var batch []string
-	for id := range merged {
-		batch = append(batch, id)
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Failed to enqueue URLs deletion: %s", err)})
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
 	}
 
-	if err := h.urlService.BatchDelete(ctx, batch, userID); err != nil {
+	if err := h.urlService.BatchDelete(ctx, ids, userID); err != nil {
+		if handleContextErr(w, r, timeStart, "/api/user/urls") {
+			return
+		}
 		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Failed to mark URLs as deleted: %s", err)})
 		http.Error(w, fmt.Sprintf("Failed to mark URLs as deleted: %s", err), http.StatusInternalServerError)
 		return
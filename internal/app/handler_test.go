@@ -1 +1,627 @@
 package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/analytics"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/deletion"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/store"
+)
+
+// postJSON выполняет JSON-запрос к обработчику и возвращает ответ.
+func postJSON(t *testing.T, handler http.HandlerFunc, target string, body any) *http.Response {
+	t.Helper()
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w.Result()
+}
+
+func TestPostShortenHandlerAlias(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+
+	tests := []struct {
+		name   string
+		body   map[string]string
+		status int
+		result string
+	}{
+		{name: "created", body: map[string]string{"url": "https://a.example", "alias": "my-promo"}, status: http.StatusCreated, result: "http://localhost:8080/my-promo"},
+		{name: "taken", body: map[string]string{"url": "https://b.example", "alias": "my-promo"}, status: http.StatusConflict},
+		{name: "reserved", body: map[string]string{"url": "https://c.example", "alias": "API"}, status: http.StatusBadRequest},
+		{name: "bad charset", body: map[string]string{"url": "https://c.example", "alias": "a/b/c"}, status: http.StatusBadRequest},
+		{name: "too short", body: map[string]string{"url": "https://c.example", "alias": "ab"}, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := postJSON(t, h.PostShortenHandler, "/api/shorten", tt.body)
+			defer resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+			var out map[string]string
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+			if tt.result != "" {
+				assert.Equal(t, tt.result, out["result"])
+			} else {
+				assert.NotEmpty(t, out["error"])
+			}
+		})
+	}
+}
+
+func TestPostShortenHandlerBatchAlias(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+
+	resp := postJSON(t, h.PostShortenHandlerBatch, "/api/shorten/batch", []map[string]string{
+		{"correlation_id": "1", "original_url": "https://a.example", "alias": "same"},
+		{"correlation_id": "2", "original_url": "https://b.example", "alias": "same"},
+	})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+	var out []map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	require.Len(t, out, 2)
+	assert.Equal(t, "created", out[0]["status"])
+	assert.Equal(t, "invalid", out[1]["status"])
+	assert.Empty(t, out[1]["short_url"])
+	assert.NotEmpty(t, out[1]["error"])
+
+	resp = postJSON(t, h.PostShortenHandlerBatch, "/api/shorten/batch", []map[string]string{
+		{"correlation_id": "1", "original_url": "https://c.example", "alias": "first"},
+		{"correlation_id": "2", "original_url": "https://d.example"},
+	})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	out = nil
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	require.Len(t, out, 2)
+	assert.Equal(t, "http://localhost:8080/first", out[0]["short_url"])
+	assert.NotEmpty(t, out[1]["short_url"])
+}
+
+func TestPostShortenHandlerBatchPartial(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+
+	resp := postJSON(t, h.PostShortenHandlerBatch, "/api/shorten/batch", []map[string]string{
+		{"correlation_id": "1", "original_url": "https://a.example", "alias": "taken"},
+	})
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	tests := []struct {
+		name     string
+		body     []map[string]string
+		status   int
+		statuses []string
+	}{
+		{
+			name: "mixed",
+			body: []map[string]string{
+				{"correlation_id": "1", "original_url": "https://b.example"},
+				{"correlation_id": "2", "original_url": "https://a.example"},
+				{"correlation_id": "3", "original_url": "not a url"},
+				{"correlation_id": "4", "original_url": "https://c.example", "alias": "taken"},
+			},
+			status:   http.StatusMultiStatus,
+			statuses: []string{"created", "exists", "invalid", "invalid"},
+		},
+		{
+			name: "all exist",
+			body: []map[string]string{
+				{"correlation_id": "1", "original_url": "https://a.example"},
+			},
+			status:   http.StatusCreated,
+			statuses: []string{"exists"},
+		},
+		{
+			name: "all invalid",
+			body: []map[string]string{
+				{"correlation_id": "1", "original_url": "ftp:/broken"},
+				{"correlation_id": "2", "original_url": "https://d.example", "alias": "ab"},
+			},
+			status:   http.StatusBadRequest,
+			statuses: []string{"invalid", "invalid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := postJSON(t, h.PostShortenHandlerBatch, "/api/shorten/batch", tt.body)
+			defer resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+
+			var out []map[string]string
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+			require.Len(t, out, len(tt.statuses))
+			for i, st := range tt.statuses {
+				assert.Equal(t, tt.body[i]["correlation_id"], out[i]["correlation_id"])
+				assert.Equal(t, st, out[i]["status"], "item %d", i)
+				if st == "created" || st == "exists" {
+					assert.NotEmpty(t, out[i]["short_url"])
+				} else {
+					assert.NotEmpty(t, out[i]["error"])
+				}
+			}
+		})
+	}
+}
+
+func TestPostShortenHandlerExpiry(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	r := routerForGet(h)
+
+	resp := postJSON(t, h.PostShortenHandler, "/api/shorten", map[string]any{"url": "https://a.example", "alias": "once", "max_clicks": 1})
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	statuses := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/once", nil))
+		statuses = append(statuses, w.Code)
+	}
+	assert.Equal(t, []int{http.StatusTemporaryRedirect, http.StatusGone}, statuses)
+
+	resp = postJSON(t, h.PostShortenHandler, "/api/shorten", map[string]any{"url": "https://b.example", "ttl_seconds": 60, "expires_at": "2099-01-01T00:00:00Z"})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = postJSON(t, h.PostShortenHandler, "/api/shorten", map[string]any{"url": "https://b.example", "expires_at": "2000-01-01T00:00:00Z"})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetURLStatsOwnership(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	stats := analytics.NewMemoryStore()
+	h.clickStats = stats
+
+	ctx := context.WithValue(context.Background(), auth.UserIDKey, "owner")
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "mine", OriginalURL: "https://a.example", UserID: "owner"}))
+	require.NoError(t, stats.AddClicks(ctx, []analytics.Click{{LinkID: "mine", Timestamp: time.Now()}}))
+
+	r := chi.NewRouter()
+	r.Get("/api/user/urls/{id}/stats", h.GetURLStats)
+
+	get := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/mine/stats?bucket=hour", nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, user))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("owner")
+	require.Equal(t, http.StatusOK, w.Code)
+	var out statsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&out))
+	assert.EqualValues(t, 1, out.Total)
+	assert.Len(t, out.Series, 1)
+
+	assert.Equal(t, http.StatusNotFound, get("stranger").Code)
+}
+
+func TestGetInternalStatsTrustedSubnet(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+
+	ctx := context.Background()
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "a1", OriginalURL: "https://a.example", UserID: "u1"}))
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "a2", OriginalURL: "https://b.example", UserID: "u1"}))
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "b1", OriginalURL: "https://c.example", UserID: "u2"}))
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "c1", OriginalURL: "https://d.example", UserID: "u3"}))
+	require.NoError(t, h.urlService.BatchDelete(ctx, []string{"c1"}, "u3"))
+
+	get := func(subnet, realIP string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+		if realIP != "" {
+			req.Header.Set("X-Real-IP", realIP)
+		}
+		w := httptest.NewRecorder()
+		TrustedSubnet(subnet)(http.HandlerFunc(h.GetInternalStats)).ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("10.0.0.0/8", "10.1.2.3")
+	require.Equal(t, http.StatusOK, w.Code)
+	var out internalStatsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&out))
+	assert.Equal(t, internalStatsResponse{URLs: 3, Users: 2}, out)
+
+	assert.Equal(t, http.StatusForbidden, get("10.0.0.0/8", "192.168.0.1").Code)
+	assert.Equal(t, http.StatusForbidden, get("10.0.0.0/8", "").Code)
+	assert.Equal(t, http.StatusForbidden, get("", "10.1.2.3").Code)
+	assert.Equal(t, http.StatusForbidden, get("not-a-cidr", "10.1.2.3").Code)
+}
+
+func TestDeleteUserURLsQueued(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	q, err := deletion.NewQueue(h.urlService, deletion.Options{FlushInterval: time.Hour})
+	require.NoError(t, err)
+	h.deletes = q
+
+	ctx := context.Background()
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "q1", OriginalURL: "https://q1.example", UserID: "owner"}))
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "q2", OriginalURL: "https://q2.example", UserID: "owner"}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewReader([]byte(`["q1","q2"]`)))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, "owner"))
+	w := httptest.NewRecorder()
+	h.DeleteUserURLs(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// удаление выполняется при досбросе очереди
+	require.NoError(t, q.Close(ctx))
+	_, err = h.urlService.GetOriginalURL(ctx, "q1")
+	assert.ErrorIs(t, err, store.ErrDeleted)
+	_, err = h.urlService.GetOriginalURL(ctx, "q2")
+	assert.ErrorIs(t, err, store.ErrDeleted)
+}
+
+// blockingService ждет отмены контекста в GetOriginalURL, имитируя медленную БД.
+type blockingService struct{ noopService }
+
+func (blockingService) GetOriginalURL(ctx context.Context, _ string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+// recordingAccessLogger запоминает коды ответов из access-лога.
+type recordingAccessLogger struct{ codes []int }
+
+func (l *recordingAccessLogger) WriteToLog(_ time.Time, _ string, _ string, code int, _ string) {
+	l.codes = append(l.codes, code)
+}
+
+func TestGetHandlerContextCancellation(t *testing.T) {
+	_, done := setupMemoryApp()
+	defer done()
+	access := &recordingAccessLogger{}
+	logger.Logging = access
+
+	h := &Handler{urlService: blockingService{}}
+	r := chi.NewRouter()
+	r.Use(logger.RequestLogger)
+	r.With(Deadline(20*time.Millisecond)).Get("/{id}", h.GetHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, []int{http.StatusGatewayTimeout, http.StatusGatewayTimeout}, access.codes)
+
+	// клиент отключился: ответ не пишется, в логе 499
+	access.codes = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, []int{logger.StatusClientClosedRequest, logger.StatusClientClosedRequest}, access.codes)
+}
+
+func TestPostShortenBulk(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	config.AppConfig.BulkChunkSize = 2
+
+	body := strings.Join([]string{
+		`{"correlation_id":"1","original_url":"https://a.example"}`,
+		`{"correlation_id":"2","original_url":"https://b.example","alias":"bee"}`,
+		``,
+		`{"correlation_id":"3","original_url":`,
+		`{"correlation_id":"4","original_url":"https://a.example"}`,
+		`{"correlation_id":"5","original_url":"not a url"}`,
+		`{"correlation_id":"6","original_url":"https://` + strings.Repeat("x", 20<<10) + `"}`,
+		`{"correlation_id":"7","original_url":"https://c.example"}`,
+	}, "\n")
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/bulk", &gz)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.GzipMiddleware(http.HandlerFunc(h.PostShortenBulk)).ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	var out []bulkResponseItem
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var item bulkResponseItem
+		require.NoError(t, dec.Decode(&item))
+		out = append(out, item)
+	}
+
+	want := []struct {
+		line   int
+		status string
+	}{
+		{1, "created"}, {2, "created"}, {4, "invalid"}, {5, "exists"}, {6, "invalid"}, {7, "invalid"}, {8, "created"},
+	}
+	require.Len(t, out, len(want))
+	for i, w := range want {
+		assert.Equal(t, w.line, out[i].Line)
+		assert.Equal(t, w.status, out[i].Status, "line %d", w.line)
+	}
+	assert.Equal(t, "http://localhost:8080/bee", out[1].ShortURL)
+	assert.Equal(t, out[0].ShortURL, out[3].ShortURL)
+	assert.Equal(t, "invalid JSON", out[2].Error)
+}
+
+func TestPostShortenBulkContentType(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+
+	resp := postJSON(t, h.PostShortenBulk, "/api/shorten/bulk", []string{})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetUserURLsExport(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	ctx := context.Background()
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "a1", OriginalURL: "https://a.example", UserID: "owner", ExpiresAt: &expires, MaxClicks: 5}))
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "b1", OriginalURL: "https://b.example", UserID: "owner"}))
+	require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: "c1", OriginalURL: "https://c.example", UserID: "other"}))
+	require.NoError(t, h.urlService.BatchDelete(ctx, []string{"b1"}, "owner"))
+
+	export := func(user, format string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export?format="+format, nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, user))
+		w := httptest.NewRecorder()
+		h.GetUserURLsExport(w, req)
+		return w.Result()
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		resp := export("owner", "csv")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Contains(t, resp.Header.Get("Content-Disposition"), `attachment; filename="urls-`)
+		assert.Contains(t, resp.Header.Get("Content-Disposition"), `.csv"`)
+
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, []string{"short_url", "original_url", "created_at", "deleted", "expires_at", "max_clicks", "clicks"}, records[0])
+		byURL := map[string][]string{records[1][0]: records[1], records[2][0]: records[2]}
+		assert.Equal(t, "2030-01-02T03:04:05Z", byURL["http://localhost:8080/a1"][4])
+		assert.Equal(t, "5", byURL["http://localhost:8080/a1"][5])
+		assert.Equal(t, "true", byURL["http://localhost:8080/b1"][3])
+		assert.Empty(t, byURL["http://localhost:8080/b1"][4])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		resp := export("owner", "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var rows []exportRow
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&rows))
+		assert.Len(t, rows, 2)
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		resp := export("owner", "ndjson")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var rows int
+		dec := json.NewDecoder(resp.Body)
+		for dec.More() {
+			var row exportRow
+			require.NoError(t, dec.Decode(&row))
+			assert.NotEmpty(t, row.OriginalURL)
+			assert.False(t, row.CreatedAt.IsZero())
+			rows++
+		}
+		assert.Equal(t, 2, rows)
+	})
+
+	t.Run("empty json", func(t *testing.T) {
+		resp := export("nobody", "json")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var rows []exportRow
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&rows))
+		assert.Empty(t, rows)
+		assert.NotNil(t, rows)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		resp := export("owner", "xml")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Disposition"))
+	})
+}
+
+func TestGetUserURLsPagination(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, u := range []string{"https://a.example/1", "https://b.example/2", "https://sub.a.example/3"} {
+		require.NoError(t, h.urlService.SaveURL(ctx, store.URL{ID: fmt.Sprintf("id%d", i), OriginalURL: u, UserID: "owner", CreatedAt: base.Add(time.Duration(i) * time.Minute)}))
+	}
+
+	list := func(target string) (*http.Response, []URLPair) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, "owner"))
+		w := httptest.NewRecorder()
+		h.GetUserURLs(w, req)
+		resp := w.Result()
+		defer resp.Body.Close()
+		var out []URLPair
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		}
+		return resp, out
+	}
+
+	resp, out := list("/api/user/urls?limit=2&sort=-created_at")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, out, 2)
+	assert.Equal(t, "http://localhost:8080/id2", out[0].ShortURL)
+	assert.Equal(t, "http://localhost:8080/id1", out[1].ShortURL)
+
+	next := regexp.MustCompile(`<([^>]+)>; rel="next"`).FindStringSubmatch(resp.Header.Get("Link"))
+	require.Len(t, next, 2)
+	assert.Contains(t, resp.Header.Get("Link"), `<http://localhost:8080/api/user/urls?limit=2&sort=-created_at>; rel="first"`)
+
+	resp, out = list(strings.TrimPrefix(next[1], "http://localhost:8080"))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, out, 1)
+	assert.Equal(t, "http://localhost:8080/id0", out[0].ShortURL)
+	assert.NotContains(t, resp.Header.Get("Link"), `rel="next"`)
+
+	_, out = list("/api/user/urls?domain=A.example")
+	assert.Len(t, out, 2)
+	_, out = list("/api/user/urls?q=B.EXAMPLE")
+	assert.Len(t, out, 1)
+	resp, _ = list("/api/user/urls?q=nothing")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	for _, bad := range []string{"limit=0", "limit=1001", "cursor=broken", "sort=id", "domain=a%2Fb"} {
+		resp, _ = list("/api/user/urls?" + bad)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, bad)
+	}
+}
+
+func TestAuthPolicies(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	config.AppConfig.JWTTokenExp = time.Hour
+
+	send := func(router http.Handler, method, target string, cookie *http.Cookie) *http.Response {
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"url":"https://example.com/policy"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Result()
+	}
+	garbage := &http.Cookie{Name: "auth_token", Value: "garbage"}
+	router := InitHandlers(h.urlService)
+
+	// пользовательские эндпоинты требуют валидной сессии
+	resp := send(router, http.MethodGet, "/api/user/urls", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer realm="shortener"`, resp.Header.Get("WWW-Authenticate"))
+	assert.Empty(t, resp.Cookies())
+
+	resp = send(router, http.MethodDelete, "/api/user/urls", garbage)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer realm="shortener", error="invalid_token"`, resp.Header.Get("WWW-Authenticate"))
+
+	// сокращение по-прежнему выдает новую сессию
+	resp = send(router, http.MethodPost, "/api/shorten", garbage)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Len(t, resp.Cookies(), 1)
+
+	resp = send(router, http.MethodGet, "/api/user/urls", resp.Cookies()[0])
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// политику группы можно ослабить
+	lenient := InitHandlers(h.urlService, WithAuthPolicy(GroupUser, auth.PolicyOptional))
+	resp = send(lenient, http.MethodGet, "/api/user/urls", nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Len(t, resp.Cookies(), 1)
+}
+
+func TestAuthRefreshAndLogout(t *testing.T) {
+	h, done := setupMemoryApp()
+	defer done()
+	config.AppConfig.JWTTokenExp = time.Hour
+	config.AppConfig.JWTRefreshWindow = 2 * time.Hour
+	auth.SetRevocations(auth.NewMemoryRevocations())
+	router := InitHandlers(h.urlService)
+
+	send := func(method, target, bearer string, cookie *http.Cookie) *http.Response {
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"url":"https://example.com/session"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	resp := send(http.MethodPost, "/api/shorten", "", nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	session := resp.Cookies()[0]
+
+	// токен в окне продления перевыпускается, ссылки остаются у пользователя
+	resp = send(http.MethodGet, "/api/user/urls", "", session)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, resp.Cookies(), 1, "cookie продлена")
+	resp = send(http.MethodGet, "/api/user/urls", "", resp.Cookies()[0])
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// явное продление для клиентов без cookie
+	resp = send(http.MethodPost, "/api/auth/refresh", session.Value, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Cookies())
+	var refreshed refreshResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&refreshed))
+	resp = send(http.MethodGet, "/api/user/urls", refreshed.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// выход отзывает всю сессию
+	resp = send(http.MethodPost, "/api/auth/logout", refreshed.Token, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = send(http.MethodGet, "/api/user/urls", "", session)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = send(http.MethodPost, "/api/auth/refresh", refreshed.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
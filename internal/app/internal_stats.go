@@ -0,0 +1,73 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// internalStatsResponse — ответ GET /api/internal/stats.
+type internalStatsResponse struct {
+	URLs  int `json:"urls"`
+	Users int `json:"users"`
+}
+
+// TrustedSubnet пропускает запрос, только если IP из X-Real-IP входит в подсеть cidr.
+// При пустой или некорректной подсети все запросы получают 403.
+func TrustedSubnet(cidr string) func(http.Handler) http.Handler {
+	var subnet *net.IPNet
+	if cidr = strings.TrimSpace(cidr); cidr != "" {
+		_, parsed, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Invalid trusted subnet %q: %s", cidr, err)})
+		}
+		subnet = parsed
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+			if subnet == nil || ip == nil || !subnet.Contains(ip) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetInternalStats возвращает число сокращенных ссылок и пользователей.
+func (h *Handler) GetInternalStats(w http.ResponseWriter, r *http.Request) {
+	timeStart := time.Now()
+	ctx := r.Context()
+
+	urls, err := h.urlService.CountURLs(ctx)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, "/api/internal/stats") {
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Count URLs ERROR: %s", err)})
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	users, err := h.urlService.CountUsers(ctx)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, "/api/internal/stats") {
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Count users ERROR: %s", err)})
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(internalStatsResponse{URLs: urls, Users: users}); err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Write ERROR: %s", err)})
+	}
+	logger.Logging.WriteToLog(timeStart, "/api/internal/stats", "GET", http.StatusOK, fmt.Sprintf("urls=%d users=%d", urls, users))
+}
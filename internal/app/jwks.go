@@ -0,0 +1,25 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"net/http"
+)
+
+// GetJWKS публикует открытые ключи проверки JWT, чтобы другие сервисы могли
+// проверять выпущенные токены. HMAC-ключи в ответ не попадают.
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	ks, err := auth.Keys()
+	if err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("JWKS ERROR: %s", err)})
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ks.JWKS())
+}
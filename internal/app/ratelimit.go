@@ -0,0 +1,107 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// authRateLimit — сколько попыток входа и регистрации разрешено одному IP за authRateWindow.
+	authRateLimit = 10
+	// authRateWindow — окно ограничения попыток входа и регистрации.
+	authRateWindow = time.Minute
+	// maxTrackedIPs — сколько адресов ограничитель помнит одновременно.
+	maxTrackedIPs = 10000
+)
+
+// ipWindow — число запросов с одного IP в текущем окне.
+type ipWindow struct {
+	start time.Time
+	n     int
+}
+
+// ipLimiter ограничивает число запросов с одного IP за фиксированное окно.
+// Помнит не больше capacity адресов: при переполнении вытесняется окно,
+// начатое раньше всех.
+type ipLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	capacity int
+	hits     map[string]*ipWindow
+	// sweepAt — когда в следующий раз удалить истекшие окна
+	sweepAt time.Time
+}
+
+// allow засчитывает запрос с ip; если лимит исчерпан, возвращает false и
+// время до начала следующего окна.
+func (l *ipLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.After(l.sweepAt) {
+		for k, w := range l.hits {
+			if now.Sub(w.start) >= l.window {
+				delete(l.hits, k)
+			}
+		}
+		l.sweepAt = now.Add(l.window)
+	}
+
+	w, ok := l.hits[ip]
+	if !ok && len(l.hits) >= l.capacity {
+		l.evictOldestLocked()
+	}
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &ipWindow{start: now}
+		l.hits[ip] = w
+	}
+	if w.n >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.n++
+	return true, 0
+}
+
+// evictOldestLocked удаляет окно, начатое раньше всех; вызывается под мьютексом.
+func (l *ipLimiter) evictOldestLocked() {
+	var oldest string
+	var start time.Time
+	for k, w := range l.hits {
+		if oldest == "" || w.start.Before(start) {
+			oldest, start = k, w.start
+		}
+	}
+	delete(l.hits, oldest)
+}
+
+// RateLimit пропускает с одного IP не больше limit запросов за window,
+// остальные отклоняет с 429 и Retry-After. Адрес берется из соединения, а не
+// из заголовков, которые клиент может подменить. Маршруты с одним middleware
+// делят общий лимит.
+func RateLimit(limit int, window time.Duration) func(http.Handler) http.Handler {
+	l := &ipLimiter{limit: limit, window: window, capacity: maxTrackedIPs, hits: make(map[string]*ipWindow)}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, retry := l.allow(remoteIP(r), time.Now()); !ok {
+				w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retry.Seconds()))))
+				writeJSONError(w, http.StatusTooManyRequests, "too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteIP возвращает адрес соединения клиента.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitPerIP(t *testing.T) {
+	h := RateLimit(2, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, send("10.0.0.1").Code)
+	w := send("10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// у другого адреса свой лимит
+	assert.Equal(t, http.StatusOK, send("10.0.0.2").Code)
+}
+
+func TestRateLimitIgnoresSpoofedHeader(t *testing.T) {
+	h := RateLimit(2, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// новый X-Real-IP в каждом запросе не сбрасывает счетчик соединения
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Real-IP", fmt.Sprintf("203.0.113.%d", i))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestIPLimiterCapacity(t *testing.T) {
+	l := &ipLimiter{limit: 1, window: time.Minute, capacity: 2, hits: make(map[string]*ipWindow)}
+	now := time.Now()
+
+	l.allow("a", now)
+	l.allow("b", now.Add(time.Second))
+	l.allow("c", now.Add(2*time.Second))
+
+	// вытеснено самое старое окно, размер не превышает capacity
+	assert.Len(t, l.hits, 2)
+	assert.NotContains(t, l.hits, "a")
+	ok, _ := l.allow("c", now.Add(3*time.Second))
+	assert.False(t, ok)
+}
+
+func TestIPLimiterWindow(t *testing.T) {
+	l := &ipLimiter{limit: 1, window: time.Minute, capacity: maxTrackedIPs, hits: make(map[string]*ipWindow)}
+	now := time.Now()
+
+	ok, _ := l.allow("a", now)
+	assert.True(t, ok)
+	ok, retry := l.allow("a", now.Add(20*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 40*time.Second, retry)
+
+	// в новом окне лимит восстанавливается, а истекшие окна удаляются
+	ok, _ = l.allow("a", now.Add(time.Minute))
+	assert.True(t, ok)
+	l.allow("b", now.Add(3*time.Minute))
+	assert.Len(t, l.hits, 1)
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"net/http"
+	"time"
+)
+
+// refreshResponse — ответ POST /api/auth/refresh.
+type refreshResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PostAuthRefresh перевыпускает JWT текущей сессии с тем же пользователем для
+// клиентов без cookie. Если вход был по cookie, она тоже обновляется. После
+// окончания абсолютного срока сессии отвечает 401.
+func (h *Handler) PostAuthRefresh(w http.ResponseWriter, r *http.Request) {
+	timeStart := time.Now()
+	claims := auth.GetClaims(r.Context())
+	if claims == nil {
+		writeJSONError(w, http.StatusForbidden, "token refresh requires a JWT")
+		logger.Logging.WriteToLog(timeStart, "/api/auth/refresh", "POST", http.StatusForbidden, "Not a JWT")
+		return
+	}
+
+	token, exp, err := auth.Refresh(claims)
+	if errors.Is(err, auth.ErrSessionExpired) {
+		auth.Unauthorized(w, true)
+		logger.Logging.WriteToLog(timeStart, "/api/auth/refresh", "POST", http.StatusUnauthorized, "Session expired")
+		return
+	}
+	if err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Token refresh ERROR: %s", err)})
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("Authorization") == "" {
+		auth.SetSessionCookie(w, token, exp)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(refreshResponse{Token: token, ExpiresAt: exp.UTC()})
+	logger.Logging.WriteToLog(timeStart, "/api/auth/refresh", "POST", http.StatusOK, "Refreshed")
+}
+
+// PostAuthLogout завершает текущую сессию: ее токены, включая уже продленные,
+// больше не принимаются, а cookie удаляется.
+func (h *Handler) PostAuthLogout(w http.ResponseWriter, r *http.Request) {
+	timeStart := time.Now()
+	claims := auth.GetClaims(r.Context())
+	if claims == nil {
+		writeJSONError(w, http.StatusForbidden, "logout requires a JWT")
+		logger.Logging.WriteToLog(timeStart, "/api/auth/logout", "POST", http.StatusForbidden, "Not a JWT")
+		return
+	}
+
+	if err := auth.Logout(r.Context(), claims); err != nil {
+		if handleContextErr(w, r, timeStart, "/api/auth/logout") {
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Logout ERROR: %s", err)})
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	auth.ClearSessionCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+	logger.Logging.WriteToLog(timeStart, "/api/auth/logout", "POST", http.StatusNoContent, "Logged out")
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/zauremazhikovayandex/url/internal/analytics"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"net/http"
+	"time"
+)
+
+// statsResponse — ответ эндпоинта статистики ссылки.
+type statsResponse struct {
+	ID     string            `json:"id"`
+	Total  int64             `json:"total"`
+	Bucket string            `json:"bucket"`
+	From   time.Time         `json:"from"`
+	To     time.Time         `json:"to"`
+	Series []analytics.Point `json:"series"`
+}
+
+// defaultStatsWindow — интервал статистики по умолчанию для каждого шага ряда.
+var defaultStatsWindow = map[analytics.Granularity]time.Duration{
+	analytics.Hour: 24 * time.Hour,
+	analytics.Day:  30 * 24 * time.Hour,
+}
+
+// parseTimeParam разбирает необязательный параметр запроса в формате RFC 3339.
+func parseTimeParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be RFC 3339 timestamp", name)
+	}
+	return t, nil
+}
+
+// GetURLStats возвращает статистику переходов по ссылке текущего пользователя:
+// общее число и временной ряд с шагом bucket=hour|day за интервал [from, to).
+func (h *Handler) GetURLStats(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserID(r.Context())
+	id := chi.URLParam(r, "id")
+	timeStart := time.Now()
+
+	u, err := h.urlService.GetURL(r.Context(), id)
+	if err != nil && !errors.Is(err, store.ErrDeleted) && !errors.Is(err, store.ErrExpired) {
+		if errors.Is(err, store.ErrNotFound) {
+			writeJSONError(w, http.StatusNotFound, "URL not found")
+			return
+		}
+		if handleContextErr(w, r, timeStart, r.URL.Path) {
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Stats lookup ERROR: %s", err)})
+		writeJSONError(w, http.StatusInternalServerError, "Server error")
+		return
+	}
+	// чужая ссылка неотличима от несуществующей
+	if u.UserID != userID {
+		writeJSONError(w, http.StatusNotFound, "URL not found")
+		return
+	}
+
+	g, err := analytics.ParseGranularity(r.URL.Query().Get("bucket"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := parseTimeParam(r, "to", time.Now().UTC())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	from, err := parseTimeParam(r, "from", to.Add(-defaultStatsWindow[g]))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !from.Before(to) {
+		writeJSONError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	stats, err := h.clickStats.Stats(r.Context(), id, g, from, to)
+	if err != nil {
+		if handleContextErr(w, r, timeStart, r.URL.Path) {
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Stats ERROR: %s", err)})
+		writeJSONError(w, http.StatusInternalServerError, "Server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statsResponse{
+		ID:     id,
+		Total:  stats.Total,
+		Bucket: string(g),
+		From:   from.UTC(),
+		To:     to.UTC(),
+		Series: stats.Series,
+	})
+}
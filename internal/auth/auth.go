@@ -5,29 +5,194 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"github.com/zauremazhikovayandex/url/internal/config"
+	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+var (
+	// ErrSessionExpired — абсолютный срок сессии истек, токен больше не продлевается.
+	ErrSessionExpired = errors.New("session expired")
+	// ErrRevoked — сессия токена завершена выходом.
+	ErrRevoked = errors.New("session revoked")
+	// ErrRevocationUnavailable — не удалось проверить, не отозвана ли сессия.
+	ErrRevocationUnavailable = errors.New("revocation list unavailable")
+)
+
 // Claims - UserID
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID string
+	// SessionID — идентификатор сессии, общий для всех продлений токена.
+	SessionID string `json:"sid,omitempty"`
+	// SessionStart — начало сессии; от него отсчитывается JWTSessionMaxAge.
+	SessionStart *jwt.NumericDate `json:"sst,omitempty"`
 }
 
-// GenerateToken - Генерация JWT токена
+// GenerateToken - Генерация JWT токена текущим ключом подписи; токен открывает новую сессию.
 func GenerateToken(userID string) (string, error) {
-	conf := config.AppConfig
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+	token, _, err := NewSession(userID)
+	return token, err
+}
+
+// NewSession открывает новую сессию userID и возвращает ее первый токен и срок его действия.
+func NewSession(userID string) (token string, exp time.Time, err error) {
+	return issue(userID, newSessionID(), time.Now())
+}
+
+// issue подписывает токен сессии sessionID, начатой в start. Срок токена —
+// JWTTokenExp, но не дальше конца сессии.
+func issue(userID, sessionID string, start time.Time) (string, time.Time, error) {
+	ks, err := Keys()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	exp := now.Add(config.AppConfig.JWTTokenExp)
+	if end, ok := sessionEnd(start); ok && end.Before(exp) {
+		exp = end
+	}
+	token, err := ks.Sign(Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(conf.JWTTokenExp)),
+			ExpiresAt: jwt.NewNumericDate(exp),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
-		UserID: userID,
+		UserID:       userID,
+		SessionID:    sessionID,
+		SessionStart: jwt.NewNumericDate(start),
+	})
+	return token, exp, err
+}
+
+// sessionEnd возвращает момент окончания сессии, начатой в start;
+// ok == false, если срок сессии не ограничен.
+func sessionEnd(start time.Time) (time.Time, bool) {
+	if config.AppConfig.JWTSessionMaxAge <= 0 {
+		return time.Time{}, false
+	}
+	return start.Add(config.AppConfig.JWTSessionMaxAge), true
+}
+
+// ParseClaims проверяет подпись и срок действия JWT и то, что его сессия не
+// отозвана. Ключ проверки выбирается по kid из заголовка токена.
+func ParseClaims(ctx context.Context, tokenStr string) (*Claims, error) {
+	ks, err := Keys()
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, ks.keyfunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.UserID == "" {
+		return nil, errors.New("invalid token")
+	}
+	if claims.SessionID != "" {
+		revoked, err := Revocations().IsRevoked(ctx, claims.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrRevocationUnavailable, err)
+		}
+		if revoked {
+			return nil, ErrRevoked
+		}
+	}
+	return claims, nil
+}
+
+// ParseToken проверяет JWT так же, как ParseClaims, и возвращает userID из него.
+func ParseToken(tokenStr string) (string, error) {
+	claims, err := ParseClaims(context.Background(), tokenStr)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+// NeedsRefresh сообщает, что до истечения токена осталось меньше
+// JWTRefreshWindow и сессия позволяет его продлить.
+func NeedsRefresh(c *Claims) bool {
+	window := config.AppConfig.JWTRefreshWindow
+	if window <= 0 || c.ExpiresAt == nil || time.Until(c.ExpiresAt.Time) > window {
+		return false
+	}
+	if c.SessionStart == nil {
+		return true
+	}
+	end, ok := sessionEnd(c.SessionStart.Time)
+	return !ok || c.ExpiresAt.Time.Before(end)
+}
+
+// Refresh выпускает новый токен той же сессии и того же пользователя. Токены,
+// выпущенные до появления сессий, получают новую сессию.
+func Refresh(c *Claims) (token string, exp time.Time, err error) {
+	sessionID, start := c.SessionID, time.Now()
+	if sessionID == "" || c.SessionStart == nil {
+		sessionID = newSessionID()
+	} else {
+		start = c.SessionStart.Time
+	}
+	if end, ok := sessionEnd(start); ok && !time.Now().Before(end) {
+		return "", time.Time{}, ErrSessionExpired
+	}
+	return issue(c.UserID, sessionID, start)
+}
+
+// Logout отзывает сессию токена: все ее токены, включая продленные, перестают
+// приниматься. Запись об отзыве хранится, пока не истечет последний выпущенный
+// токен, а для сессий с ограниченным сроком — до конца сессии.
+func Logout(ctx context.Context, c *Claims) error {
+	if c.SessionID == "" {
+		return nil
+	}
+	until := time.Now().Add(config.AppConfig.JWTTokenExp)
+	if c.ExpiresAt != nil && c.ExpiresAt.Time.After(until) {
+		until = c.ExpiresAt.Time
+	}
+	if c.SessionStart != nil {
+		if end, ok := sessionEnd(c.SessionStart.Time); ok && end.After(until) {
+			until = end
+		}
+	}
+	return Revocations().Revoke(ctx, c.SessionID, until)
+}
+
+// NewUser создает нового анонимного пользователя и выпускает для него JWT.
+func NewUser() (userID string, token string, err error) {
+	userID = generateUserID()
+	token, err = GenerateToken(userID)
+	return userID, token, err
+}
+
+// SetSessionCookie сохраняет токен в cookie сессии.
+func SetSessionCookie(w http.ResponseWriter, token string, exp time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.AppConfig.JWTCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  exp,
+		HttpOnly: true,
 	})
+}
+
+// ClearSessionCookie удаляет cookie сессии.
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.AppConfig.JWTCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
 
-	return token.SignedString([]byte(conf.JWTSecretKey))
+// WithUserID возвращает контекст с userID.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, UserIDKey, userID)
 }
 
 // GetUserID - Получить userID
@@ -36,9 +201,33 @@ func GetUserID(ctx context.Context) string {
 	return userID
 }
 
+// claimsKey — ключ контекста с claims JWT запроса.
+const claimsKey key = "claims"
+
+// WithClaims возвращает контекст с claims JWT, по которому вошел пользователь.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, c)
+}
+
+// GetClaims возвращает claims JWT запроса; nil, если вход был не по JWT.
+func GetClaims(ctx context.Context) *Claims {
+	c, _ := ctx.Value(claimsKey).(*Claims)
+	return c
+}
+
+// NewUserID генерирует идентификатор нового пользователя.
+func NewUserID() string {
+	return generateUserID()
+}
+
 // Генерация userID (можно UUID, а пока — random base64)
 func generateUserID() string {
 	b := make([]byte, 16)
 	rand.Read(b)
 	return base64.URLEncoding.EncodeToString(b)
 }
+
+// newSessionID генерирует идентификатор сессии того же вида, что и userID.
+func newSessionID() string {
+	return generateUserID()
+}
@@ -3,44 +3,211 @@ package auth
 
 import (
 	"context"
-	"github.com/golang-jwt/jwt/v4"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/tracing"
 )
 
-// Middleware — middleware, добавляющий userID в контекст запроса из cookie/JWT.
+// bearerPrefix — схема заголовка Authorization.
+const bearerPrefix = "Bearer "
+
+// realm — область защиты в заголовке WWW-Authenticate.
+const realm = "shortener"
+
+// ErrNotAPIKey — строка не похожа на API-ключ; ее нужно проверять как JWT.
+var ErrNotAPIKey = errors.New("not an api key")
+
+// KeyAuthenticator проверяет API-ключ и возвращает его владельца и права.
+// Для строк другого формата возвращает ErrNotAPIKey.
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (userID string, scopes []Scope, err error)
+}
+
+// Policy — требование маршрута к аутентификации.
+type Policy int
+
+const (
+	// PolicyOptional — без валидных учетных данных в cookie создается новый
+	// анонимный пользователь.
+	PolicyOptional Policy = iota
+	// PolicyRequired — без валидных учетных данных запрос отклоняется с 401.
+	PolicyRequired
+	// PolicyNone — запрос проходит как есть: пользователь, если он определен,
+	// остается в контексте, но новый анонимный пользователь не создается.
+	PolicyNone
+)
+
+// Middleware возвращает middleware, применяющий политику к запросу, уже
+// прошедшему Identify.
+func (p Policy) Middleware() func(http.Handler) http.Handler {
+	switch p {
+	case PolicyRequired:
+		return Required
+	case PolicyNone:
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return Optional
+}
+
+// invalidKey — ключ контекста: клиент передал учетные данные, но они не прошли проверку.
+const invalidKey key = "invalidCredentials"
+
+// unavailableKey — ключ контекста: учетные данные не удалось проверить, потому
+// что список отозванных сессий недоступен.
+const unavailableKey key = "credentialsUnavailable"
+
+// Middleware — middleware, добавляющий userID в контекст запроса из заголовка
+// Authorization (JWT) или cookie, а без них — нового анонимного пользователя;
+// API-ключи не принимаются.
 func Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		conf := config.AppConfig
-
-		userID := ""
-		c, err := r.Cookie(conf.JWTCookieName)
-		if err == nil && c != nil {
-			tokenStr := c.Value
-			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-				return []byte(conf.JWTSecretKey), nil
-			})
-			if err == nil && token.Valid {
-				userID = claims.UserID
+	return Identify(nil)(Optional(next))
+}
+
+// Identify создает middleware, определяющий пользователя запроса. Заголовок
+// Authorization: Bearer принимает JWT и, если задан keys, API-ключ; неверные
+// учетные данные в заголовке отклоняются с 401 при любой политике маршрута.
+// Без заголовка userID берется из cookie; токен cookie, которому осталось
+// меньше JWTRefreshWindow, незаметно перевыпускается в той же сессии. Если
+// пользователь не определен, в том числе из-за недоступного списка отозванных
+// сессий, контекст остается без userID, а решение принимает политика маршрута.
+// Проверки выполняются в спане auth.Identify, и запрос продолжается с его
+// контекстом.
+func Identify(keys KeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracing.Tracer().Start(r.Context(), "auth.Identify")
+
+			if h := r.Header.Get("Authorization"); h != "" {
+				userID, scopes, claims, err := authenticateHeader(ctx, keys, h)
+				span.End()
+				if errors.Is(err, ErrRevocationUnavailable) {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, unavailableKey, true)))
+					return
+				}
+				if err != nil {
+					Unauthorized(w, true)
+					return
+				}
+				ctx = WithUserID(ctx, userID)
+				if scopes != nil {
+					ctx = WithScopes(ctx, scopes)
+				}
+				if claims != nil {
+					ctx = WithClaims(ctx, claims)
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			if c, err := r.Cookie(config.AppConfig.JWTCookieName); err == nil && c.Value != "" {
+				claims, err := ParseClaims(ctx, c.Value)
+				switch {
+				case errors.Is(err, ErrRevocationUnavailable):
+					ctx = context.WithValue(ctx, unavailableKey, true)
+				case err != nil:
+					ctx = context.WithValue(ctx, invalidKey, true)
+				default:
+					if NeedsRefresh(claims) {
+						if token, exp, err := Refresh(claims); err == nil {
+							SetSessionCookie(w, token, exp)
+						}
+					}
+					ctx = WithClaims(WithUserID(ctx, claims.UserID), claims)
+				}
 			}
+			span.End()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Optional — политика PolicyOptional: запрос без пользователя получает нового
+// анонимного пользователя и cookie с его токеном. Если учетные данные не удалось
+// проверить, запрос обслуживается как анонимный, но cookie клиента не заменяется.
+func Optional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetUserID(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if unavailable, _ := r.Context().Value(unavailableKey).(bool); unavailable {
+			next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), generateUserID())))
+			return
 		}
 
-		if userID == "" {
-			userID = generateUserID()
-			token, _ := GenerateToken(userID)
-			http.SetCookie(w, &http.Cookie{
-				Name:     conf.JWTCookieName,
-				Value:    token,
-				Path:     "/",
-				Expires:  time.Now().Add(conf.JWTTokenExp),
-				HttpOnly: true,
-			})
+		userID, token, err := NewUser()
+		if err != nil {
+			http.Error(w, "failed to issue token", http.StatusInternalServerError)
+			return
 		}
+		SetSessionCookie(w, token, time.Now().Add(config.AppConfig.JWTTokenExp))
+		next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+	})
+}
 
-		ctx := context.WithValue(r.Context(), UserIDKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+// Required — политика PolicyRequired: запрос без пользователя отклоняется с 401,
+// а если учетные данные не удалось проверить — с 503.
+func Required(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetUserID(r.Context()) == "" {
+			if unavailable, _ := r.Context().Value(unavailableKey).(bool); unavailable {
+				http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			invalid, _ := r.Context().Value(invalidKey).(bool)
+			Unauthorized(w, invalid)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
+
+// Unauthorized отвечает 401 с заголовком WWW-Authenticate по RFC 6750;
+// invalid — учетные данные были переданы, но не прошли проверку.
+func Unauthorized(w http.ResponseWriter, invalid bool) {
+	challenge := fmt.Sprintf("Bearer realm=%q", realm)
+	msg := "authentication required"
+	if invalid {
+		challenge += `, error="invalid_token"`
+		msg = "invalid credentials"
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// authenticateHeader проверяет значение Authorization со схемой Bearer.
+func authenticateHeader(ctx context.Context, keys KeyAuthenticator, header string) (string, []Scope, *Claims, error) {
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", nil, nil, errors.New("unsupported authorization scheme")
+	}
+	return Authenticate(ctx, keys, strings.TrimSpace(header[len(bearerPrefix):]))
+}
+
+// Authenticate проверяет токен как API-ключ, если задан keys, а иначе как JWT.
+// Для JWT scopes == nil: у владельца токена нет ограничений; claims
+// заполняются только для JWT.
+func Authenticate(ctx context.Context, keys KeyAuthenticator, token string) (string, []Scope, *Claims, error) {
+	if keys != nil {
+		userID, scopes, err := keys.Authenticate(ctx, token)
+		if !errors.Is(err, ErrNotAPIKey) {
+			if scopes == nil {
+				scopes = []Scope{}
+			}
+			return userID, scopes, nil, err
+		}
+	}
+	claims, err := ParseClaims(ctx, token)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return claims.UserID, nil, claims, nil
+}
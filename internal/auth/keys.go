@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// minHMACSecret — минимальная длина HMAC-секрета в байтах.
+	minHMACSecret = 32
+	// minRSABits — минимальный размер ключа RSA.
+	minRSABits = 2048
+	// defaultKeyID — kid секрета из JWTSecretKey.
+	defaultKeyID = "default"
+)
+
+// ErrUnknownKey — токен подписан ключом, которого нет среди ключей проверки.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key — ключ подписи или проверки JWT.
+type Key struct {
+	// ID — идентификатор ключа, передается в заголовке kid.
+	ID string
+	// Method — алгоритм подписи: HS256, RS256 или EdDSA.
+	Method jwt.SigningMethod
+
+	sign   interface{}
+	verify interface{}
+}
+
+// CanSign сообщает, может ли ключ подписывать токены.
+func (k *Key) CanSign() bool {
+	return k.sign != nil
+}
+
+// NewHMACKey создает ключ HS256 из секрета.
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < minHMACSecret {
+		return nil, fmt.Errorf("key %q: HMAC secret must be at least %d bytes", id, minHMACSecret)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, sign: secret, verify: secret}, nil
+}
+
+// NewKeyFromPEM создает ключ RS256 или EdDSA из PEM: закрытый ключ (PKCS#1 или
+// PKCS#8) подписывает и проверяет, открытый ключ (PKIX или PKCS#1) только проверяет.
+func NewKeyFromPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM block", id)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("key %q: RSA key must be at least %d bits", id, minRSABits)
+		}
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, sign: k, verify: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("key %q: RSA key must be at least %d bits", id, minRSABits)
+		}
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, verify: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, sign: k, verify: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verify: k}, nil
+	}
+	return nil, fmt.Errorf("key %q: unsupported key type %T", id, parsed)
+}
+
+// KeySet — ключ подписи и все ключи проверки JWT. Старые ключи остаются
+// в наборе для проверки, пока не истекут выпущенные ими токены.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet собирает набор ключей; подписывает ключ signingID, а если он пуст —
+// единственный ключ, способный подписывать.
+func NewKeySet(signingID string, keys ...*Key) (*KeySet, error) {
+	s := &KeySet{keys: make(map[string]*Key, len(keys))}
+	var signers []string
+	for _, k := range keys {
+		if _, ok := s.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		s.keys[k.ID] = k
+		if k.CanSign() {
+			signers = append(signers, k.ID)
+		}
+	}
+
+	if signingID == "" {
+		if len(signers) != 1 {
+			return nil, fmt.Errorf("signing key id is required: %d keys can sign", len(signers))
+		}
+		signingID = signers[0]
+	}
+	k, ok := s.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", signingID)
+	}
+	if !k.CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private part", signingID)
+	}
+	s.signing = k
+	return s, nil
+}
+
+// SigningKeyID возвращает kid ключа подписи.
+func (s *KeySet) SigningKeyID() string {
+	return s.signing.ID
+}
+
+// Sign подписывает claims ключом подписи и проставляет kid.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.sign)
+}
+
+// keyfunc выбирает ключ проверки по kid; токены без kid проверяются ключом подписи.
+// Алгоритм токена должен совпадать с алгоритмом ключа.
+func (s *KeySet) keyfunc(t *jwt.Token) (interface{}, error) {
+	k := s.signing
+	if kid, ok := t.Header["kid"].(string); ok {
+		if k, ok = s.keys[kid]; !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+	return k.verify, nil
+}
+
+// ErrNoKeys — ключи JWT не настроены, а хранилище ссылок переживает перезапуск.
+var ErrNoKeys = errors.New("JWT keys are not configured: set JWT_SECRET or JWT_KEYS_DIR")
+
+// LoadKeys загружает ключи из настроек: секрет JWTSecretKey и файлы каталога
+// JWTKeysDir (<kid>.pem и <kid>.key). Если ключей нет, см. fallbackKey.
+func LoadKeys(cfg *config.Config) (*KeySet, error) {
+	var keys []*Key
+	if cfg.JWTSecretKey != "" {
+		k, err := NewHMACKey(defaultKeyID, []byte(cfg.JWTSecretKey))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	if cfg.JWTKeysDir != "" {
+		entries, err := os.ReadDir(cfg.JWTKeysDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			ext := filepath.Ext(e.Name())
+			if ext != ".pem" && ext != ".key" {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(cfg.JWTKeysDir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			id := strings.TrimSuffix(e.Name(), ext)
+			var k *Key
+			if ext == ".pem" {
+				k, err = NewKeyFromPEM(id, data)
+			} else {
+				k, err = NewHMACKey(id, []byte(strings.TrimSpace(string(data))))
+			}
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		k, err := fallbackKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return NewKeySet(cfg.JWTSigningKeyID, keys...)
+}
+
+// fallbackKey возвращает HMAC-ключ для запуска без настроенных ключей, чтобы
+// пользователи не теряли доступ к своим ссылкам после перезапуска. Для
+// файлового хранилища ключ создается один раз и сохраняется рядом с файлом
+// ссылок; с БД запуск без ключей отклоняется, а в памяти, где ссылки и так не
+// переживают перезапуск, используется временный ключ.
+func fallbackKey(cfg *config.Config) (*Key, error) {
+	switch cfg.StorageType {
+	case "DB":
+		return nil, ErrNoKeys
+	case "File":
+		path := cfg.FileStorage + ".jwt.key"
+		if data, err := os.ReadFile(path); err == nil {
+			logger.Log.Warn(&message.LogMessage{Message: fmt.Sprintf("JWT keys are not configured: using the generated key from %s", path)})
+			return NewHMACKey("generated", []byte(strings.TrimSpace(string(data))))
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		secret, err := newHMACSecret()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(secret), 0600); err != nil {
+			return nil, err
+		}
+		logger.Log.Warn(&message.LogMessage{Message: fmt.Sprintf("JWT keys are not configured: generated a signing key and saved it to %s; keep this file to keep sessions valid", path)})
+		return NewHMACKey("generated", []byte(secret))
+	}
+
+	secret, err := newHMACSecret()
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Warn(&message.LogMessage{Message: "JWT keys are not configured: using a temporary key, tokens will not survive restart"})
+	return NewHMACKey("ephemeral", []byte(secret))
+}
+
+// newHMACSecret генерирует случайный HMAC-секрет в виде base64-строки.
+func newHMACSecret() (string, error) {
+	b := make([]byte, minHMACSecret)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+var (
+	keysMu  sync.RWMutex
+	current *KeySet
+)
+
+// InitKeys загружает ключи из config.AppConfig и делает их текущими. Повторный
+// вызов перечитывает ключи, не прерывая проверку уже выпущенных токенов.
+func InitKeys() error {
+	ks, err := LoadKeys(config.AppConfig)
+	if err != nil {
+		return err
+	}
+	SetKeys(ks)
+	return nil
+}
+
+// ReloadKeys заново читает настройки ключей (config.JWTKeySettings), а не
+// берет их из config.AppConfig, и делает загруженные ключи текущими: так без
+// перезапуска меняются и набор ключей, и ключ подписи.
+func ReloadKeys() error {
+	cfg := *config.AppConfig
+	cfg.JWTSecretKey, cfg.JWTKeysDir, cfg.JWTSigningKeyID = config.JWTKeySettings()
+	ks, err := LoadKeys(&cfg)
+	if err != nil {
+		return err
+	}
+	SetKeys(ks)
+	return nil
+}
+
+// SetKeys делает набор ks текущим.
+func SetKeys(ks *KeySet) {
+	keysMu.Lock()
+	current = ks
+	keysMu.Unlock()
+}
+
+// Keys возвращает текущий набор ключей, при первом обращении загружая его из настроек.
+func Keys() (*KeySet, error) {
+	keysMu.RLock()
+	ks := current
+	keysMu.RUnlock()
+	if ks != nil {
+		return ks, nil
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if current == nil {
+		ks, err := LoadKeys(config.AppConfig)
+		if err != nil {
+			return nil, err
+		}
+		current = ks
+	}
+	return current, nil
+}
+
+// JWK — открытый ключ в формате RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS — набор открытых ключей.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает открытые ключи набора; HMAC-секреты не публикуются.
+func (s *KeySet) JWKS() JWKS {
+	res := JWKS{Keys: []JWK{}}
+	for _, k := range s.keys {
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+		switch pub := k.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		res.Keys = append(res.Keys, jwk)
+	}
+	sort.Slice(res.Keys, func(i, j int) bool { return res.Keys[i].Kid < res.Keys[j].Kid })
+	return res
+}
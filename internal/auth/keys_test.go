@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.New("error")
+	os.Exit(m.Run())
+}
+
+// writePEM сохраняет ключ в каталог dir как <kid>.pem.
+func writePEM(t *testing.T, dir, kid, typ string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600))
+}
+
+func claimsFor(userID string) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		UserID:           userID,
+	}
+}
+
+// parseWith проверяет токен набором ks.
+func parseWith(ks *KeySet, token string) (string, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, ks.keyfunc)
+	return claims.UserID, err
+}
+
+func TestLoadKeysAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writePEM(t, dir, "rsa1", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	writePEM(t, dir, "ed1", "PRIVATE KEY", der)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hs1.key"), []byte("0123456789abcdef0123456789abcdef\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0600))
+
+	for kid, alg := range map[string]string{"rsa1": "RS256", "ed1": "EdDSA", "hs1": "HS256"} {
+		t.Run(alg, func(t *testing.T) {
+			ks, err := LoadKeys(&config.Config{JWTKeysDir: dir, JWTSigningKeyID: kid})
+			require.NoError(t, err)
+
+			token, err := ks.Sign(claimsFor("u1"))
+			require.NoError(t, err)
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, kid, parsed.Header["kid"])
+			assert.Equal(t, alg, parsed.Header["alg"])
+
+			userID, err := parseWith(ks, token)
+			require.NoError(t, err)
+			assert.Equal(t, "u1", userID)
+		})
+	}
+
+	_, err = LoadKeys(&config.Config{JWTKeysDir: dir})
+	assert.Error(t, err, "несколько ключей подписи без JWTSigningKeyID")
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, err := NewHMACKey("2024", []byte("old-secret-0123456789abcdefghijkl"))
+	require.NoError(t, err)
+	newKey, err := NewHMACKey("2025", []byte("new-secret-0123456789abcdefghijkl"))
+	require.NoError(t, err)
+
+	before, err := NewKeySet("2024", oldKey)
+	require.NoError(t, err)
+	token, err := before.Sign(claimsFor("u1"))
+	require.NoError(t, err)
+
+	// новый ключ подписывает, старый еще проверяет
+	during, err := NewKeySet("2025", oldKey, newKey)
+	require.NoError(t, err)
+	userID, err := parseWith(during, token)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", userID)
+
+	// старый ключ выведен из набора
+	after, err := NewKeySet("2025", newKey)
+	require.NoError(t, err)
+	_, err = parseWith(after, token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestReloadKeysRotatesSigningKey(t *testing.T) {
+	withSessionConfig(t, &config.Config{StorageType: "Memory", JWTTokenExp: time.Hour})
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2024.key"), []byte("old-secret-0123456789abcdefghijkl"), 0600))
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_SIGNING_KEY_ID", "")
+
+	require.NoError(t, ReloadKeys())
+	ks, err := Keys()
+	require.NoError(t, err)
+	assert.Equal(t, "2024", ks.SigningKeyID())
+	token, err := ks.Sign(claimsFor("u1"))
+	require.NoError(t, err)
+
+	// новый ключ в каталоге и новый kid подписи применяются без перезапуска
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2025.key"), []byte("new-secret-0123456789abcdefghijkl"), 0600))
+	t.Setenv("JWT_SIGNING_KEY_ID", "2025")
+	require.NoError(t, ReloadKeys())
+	ks, err = Keys()
+	require.NoError(t, err)
+	assert.Equal(t, "2025", ks.SigningKeyID())
+	userID, err := parseWith(ks, token)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", userID)
+}
+
+func TestKeyfuncRejectsAlgorithmMismatch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pub := x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)
+	key, err := NewKeyFromPEM("rsa1", pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: pub}))
+	require.NoError(t, err)
+	assert.False(t, key.CanSign())
+
+	signer, err := NewKeyFromPEM("rsa1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	require.NoError(t, err)
+	ks, err := NewKeySet("", signer)
+	require.NoError(t, err)
+
+	// HS256-токен, подписанный открытым ключом как секретом, не принимается
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsFor("attacker"))
+	forged.Header["kid"] = "rsa1"
+	token, err := forged.SignedString(pub)
+	require.NoError(t, err)
+	_, err = parseWith(ks, token)
+	assert.Error(t, err)
+}
+
+func TestKeyValidation(t *testing.T) {
+	_, err := NewHMACKey("short", []byte("supersecretkey"))
+	assert.Error(t, err)
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = NewKeyFromPEM("small", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(small)}))
+	assert.Error(t, err)
+
+	_, err = NewKeyFromPEM("junk", []byte("not a pem"))
+	assert.Error(t, err)
+}
+
+func TestLoadKeysFallback(t *testing.T) {
+	// с файловым хранилищем сгенерированный ключ переживает перезапуск
+	cfg := &config.Config{StorageType: "File", FileStorage: filepath.Join(t.TempDir(), "urls.json")}
+	first, err := LoadKeys(cfg)
+	require.NoError(t, err)
+	token, err := first.Sign(claimsFor("u1"))
+	require.NoError(t, err)
+
+	second, err := LoadKeys(cfg)
+	require.NoError(t, err)
+	userID, err := parseWith(second, token)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", userID)
+
+	// с БД запуск без ключей отклоняется
+	_, err = LoadKeys(&config.Config{StorageType: "DB"})
+	assert.ErrorIs(t, err, ErrNoKeys)
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaSigner, err := NewKeyFromPEM("rsa1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	require.NoError(t, err)
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(edPub)
+	require.NoError(t, err)
+	edVerifier, err := NewKeyFromPEM("ed1", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	hmacKey, err := NewHMACKey("hs1", []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	ks, err := NewKeySet("rsa1", rsaSigner, edVerifier, hmacKey)
+	require.NoError(t, err)
+
+	jwks := ks.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, JWK{Kty: "OKP", Kid: "ed1", Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: jwks.Keys[0].X}, jwks.Keys[0])
+	assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+	assert.Equal(t, "AQAB", jwks.Keys[1].E)
+	assert.NotEmpty(t, jwks.Keys[1].N)
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/db/postgres"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"sync"
+	"time"
+)
+
+// revocationRefreshInterval — как часто копия списка отозванных сессий
+// перечитывается из БД; так выход на другом экземпляре сервиса становится
+// виден не позже чем через интервал.
+const revocationRefreshInterval = 10 * time.Second
+
+// errRevocationsStale — копия списка давно не обновлялась и могла отстать.
+var errRevocationsStale = errors.New("revocation list is stale")
+
+// RevocationStore — список отозванных сессий.
+type RevocationStore interface {
+	// Revoke отзывает сессию; запись можно забыть после until.
+	Revoke(ctx context.Context, sessionID string, until time.Time) error
+	// IsRevoked сообщает, отозвана ли сессия.
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// NewRevocationStore создает список отозванных сессий в соответствии с
+// config.AppConfig.StorageType. Без БД список ведется в памяти и не переживает
+// перезапуск. С БД проверки идут по копии в памяти, которая обновляется в фоне,
+// пока ctx не отменен.
+func NewRevocationStore(ctx context.Context) RevocationStore {
+	if config.AppConfig.StorageType != "DB" {
+		return NewMemoryRevocations()
+	}
+	c := NewCachedRevocations(&SQLRevocations{}, revocationRefreshInterval)
+	if err := c.Refresh(ctx); err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Revocation list load ERROR: %s", err)})
+	}
+	go c.Run(ctx)
+	return c
+}
+
+var (
+	revocationsMu sync.RWMutex
+	revocations   RevocationStore
+)
+
+// SetRevocations делает store текущим списком отозванных сессий.
+func SetRevocations(store RevocationStore) {
+	revocationsMu.Lock()
+	revocations = store
+	revocationsMu.Unlock()
+}
+
+// Revocations возвращает текущий список отозванных сессий; по умолчанию — в памяти.
+func Revocations() RevocationStore {
+	revocationsMu.RLock()
+	store := revocations
+	revocationsMu.RUnlock()
+	if store != nil {
+		return store
+	}
+
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	if revocations == nil {
+		revocations = NewMemoryRevocations()
+	}
+	return revocations
+}
+
+// MemoryRevocations хранит отозванные сессии в памяти; истекшие записи
+// удаляются при очередном отзыве.
+type MemoryRevocations struct {
+	mu    sync.RWMutex
+	until map[string]time.Time
+}
+
+// NewMemoryRevocations создает пустой список в памяти.
+func NewMemoryRevocations() *MemoryRevocations {
+	return &MemoryRevocations{until: make(map[string]time.Time)}
+}
+
+// Revoke отзывает сессию до until.
+func (m *MemoryRevocations) Revoke(_ context.Context, sessionID string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, t := range m.until {
+		if t.Before(now) {
+			delete(m.until, id)
+		}
+	}
+	if until.After(m.until[sessionID]) {
+		m.until[sessionID] = until
+	}
+	return nil
+}
+
+// IsRevoked сообщает, отозвана ли сессия.
+func (m *MemoryRevocations) IsRevoked(_ context.Context, sessionID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.until[sessionID]
+	return ok, nil
+}
+
+// RevocationSource — общий для экземпляров сервиса список отозванных сессий,
+// копию которого держит CachedRevocations.
+type RevocationSource interface {
+	// Revoke отзывает сессию до until.
+	Revoke(ctx context.Context, sessionID string, until time.Time) error
+	// List возвращает действующие записи списка.
+	List(ctx context.Context) (map[string]time.Time, error)
+}
+
+// CachedRevocations проверяет сессии по копии списка в памяти, не обращаясь к
+// источнику на каждый запрос. Записи хранятся до своего срока; если копию не
+// удается обновить дольше трех интервалов, IsRevoked возвращает ошибку.
+type CachedRevocations struct {
+	source   RevocationSource
+	interval time.Duration
+	mu       sync.RWMutex
+	until    map[string]time.Time
+	loadedAt time.Time
+}
+
+// NewCachedRevocations создает копию списка source, обновляемую раз в interval.
+func NewCachedRevocations(source RevocationSource, interval time.Duration) *CachedRevocations {
+	return &CachedRevocations{source: source, interval: interval, until: make(map[string]time.Time)}
+}
+
+// Refresh перечитывает список из источника. Записи копии, которых в нем еще
+// нет, сохраняются: отзыв не отменяется.
+func (c *CachedRevocations) Refresh(ctx context.Context) error {
+	list, err := c.source.List(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, t := range c.until {
+		if t.After(now) && t.After(list[id]) {
+			list[id] = t
+		}
+	}
+	c.until = list
+	c.loadedAt = now
+	return nil
+}
+
+// Run обновляет копию раз в interval, пока ctx не отменен.
+func (c *CachedRevocations) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Refresh(ctx); err != nil && ctx.Err() == nil {
+				logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Revocation list refresh ERROR: %s", err)})
+			}
+		}
+	}
+}
+
+// Revoke отзывает сессию в источнике и сразу — в копии.
+func (c *CachedRevocations) Revoke(ctx context.Context, sessionID string, until time.Time) error {
+	if err := c.source.Revoke(ctx, sessionID, until); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if until.After(c.until[sessionID]) {
+		c.until[sessionID] = until
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// IsRevoked сообщает, отозвана ли сессия, по копии списка.
+func (c *CachedRevocations) IsRevoked(_ context.Context, sessionID string) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.loadedAt.IsZero() || time.Since(c.loadedAt) > 3*c.interval {
+		return false, errRevocationsStale
+	}
+	t, ok := c.until[sessionID]
+	return ok && t.After(time.Now()), nil
+}
+
+// SQLRevocations хранит отозванные сессии в таблице revoked_sessions PostgreSQL.
+type SQLRevocations struct{}
+
+// List возвращает сессии, отзыв которых еще действует.
+func (s *SQLRevocations) List(ctx context.Context) (map[string]time.Time, error) {
+	return postgres.SelectRevokedSessions(ctx)
+}
+
+// Revoke отзывает сессию до until.
+func (s *SQLRevocations) Revoke(ctx context.Context, sessionID string, until time.Time) error {
+	return postgres.InsertRevokedSession(ctx, sessionID, until)
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// Scope — право на группу операций API.
+type Scope string
+
+// Права доступа. Пользователь, вошедший по cookie или JWT, имеет все права;
+// API-ключ — только выданные ему при создании.
+const (
+	// ScopeRead — чтение ссылок пользователя и их статистики.
+	ScopeRead Scope = "read"
+	// ScopeShorten — создание коротких ссылок.
+	ScopeShorten Scope = "shorten"
+	// ScopeDelete — удаление ссылок пользователя.
+	ScopeDelete Scope = "delete"
+	// ScopeKeys — управление API-ключами; API-ключам не выдается.
+	ScopeKeys Scope = "keys"
+)
+
+// KeyScopes — права, которые можно выдать API-ключу.
+var KeyScopes = []Scope{ScopeRead, ScopeShorten, ScopeDelete}
+
+// ParseScopes проверяет, что каждое право можно выдать API-ключу, и убирает повторы.
+func ParseScopes(names []string) ([]Scope, error) {
+	seen := make(map[Scope]bool, len(names))
+	res := make([]Scope, 0, len(names))
+	for _, name := range names {
+		s := Scope(name)
+		valid := false
+		for _, k := range KeyScopes {
+			valid = valid || s == k
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		if !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+// scopesKey — ключ контекста с правами запроса.
+const scopesKey key = "scopes"
+
+// WithScopes возвращает контекст, ограниченный правами scopes.
+func WithScopes(ctx context.Context, scopes []Scope) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// HasScope сообщает, разрешена ли запросу операция с правом s. Контекст без
+// ограничений (cookie или JWT) разрешает все.
+func HasScope(ctx context.Context, s Scope) bool {
+	scopes, ok := ctx.Value(scopesKey).([]Scope)
+	if !ok {
+		return true
+	}
+	for _, granted := range scopes {
+		if granted == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withSessionConfig подменяет настройки и ключи JWT на время теста.
+func withSessionConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	prevCfg, prevKeys, prevRevocations := config.AppConfig, current, revocations
+	t.Cleanup(func() {
+		config.AppConfig = prevCfg
+		SetKeys(prevKeys)
+		SetRevocations(prevRevocations)
+	})
+
+	key, err := NewHMACKey("test", []byte("session-secret-0123456789abcdefgh"))
+	require.NoError(t, err)
+	ks, err := NewKeySet("test", key)
+	require.NoError(t, err)
+	config.AppConfig = cfg
+	SetKeys(ks)
+	SetRevocations(NewMemoryRevocations())
+}
+
+func TestRefreshKeepsUserAndSession(t *testing.T) {
+	withSessionConfig(t, &config.Config{JWTTokenExp: time.Hour, JWTRefreshWindow: 2 * time.Hour, JWTSessionMaxAge: 24 * time.Hour})
+
+	token, err := GenerateToken("u1")
+	require.NoError(t, err)
+	claims, err := ParseClaims(context.Background(), token)
+	require.NoError(t, err)
+	assert.True(t, NeedsRefresh(claims), "токену осталось меньше окна продления")
+
+	refreshed, exp, err := Refresh(claims)
+	require.NoError(t, err)
+	next, err := ParseClaims(context.Background(), refreshed)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", next.UserID)
+	assert.Equal(t, claims.SessionID, next.SessionID)
+	assert.Equal(t, claims.SessionStart.Unix(), next.SessionStart.Unix())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), exp, time.Minute)
+}
+
+func TestSessionMaxAge(t *testing.T) {
+	withSessionConfig(t, &config.Config{JWTTokenExp: time.Hour, JWTRefreshWindow: 2 * time.Hour, JWTSessionMaxAge: 24 * time.Hour})
+
+	// сессия почти исчерпана: токен продлевается только до ее конца
+	start := time.Now().Add(-24*time.Hour + 10*time.Minute)
+	token, exp, err := issue("u1", "s1", start)
+	require.NoError(t, err)
+	assert.WithinDuration(t, start.Add(24*time.Hour), exp, time.Second)
+
+	claims, err := ParseClaims(context.Background(), token)
+	require.NoError(t, err)
+	assert.False(t, NeedsRefresh(claims), "дальше конца сессии продлевать некуда")
+
+	claims.SessionStart = jwt.NewNumericDate(time.Now().Add(-25 * time.Hour))
+	_, _, err = Refresh(claims)
+	assert.ErrorIs(t, err, ErrSessionExpired)
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	withSessionConfig(t, &config.Config{JWTTokenExp: time.Hour, JWTRefreshWindow: 2 * time.Hour})
+	ctx := context.Background()
+
+	token, err := GenerateToken("u1")
+	require.NoError(t, err)
+	claims, err := ParseClaims(ctx, token)
+	require.NoError(t, err)
+	refreshed, _, err := Refresh(claims)
+	require.NoError(t, err)
+
+	require.NoError(t, Logout(ctx, claims))
+	_, err = ParseClaims(ctx, token)
+	assert.ErrorIs(t, err, ErrRevoked)
+	_, err = ParseClaims(ctx, refreshed)
+	assert.ErrorIs(t, err, ErrRevoked, "продленные токены сессии тоже отозваны")
+
+	other, err := GenerateToken("u1")
+	require.NoError(t, err)
+	_, err = ParseClaims(ctx, other)
+	assert.NoError(t, err, "другие сессии пользователя не затронуты")
+}
+
+// flakySource — источник списка отзывов, который можно «выключить».
+type flakySource struct {
+	revoked map[string]time.Time
+	down    bool
+	lists   int
+}
+
+func (s *flakySource) Revoke(_ context.Context, sessionID string, until time.Time) error {
+	if s.down {
+		return errors.New("db is down")
+	}
+	s.revoked[sessionID] = until
+	return nil
+}
+
+func (s *flakySource) List(context.Context) (map[string]time.Time, error) {
+	s.lists++
+	if s.down {
+		return nil, errors.New("db is down")
+	}
+	res := make(map[string]time.Time, len(s.revoked))
+	for id, t := range s.revoked {
+		res[id] = t
+	}
+	return res, nil
+}
+
+func TestCachedRevocations(t *testing.T) {
+	ctx := context.Background()
+	src := &flakySource{revoked: map[string]time.Time{"old": time.Now().Add(time.Hour)}}
+	c := NewCachedRevocations(src, time.Hour)
+
+	_, err := c.IsRevoked(ctx, "old")
+	assert.Error(t, err, "до первой загрузки список неизвестен")
+
+	require.NoError(t, c.Refresh(ctx))
+	require.NoError(t, c.Revoke(ctx, "new", time.Now().Add(time.Hour)))
+	for _, id := range []string{"old", "new"} {
+		revoked, err := c.IsRevoked(ctx, id)
+		require.NoError(t, err)
+		assert.True(t, revoked, id)
+	}
+	revoked, err := c.IsRevoked(ctx, "other")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	assert.Equal(t, 1, src.lists, "проверки не обращаются к источнику")
+
+	// при недоступном источнике проверки идут по последней копии
+	src.down = true
+	assert.Error(t, c.Refresh(ctx))
+	revoked, err = c.IsRevoked(ctx, "new")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+// downRevocations — список отзывов, который нельзя проверить.
+type downRevocations struct{}
+
+func (downRevocations) Revoke(context.Context, string, time.Time) error { return errors.New("down") }
+func (downRevocations) IsRevoked(context.Context, string) (bool, error) {
+	return false, errors.New("down")
+}
+
+func TestIdentifyFailsOpenOnOptionalRoutes(t *testing.T) {
+	withSessionConfig(t, &config.Config{JWTTokenExp: time.Hour, JWTCookieName: "token"})
+	token, err := GenerateToken("u1")
+	require.NoError(t, err)
+	SetRevocations(downRevocations{})
+
+	var seen string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetUserID(r.Context())
+	})
+	serve := func(policy Policy) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		w := httptest.NewRecorder()
+		Identify(nil)(policy.Middleware()(handler)).ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	resp := serve(PolicyOptional)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, seen)
+	assert.NotEqual(t, "u1", seen, "запрос обслужен как анонимный")
+	assert.Empty(t, resp.Cookies(), "cookie клиента не заменяется")
+
+	resp = serve(PolicyRequired)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+// spanKeys — KeyAuthenticator, запоминающий спан из контекста проверки.
+type spanKeys struct{ seen *trace.SpanContext }
+
+func (k spanKeys) Authenticate(ctx context.Context, _ string) (string, []Scope, error) {
+	*k.seen = trace.SpanContextFromContext(ctx)
+	return "u1", []Scope{ScopeRead}, nil
+}
+
+func TestIdentifyUsesSpanContext(t *testing.T) {
+	withSessionConfig(t, &config.Config{JWTTokenExp: time.Hour, JWTCookieName: "token"})
+	rec := tracetest.NewSpanRecorder()
+	prevTP := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	defer otel.SetTracerProvider(prevTP)
+
+	var lookup, handler trace.SpanContext
+	var userID string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler = trace.SpanContextFromContext(r.Context())
+		userID = GetUserID(r.Context())
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer key")
+	Identify(spanKeys{seen: &lookup})(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	// проверка ключа и обработчик видят контекст спана auth.Identify
+	spans := rec.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "auth.Identify", spans[0].Name())
+	assert.Equal(t, spans[0].SpanContext(), lookup)
+	assert.Equal(t, spans[0].SpanContext(), handler)
+	assert.Equal(t, "u1", userID)
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[string, int](2, time.Minute)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	_, _ = c.Get("a")
+	c.Add("c", 3)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRUExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRU[string, int](10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add("ttl", 1)
+	c.AddUntil("until", 2, now.Add(10*time.Second))
+
+	now = now.Add(30 * time.Second)
+	_, ok := c.Get("ttl")
+	assert.True(t, ok)
+	_, ok = c.Get("until")
+	assert.False(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get("ttl")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
@@ -16,6 +16,8 @@ import (
 var (
 	AppConfig *Config
 	once      sync.Once
+	// configPath — путь к JSON-файлу конфигурации, заданный при InitConfig.
+	configPath string
 )
 
 // Config описывает параметры HTTP-сервера, хранилища и авторизации.
@@ -26,10 +28,69 @@ type Config struct {
 	FileStorage    string
 	PGConfig       *PostgresConfig
 	StorageType    string
-	JWTSecretKey   string
-	JWTTokenExp    time.Duration
-	JWTCookieName  string
-	EnableHTTPS    bool
+	// JWTSecretKey — HMAC-секрет JWT с kid "default" (не короче 32 байт). Если не
+	// задан ни он, ни JWTKeysDir, с файловым хранилищем ключ генерируется и
+	// сохраняется рядом с файлом ссылок, с БД запуск завершается ошибкой, а в
+	// памяти используется временный ключ.
+	JWTSecretKey string
+	// JWTKeysDir — каталог ключей JWT: <kid>.pem — RSA или Ed25519 (закрытый ключ
+	// подписывает и проверяет, открытый только проверяет), <kid>.key — HMAC-секрет.
+	JWTKeysDir string
+	// JWTSigningKeyID — kid ключа подписи; обязателен, если подписывать может
+	// больше одного ключа.
+	JWTSigningKeyID string
+	// JWKSEnabled включает публикацию открытых ключей на /.well-known/jwks.json.
+	JWKSEnabled bool
+	JWTTokenExp time.Duration
+	// JWTRefreshWindow — за сколько до истечения токен из cookie перевыпускается
+	// при очередном запросе.
+	JWTRefreshWindow time.Duration
+	// JWTSessionMaxAge — абсолютное время жизни сессии: дальше него токены не продлеваются.
+	JWTSessionMaxAge time.Duration
+	JWTCookieName    string
+	EnableHTTPS      bool
+	// FileSyncPolicy — политика fsync журнала файлового хранилища: always, interval, never.
+	FileSyncPolicy string
+	// FileCompactInterval — период компакции журнала в снимок.
+	FileCompactInterval time.Duration
+	// ReaperInterval — период удаления истекших ссылок; 0 отключает очистку.
+	ReaperInterval time.Duration
+	// GRPCAddr — адрес gRPC-сервера.
+	GRPCAddr string
+	// TrustedSubnet — CIDR, из которого доступны внутренние эндпоинты; пусто — недоступны.
+	TrustedSubnet string
+	// TraceExporter — куда отправлять спаны: none, stdout, file, otlp.
+	TraceExporter string
+	// TraceFile — файл для экспортера file.
+	TraceFile string
+	// RequestTimeout — дедлайн обработки HTTP-запроса по умолчанию; 0 — без дедлайна.
+	RequestTimeout time.Duration
+	// RouteTimeouts — дедлайны отдельных маршрутов по ключу "METHOD pattern", например "GET /{id}".
+	RouteTimeouts map[string]time.Duration
+	// DeleteWorkers — число воркеров очереди удаления.
+	DeleteWorkers int
+	// DeleteBatchSize — максимальный размер пачки удаления.
+	DeleteBatchSize int
+	// DeleteFlushInterval — максимальное ожидание неполной пачки удаления.
+	DeleteFlushInterval time.Duration
+	// DeleteQueueSize — емкость буфера очереди удаления.
+	DeleteQueueSize int
+	// DeleteQueueFile — файл для сохранения незавершенных удалений; пусто — без сохранения.
+	DeleteQueueFile string
+	// CacheSize — число записей кэша коротких ссылок; 0 — кэш выключен.
+	CacheSize int
+	// CacheTTL — время жизни записи кэша.
+	CacheTTL time.Duration
+	// IDStrategy — стратегия генерации коротких id: random, counter, hash, sqids.
+	IDStrategy string
+	// IDLength — длина id для random и hash, минимальная длина для sqids.
+	IDLength int
+	// IDSalt — соль перестановки алфавита для sqids.
+	IDSalt string
+	// DedupScope — область уникальности исходного URL: global (по умолчанию), user или none.
+	DedupScope string
+	// BulkChunkSize — число строк потокового импорта, сохраняемых одной пачкой.
+	BulkChunkSize int
 }
 
 // PostgresConfig описывает параметры подключения к PostgreSQL.
@@ -70,6 +131,61 @@ func pickBool(flag *boolFlag, envPtr *bool, filePtr *bool, def bool) bool {
 	}
 }
 
+// parseDuration разбирает длительность вида "30s"; при ошибке возвращает def.
+func parseDuration(v string, def time.Duration) time.Duration {
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		fmt.Println("config: invalid duration", v, err)
+		return def
+	}
+	return d
+}
+
+// parseInt разбирает целое число; при ошибке возвращает def.
+func parseInt(v string, def int) int {
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		fmt.Println("config: invalid number", v, err)
+		return def
+	}
+	return n
+}
+
+// pickInt выбирает число по приоритету env > файл > def; некорректное
+// значение env заменяется def.
+func pickInt(envVal string, filePtr *int, def int) int {
+	if envVal == "" && filePtr != nil {
+		return *filePtr
+	}
+	return parseInt(envVal, def)
+}
+
+// parseRouteTimeouts разбирает список вида "GET /{id}=500ms,POST /api/shorten/batch=30s";
+// некорректные элементы пропускаются.
+func parseRouteTimeouts(v string) map[string]time.Duration {
+	res := make(map[string]time.Duration)
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		route, dur, ok := strings.Cut(item, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(dur))
+		if !ok || err != nil {
+			fmt.Println("config: invalid route timeout", item)
+			continue
+		}
+		res[strings.Join(strings.Fields(route), " ")] = d
+	}
+	return res
+}
+
 // jsonConfig — структура для чтения настроек из JSON-файла конфигурации.
 type jsonConfig struct {
 	ServerAddress *string `json:"server_address"`
@@ -77,6 +193,33 @@ type jsonConfig struct {
 	FileStorage   *string `json:"file_storage_path"`
 	DatabaseDSN   *string `json:"database_dsn"`
 	EnableHTTPS   *bool   `json:"enable_https"`
+	FileSync      *string `json:"file_storage_fsync"`
+	FileCompact   *string `json:"file_storage_compact_interval"`
+	Reaper        *string `json:"reaper_interval"`
+	GRPCAddress   *string `json:"grpc_address"`
+	TrustedSubnet *string `json:"trusted_subnet"`
+	TraceExporter *string `json:"trace_exporter"`
+	TraceFile     *string `json:"trace_file"`
+	Timeout       *string `json:"request_timeout"`
+	RouteTimeouts *string `json:"route_timeouts"`
+	DeleteWorkers *int    `json:"delete_workers"`
+	DeleteBatch   *int    `json:"delete_batch_size"`
+	DeleteFlush   *string `json:"delete_flush_interval"`
+	DeleteQueue   *int    `json:"delete_queue_size"`
+	DeleteFile    *string `json:"delete_queue_file"`
+	CacheSize     *int    `json:"cache_size"`
+	CacheTTL      *string `json:"cache_ttl"`
+	IDStrategy    *string `json:"id_strategy"`
+	IDLength      *int    `json:"id_length"`
+	IDSalt        *string `json:"id_salt"`
+	DedupScope    *string `json:"dedup_scope"`
+	BulkChunkSize *int    `json:"bulk_chunk_size"`
+	JWTSecret     *string `json:"jwt_secret"`
+	JWTKeysDir    *string `json:"jwt_keys_dir"`
+	JWTSigningKey *string `json:"jwt_signing_key_id"`
+	JWKSEnabled   *bool   `json:"jwks_enabled"`
+	JWTRefresh    *string `json:"jwt_refresh_window"`
+	JWTSessionAge *string `json:"jwt_session_max_age"`
 }
 
 // boolFlag — вспомогательный тип для булевых флагов с приоритетом "задан/не задан".
@@ -107,6 +250,42 @@ func (b *boolFlag) String() string {
 // IsBoolFlag отмечает флаг как булев, позволяя писать -s без значения.
 func (b *boolFlag) IsBoolFlag() bool { return true }
 
+// readConfigFile читает JSON-файл конфигурации; пустой path — файла нет.
+// Как и раньше, файл разбирается мягко: при ошибке применяются поля,
+// разобранные до нее, а ошибка выводится.
+func readConfigFile(path string) jsonConfig {
+	var fileCfg jsonConfig
+	if path == "" {
+		return fileCfg
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("config: cannot read file:", err)
+		return fileCfg
+	}
+	if err := json.Unmarshal(data, &fileCfg); err != nil {
+		fmt.Println("config: cannot parse file, applying the fields that parsed:", err)
+	}
+	return fileCfg
+}
+
+// JWTKeySettings заново читает настройки ключей JWT из окружения и файла
+// конфигурации с тем же приоритетом, что и InitConfig: секрет, каталог ключей
+// и kid ключа подписи. Используется при перечитывании ключей без перезапуска.
+func JWTKeySettings() (secret, keysDir, signingKeyID string) {
+	fileCfg := readConfigFile(configPath)
+	pick := func(env string, filePtr *string) string {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+		if filePtr != nil {
+			return *filePtr
+		}
+		return ""
+	}
+	return pick("JWT_SECRET", fileCfg.JWTSecret), pick("JWT_KEYS_DIR", fileCfg.JWTKeysDir), pick("JWT_SIGNING_KEY_ID", fileCfg.JWTSigningKey)
+}
+
 // InitConfig инициализирует конфигурацию из флагов и переменных окружения.
 func InitConfig() {
 	once.Do(func() {
@@ -118,6 +297,8 @@ func InitConfig() {
 		baseURLFlag := flag.String("b", "", "base URL for short links")
 		fileStorageFlag := flag.String("f", "", "file storage")
 		dbConnFlag := flag.String("d", "", "postgres connection")
+		grpcAddrFlag := flag.String("g", "", "address to run gRPC server")
+		trustedSubnetFlag := flag.String("t", "", "trusted subnet (CIDR) for internal endpoints")
 
 		// алиасы
 		flag.StringVar(&cfgPath, "c", "", "path to config file (JSON)")
@@ -135,20 +316,44 @@ func InitConfig() {
 		envBaseURL := os.Getenv("BASE_URL")
 		envFilePath := os.Getenv("FILE_STORAGE_PATH")
 		envDB := os.Getenv("DATABASE_DSN")
+		envFileSync := os.Getenv("FILE_STORAGE_FSYNC")
+		envFileCompact := os.Getenv("FILE_STORAGE_COMPACT_INTERVAL")
+		envReaper := os.Getenv("REAPER_INTERVAL")
+		envGRPCAddr := os.Getenv("GRPC_ADDRESS")
+		envTrustedSubnet := os.Getenv("TRUSTED_SUBNET")
+		envTraceExporter := os.Getenv("TRACE_EXPORTER")
+		envTraceFile := os.Getenv("TRACE_FILE")
+		envTimeout := os.Getenv("REQUEST_TIMEOUT")
+		envRouteTimeouts := os.Getenv("ROUTE_TIMEOUTS")
+		envDeleteWorkers := os.Getenv("DELETE_WORKERS")
+		envDeleteBatch := os.Getenv("DELETE_BATCH_SIZE")
+		envDeleteFlush := os.Getenv("DELETE_FLUSH_INTERVAL")
+		envDeleteQueue := os.Getenv("DELETE_QUEUE_SIZE")
+		envDeleteFile := os.Getenv("DELETE_QUEUE_FILE")
+		envCacheSize := os.Getenv("CACHE_SIZE")
+		envCacheTTL := os.Getenv("CACHE_TTL")
+		envIDStrategy := os.Getenv("ID_STRATEGY")
+		envIDLength := os.Getenv("ID_LENGTH")
+		envIDSalt := os.Getenv("ID_SALT")
+		envDedupScope := os.Getenv("DEDUP_SCOPE")
+		envBulkChunk := os.Getenv("BULK_CHUNK_SIZE")
+		envJWTSecret := os.Getenv("JWT_SECRET")
+		envJWTKeysDir := os.Getenv("JWT_KEYS_DIR")
+		envJWTSigningKey := os.Getenv("JWT_SIGNING_KEY_ID")
+		envJWTRefresh := os.Getenv("JWT_REFRESH_WINDOW")
+		envJWTSessionAge := os.Getenv("JWT_SESSION_MAX_AGE")
+		var envJWKS *bool
+		if v, ok := os.LookupEnv("JWKS_ENABLED"); ok {
+			envJWKS = boolEnvPtr(v)
+		}
 		var envHTTPS *bool
 		if v, ok := os.LookupEnv("ENABLE_HTTPS"); ok {
 			envHTTPS = boolEnvPtr(v)
 		}
 
 		// file
-		var fileCfg jsonConfig
-		if cfgPath != "" {
-			if data, err := os.ReadFile(cfgPath); err == nil {
-				_ = json.Unmarshal(data, &fileCfg) // мягко
-			} else {
-				fmt.Println("config: cannot read file:", err)
-			}
-		}
+		configPath = cfgPath
+		fileCfg := readConfigFile(cfgPath)
 
 		// сбор финальных значений по приоритету
 		// helpers
@@ -171,6 +376,40 @@ func InitConfig() {
 		filePath := pickStr(*fileStorageFlag, envFilePath, fileCfg.FileStorage, "")
 		dbConn := pickStr(*dbConnFlag, envDB, fileCfg.DatabaseDSN, "")
 		enableTLS := pickBool(&httpsFlag, envHTTPS, fileCfg.EnableHTTPS, false)
+		fileSync := pickStr("", envFileSync, fileCfg.FileSync, "interval")
+		fileCompact := parseDuration(pickStr("", envFileCompact, fileCfg.FileCompact, ""), 10*time.Minute)
+		reaperInterval := parseDuration(pickStr("", envReaper, fileCfg.Reaper, ""), time.Minute)
+		grpcAddr := pickStr(*grpcAddrFlag, envGRPCAddr, fileCfg.GRPCAddress, ":3200")
+		trustedSubnet := pickStr(*trustedSubnetFlag, envTrustedSubnet, fileCfg.TrustedSubnet, "")
+		traceExporter := pickStr("", envTraceExporter, fileCfg.TraceExporter, "none")
+		traceFile := pickStr("", envTraceFile, fileCfg.TraceFile, "traces.json")
+		requestTimeout := parseDuration(pickStr("", envTimeout, fileCfg.Timeout, ""), 10*time.Second)
+		routeTimeouts := parseRouteTimeouts(pickStr("", envRouteTimeouts, fileCfg.RouteTimeouts, ""))
+		deleteWorkers := pickInt(envDeleteWorkers, fileCfg.DeleteWorkers, 2)
+		deleteBatch := pickInt(envDeleteBatch, fileCfg.DeleteBatch, 100)
+		deleteFlush := parseDuration(pickStr("", envDeleteFlush, fileCfg.DeleteFlush, ""), 500*time.Millisecond)
+		deleteQueue := pickInt(envDeleteQueue, fileCfg.DeleteQueue, 1024)
+		deleteFile := pickStr("", envDeleteFile, fileCfg.DeleteFile, "")
+		cacheSize := pickInt(envCacheSize, fileCfg.CacheSize, 10000)
+		cacheTTL := parseDuration(pickStr("", envCacheTTL, fileCfg.CacheTTL, ""), time.Minute)
+		idStrategy := strings.ToLower(pickStr("", envIDStrategy, fileCfg.IDStrategy, "random"))
+		idLength := pickInt(envIDLength, fileCfg.IDLength, 8)
+		idSalt := pickStr("", envIDSalt, fileCfg.IDSalt, "")
+		dedupScope := strings.ToLower(pickStr("", envDedupScope, fileCfg.DedupScope, "global"))
+		switch dedupScope {
+		case "global", "user", "none":
+		default:
+			fmt.Println("config: invalid dedup scope", dedupScope)
+			dedupScope = "global"
+		}
+
+		bulkChunk := pickInt(envBulkChunk, fileCfg.BulkChunkSize, 1000)
+		jwtSecret := pickStr("", envJWTSecret, fileCfg.JWTSecret, "")
+		jwtKeysDir := pickStr("", envJWTKeysDir, fileCfg.JWTKeysDir, "")
+		jwtSigningKey := pickStr("", envJWTSigningKey, fileCfg.JWTSigningKey, "")
+		jwksEnabled := pickBool(nil, envJWKS, fileCfg.JWKSEnabled, false)
+		jwtRefresh := parseDuration(pickStr("", envJWTRefresh, fileCfg.JWTRefresh, ""), time.Hour)
+		jwtSessionAge := parseDuration(pickStr("", envJWTSessionAge, fileCfg.JWTSessionAge, ""), 30*24*time.Hour)
 
 		storageType := "Memory"
 		if dbConn != "" {
@@ -187,11 +426,38 @@ func InitConfig() {
 				DBConnection: dbConn,
 				DBTimeout:    10,
 			},
-			StorageType:   storageType,
-			JWTSecretKey:  "supersecretkey",
-			JWTTokenExp:   time.Hour * 3,
-			JWTCookieName: "auth_token",
-			EnableHTTPS:   enableTLS,
+			StorageType:      storageType,
+			JWTSecretKey:     jwtSecret,
+			JWTKeysDir:       jwtKeysDir,
+			JWTSigningKeyID:  jwtSigningKey,
+			JWKSEnabled:      jwksEnabled,
+			JWTTokenExp:      time.Hour * 3,
+			JWTRefreshWindow: jwtRefresh,
+			JWTSessionMaxAge: jwtSessionAge,
+			JWTCookieName:    "auth_token",
+			EnableHTTPS:      enableTLS,
+
+			FileSyncPolicy:      fileSync,
+			FileCompactInterval: fileCompact,
+			ReaperInterval:      reaperInterval,
+			GRPCAddr:            grpcAddr,
+			TrustedSubnet:       trustedSubnet,
+			TraceExporter:       traceExporter,
+			TraceFile:           traceFile,
+			RequestTimeout:      requestTimeout,
+			RouteTimeouts:       routeTimeouts,
+			DeleteWorkers:       deleteWorkers,
+			DeleteBatchSize:     deleteBatch,
+			DeleteFlushInterval: deleteFlush,
+			DeleteQueueSize:     deleteQueue,
+			DeleteQueueFile:     deleteFile,
+			CacheSize:           cacheSize,
+			CacheTTL:            cacheTTL,
+			IDStrategy:          idStrategy,
+			IDLength:            idLength,
+			IDSalt:              idSalt,
+			DedupScope:          dedupScope,
+			BulkChunkSize:       bulkChunk,
 		}
 
 		fmt.Println("Storage type:", storageType)
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// Account — запись таблицы accounts.
+type Account struct {
+	ID           string
+	Username     string
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+// InsertAccount сохраняет новую учетную запись.
+func InsertAccount(ctx context.Context, a Account) error {
+	instance, err := SQLInstance()
+	if err != nil {
+		return err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	_, err = db.Exec(timeoutCtx,
+		"INSERT INTO accounts (userID, username, password_hash, created_at) VALUES ($1, $2, $3, $4)",
+		a.ID, a.Username, a.PasswordHash, a.CreatedAt)
+	return err
+}
+
+// SelectAccountByUsername возвращает учетную запись по имени; found == false, если ее нет.
+func SelectAccountByUsername(ctx context.Context, username string) (a Account, found bool, err error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return Account{}, false, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	err = db.QueryRow(timeoutCtx,
+		"SELECT userID, username, password_hash, created_at FROM accounts WHERE username = $1", username).
+		Scan(&a.ID, &a.Username, &a.PasswordHash, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Account{}, false, nil
+	}
+	if err != nil {
+		return Account{}, false, err
+	}
+	return a, true, nil
+}
+
+// AccountExists сообщает, есть ли учетная запись с userID.
+func AccountExists(ctx context.Context, userID string) (bool, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return false, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	var exists bool
+	err = db.QueryRow(timeoutCtx, "SELECT EXISTS (SELECT 1 FROM accounts WHERE userID = $1)", userID).Scan(&exists)
+	return exists, err
+}
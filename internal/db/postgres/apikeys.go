@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// APIKey — запись таблицы api_keys; хранится только хеш секрета.
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Hash       string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// apiKeyColumns — столбцы api_keys в порядке scanAPIKey.
+const apiKeyColumns = "id, userID, name, hash, scopes, created_at, last_used_at"
+
+// scanAPIKey читает строку с apiKeyColumns.
+func scanAPIKey(row pgx.Row) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Hash, &k.Scopes, &k.CreatedAt, &k.LastUsedAt)
+	return k, err
+}
+
+// InsertAPIKey сохраняет новый API-ключ.
+func InsertAPIKey(ctx context.Context, k APIKey) error {
+	instance, err := SQLInstance()
+	if err != nil {
+		return err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	_, err = db.Exec(timeoutCtx,
+		"INSERT INTO api_keys (id, userID, name, hash, scopes, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		k.ID, k.UserID, k.Name, k.Hash, k.Scopes, k.CreatedAt)
+	return err
+}
+
+// SelectAPIKey возвращает ключ по id; found == false, если ключа нет.
+func SelectAPIKey(ctx context.Context, id string) (k APIKey, found bool, err error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return APIKey{}, false, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	k, err = scanAPIKey(db.QueryRow(timeoutCtx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, false, nil
+	}
+	if err != nil {
+		return APIKey{}, false, err
+	}
+	return k, true, nil
+}
+
+// SelectAPIKeysByUser возвращает ключи пользователя в порядке создания.
+func SelectAPIKeysByUser(ctx context.Context, userID string) ([]APIKey, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return nil, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	rows, err := db.Query(timeoutCtx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE userID = $1 ORDER BY created_at, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteAPIKeys удаляет ключи ids, принадлежащие userID, и возвращает число удаленных.
+func DeleteAPIKeys(ctx context.Context, userID string, ids []string) (int64, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return 0, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	tag, err := db.Exec(timeoutCtx, "DELETE FROM api_keys WHERE userID = $1 AND id = ANY($2)", userID, ids)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// TouchAPIKey отмечает время последнего использования ключа.
+func TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	instance, err := SQLInstance()
+	if err != nil {
+		return err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	_, err = db.Exec(timeoutCtx, "UPDATE api_keys SET last_used_at = $2 WHERE id = $1", id, at)
+	return err
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// Click — запись о переходе по ссылке в таблице url_clicks.
+type Click struct {
+	LinkID         string
+	Timestamp      time.Time
+	Referrer       string
+	UserAgent      string
+	IPBucket       string
+	AcceptLanguage string
+}
+
+// ClickPoint — число переходов за шаг временного ряда.
+type ClickPoint struct {
+	Time   time.Time
+	Clicks int64
+}
+
+// InsertClicks сохраняет пачку переходов через COPY.
+func InsertClicks(ctx context.Context, clicks []Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	instance, err := SQLInstance()
+	if err != nil {
+		return err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	columns := []string{"link_id", "ts", "referrer", "user_agent", "ip_bucket", "accept_language"}
+	_, err = db.CopyFrom(timeoutCtx, pgx.Identifier{"url_clicks"}, columns,
+		pgx.CopyFromSlice(len(clicks), func(i int) ([]interface{}, error) {
+			c := clicks[i]
+			return []interface{}{c.LinkID, c.Timestamp, c.Referrer, c.UserAgent, c.IPBucket, c.AcceptLanguage}, nil
+		}))
+	return err
+}
+
+// DeleteClicks удаляет переходы по ссылкам linkIDs.
+func DeleteClicks(ctx context.Context, linkIDs []string) error {
+	if len(linkIDs) == 0 {
+		return nil
+	}
+
+	instance, err := SQLInstance()
+	if err != nil {
+		return err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	_, err = db.Exec(timeoutCtx, "DELETE FROM url_clicks WHERE link_id = ANY($1)", linkIDs)
+	return err
+}
+
+// SelectClickStats возвращает общее число переходов по ссылке и ряд
+// переходов за [from, to) с шагом granularity ("hour" или "day").
+func SelectClickStats(ctx context.Context, linkID string, granularity string, from, to time.Time) (int64, []ClickPoint, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return 0, nil, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	var total int64
+	err = db.QueryRow(timeoutCtx, "SELECT count(*) FROM url_clicks WHERE link_id = $1", linkID).Scan(&total)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	query := `SELECT date_trunc($2, ts AT TIME ZONE 'UTC') AS bucket, count(*)
+		FROM url_clicks
+		WHERE link_id = $1 AND ts >= $3 AND ts < $4
+		GROUP BY bucket
+		ORDER BY bucket`
+	rows, err := db.Query(timeoutCtx, query, linkID, granularity, from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var points []ClickPoint
+	for rows.Next() {
+		var p ClickPoint
+		if err := rows.Scan(&p.Time, &p.Clicks); err != nil {
+			return 0, nil, err
+		}
+		points = append(points, p)
+	}
+	return total, points, rows.Err()
+}
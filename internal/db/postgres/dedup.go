@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Имена частичных уникальных индексов по исходному URL (см. миграцию 0009).
+const (
+	// GlobalURLIndex — URL уникален среди всех неудаленных ссылок.
+	GlobalURLIndex = "urls_active_originalurl_key"
+	// UserURLIndex — URL уникален среди неудаленных ссылок пользователя.
+	UserURLIndex = "urls_user_originalurl_key"
+)
+
+// ApplyDedupScope приводит уникальные индексы по исходному URL к области scope:
+// "global", "user" или "none". Если существующие данные нарушают новую
+// уникальность, индекс не создается и возвращается ошибка.
+func ApplyDedupScope(ctx context.Context, pool *pgxpool.Pool, scope string) error {
+	var create, drop []string
+	switch scope {
+	case "global":
+		create = []string{"CREATE UNIQUE INDEX IF NOT EXISTS " + GlobalURLIndex + " ON urls (originalURL) WHERE NOT deleted"}
+		drop = []string{UserURLIndex}
+	case "user":
+		create = []string{"CREATE UNIQUE INDEX IF NOT EXISTS " + UserURLIndex + " ON urls (userID, originalURL) WHERE NOT deleted"}
+		drop = []string{GlobalURLIndex}
+	case "none":
+		drop = []string{GlobalURLIndex, UserURLIndex}
+	default:
+		return fmt.Errorf("unknown dedup scope %q", scope)
+	}
+
+	return withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		for _, q := range create {
+			if _, err := conn.Exec(ctx, q); err != nil {
+				return fmt.Errorf("dedup scope %s: %w", scope, err)
+			}
+		}
+		for _, idx := range drop {
+			if _, err := conn.Exec(ctx, "DROP INDEX IF EXISTS "+idx); err != nil {
+				return fmt.Errorf("dedup scope %s: %w", scope, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,8 @@
+-- Исходная схема; IF NOT EXISTS позволяет принять под управление БД,
+-- созданные до появления миграций.
+CREATE TABLE IF NOT EXISTS urls (
+	id TEXT,
+	userID TEXT,
+	originalURL TEXT UNIQUE,
+	deleted INTEGER DEFAULT 0
+);
@@ -0,0 +1 @@
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_pkey;
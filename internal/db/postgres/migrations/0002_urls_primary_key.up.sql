@@ -0,0 +1,4 @@
+-- До миграции id не был уникальным: оставляем по одной строке на id.
+DELETE FROM urls WHERE id IS NULL;
+DELETE FROM urls a USING urls b WHERE a.id = b.id AND a.ctid < b.ctid;
+ALTER TABLE urls ADD CONSTRAINT urls_pkey PRIMARY KEY (id);
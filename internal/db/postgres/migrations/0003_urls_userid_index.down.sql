@@ -0,0 +1 @@
+DROP INDEX IF EXISTS urls_userid_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS urls_userid_idx ON urls (userID);
@@ -0,0 +1,4 @@
+ALTER TABLE urls ALTER COLUMN deleted DROP NOT NULL;
+ALTER TABLE urls ALTER COLUMN deleted DROP DEFAULT;
+ALTER TABLE urls ALTER COLUMN deleted TYPE INTEGER USING CASE WHEN deleted THEN 1 ELSE 0 END;
+ALTER TABLE urls ALTER COLUMN deleted SET DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE urls ALTER COLUMN deleted DROP DEFAULT;
+ALTER TABLE urls ALTER COLUMN deleted TYPE BOOLEAN USING COALESCE(deleted, 0) <> 0;
+ALTER TABLE urls ALTER COLUMN deleted SET DEFAULT FALSE;
+ALTER TABLE urls ALTER COLUMN deleted SET NOT NULL;
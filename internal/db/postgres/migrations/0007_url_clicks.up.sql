@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS url_clicks (
+	link_id TEXT NOT NULL,
+	ts TIMESTAMPTZ NOT NULL,
+	referrer TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_bucket TEXT NOT NULL DEFAULT '',
+	accept_language TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS url_clicks_link_ts_idx ON url_clicks (link_id, ts);
@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS urls_id_seq;
@@ -0,0 +1 @@
+CREATE SEQUENCE IF NOT EXISTS urls_id_seq AS BIGINT;
@@ -0,0 +1,14 @@
+-- Прежнее ограничение уникально по всем ссылкам, включая удаленные. Если
+-- исходные URL повторяются, откат прерывается: дубли нужно разобрать вручную,
+-- чтобы не потерять ссылки пользователей.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM urls GROUP BY originalURL HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'cannot restore global originalURL uniqueness: duplicate original URLs exist';
+    END IF;
+END
+$$;
+DROP INDEX IF EXISTS urls_user_originalurl_key;
+DROP INDEX IF EXISTS urls_active_originalurl_key;
+DROP INDEX IF EXISTS urls_originalurl_idx;
+ALTER TABLE urls ADD CONSTRAINT urls_originalurl_key UNIQUE (originalURL);
@@ -0,0 +1,6 @@
+-- Удаленные ссылки больше не учитываются в уникальности исходного URL, а ее
+-- область задает DEDUP_SCOPE. По умолчанию URL уникален среди всех
+-- неудаленных ссылок; для других областей индекс пересоздает ApplyDedupScope.
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_originalurl_key;
+CREATE INDEX IF NOT EXISTS urls_originalurl_idx ON urls (originalURL);
+CREATE UNIQUE INDEX IF NOT EXISTS urls_active_originalurl_key ON urls (originalURL) WHERE NOT deleted;
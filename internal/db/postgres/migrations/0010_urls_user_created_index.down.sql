@@ -0,0 +1 @@
+DROP INDEX IF EXISTS urls_user_created_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS urls_user_created_idx ON urls (userID, created_at, id) WHERE NOT deleted;
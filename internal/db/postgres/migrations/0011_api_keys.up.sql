@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+	id TEXT PRIMARY KEY,
+	userID TEXT NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	hash TEXT NOT NULL,
+	scopes TEXT[] NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_used_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS api_keys_userid_idx ON api_keys (userID, created_at);
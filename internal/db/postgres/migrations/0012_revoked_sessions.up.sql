@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS revoked_sessions (
+	session_id TEXT PRIMARY KEY,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS revoked_sessions_expires_idx ON revoked_sessions (expires_at);
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// InsertRevokedSession добавляет сессию в список отозванных до until и
+// удаляет записи, срок которых уже прошел.
+func InsertRevokedSession(ctx context.Context, sessionID string, until time.Time) error {
+	instance, err := SQLInstance()
+	if err != nil {
+		return err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	_, err = db.Exec(timeoutCtx,
+		`INSERT INTO revoked_sessions (session_id, expires_at) VALUES ($1, $2)
+		ON CONFLICT (session_id) DO UPDATE SET expires_at = GREATEST(revoked_sessions.expires_at, EXCLUDED.expires_at)`,
+		sessionID, until)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(timeoutCtx, "DELETE FROM revoked_sessions WHERE expires_at < now()")
+	return err
+}
+
+// SelectRevokedSessions возвращает отозванные сессии, срок записи которых еще не прошел.
+func SelectRevokedSessions(ctx context.Context) (map[string]time.Time, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return nil, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	rows, err := db.Query(timeoutCtx, "SELECT session_id, expires_at FROM revoked_sessions WHERE expires_at > now()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]time.Time)
+	for rows.Next() {
+		var id string
+		var until time.Time
+		if err := rows.Scan(&id, &until); err != nil {
+			return nil, err
+		}
+		res[id] = until
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/zauremazhikovayandex/url/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// querier — общие методы пула и транзакции.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error)
+}
+
+var (
+	_ querier = (*pgxpool.Pool)(nil)
+	_ querier = (pgx.Tx)(nil)
+)
+
+// tracedDB — обертка над пулом или транзакцией, открывающая спан на каждый SQL-запрос.
+type tracedDB struct {
+	q querier
+}
+
+// traced оборачивает пул или транзакцию трассировкой запросов.
+func traced(q querier) tracedDB {
+	return tracedDB{q: q}
+}
+
+// startQuery открывает клиентский спан запроса; имя спана — первое слово SQL.
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	op := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+	return tracing.Tracer().Start(ctx, "postgres "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(op),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// endQuery закрывает спан запроса; отсутствие строк ошибкой не считается.
+func endQuery(span trace.Span, err error) {
+	if !errors.Is(err, pgx.ErrNoRows) {
+		tracing.RecordError(span, err)
+	}
+	span.End()
+}
+
+// QueryRow выполняет запрос; спан закрывается при Scan.
+func (db tracedDB) QueryRow(ctx context.Context, query string, args ...interface{}) pgx.Row {
+	ctx, span := startQuery(ctx, query)
+	return tracedRow{row: db.q.QueryRow(ctx, query, args...), span: span}
+}
+
+// Query выполняет запрос; спан закрывается при Close.
+func (db tracedDB) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	rows, err := db.q.Query(ctx, query, args...)
+	if err != nil {
+		endQuery(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+// Exec выполняет команду без результата.
+func (db tracedDB) Exec(ctx context.Context, query string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := startQuery(ctx, query)
+	tag, err := db.q.Exec(ctx, query, args...)
+	span.SetAttributes(attribute.Int64("db.rows_affected", tag.RowsAffected()))
+	endQuery(span, err)
+	return tag, err
+}
+
+// CopyFrom загружает строки в таблицу через COPY.
+func (db tracedDB) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "postgres COPY",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName("COPY"),
+			semconv.DBCollectionName(table.Sanitize()),
+		),
+	)
+	n, err := db.q.CopyFrom(ctx, table, columns, src)
+	span.SetAttributes(attribute.Int64("db.rows_affected", n))
+	endQuery(span, err)
+	return n, err
+}
+
+// tracedRow закрывает спан после чтения строки.
+type tracedRow struct {
+	row  pgx.Row
+	span trace.Span
+}
+
+// Scan читает строку и закрывает спан.
+func (r tracedRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	endQuery(r.span, err)
+	return err
+}
+
+// tracedRows закрывает спан после закрытия результата.
+type tracedRows struct {
+	pgx.Rows
+	span trace.Span
+}
+
+// Close закрывает результат и спан.
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	endQuery(r.span, r.Rows.Err())
+}
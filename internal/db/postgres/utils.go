@@ -10,142 +10,495 @@ import (
 	"github.com/zauremazhikovayandex/url/internal/logger/message"
 	"log"
 	"strings"
+	"time"
 )
 
 // URL представляет запись о короткой ссылке в БД.
 type URL struct {
 	ID          string
 	OriginalURL string
-	Deleted     int
+	UserID      string
+	Deleted     bool
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	MaxClicks   int
+	Clicks      int64
 }
 
+// Expired сообщает, истек ли срок жизни ссылки или исчерпан лимит переходов.
+func (u URL) Expired(now time.Time) bool {
+	if u.ExpiresAt != nil && !now.Before(*u.ExpiresAt) {
+		return true
+	}
+	return u.MaxClicks > 0 && u.Clicks >= int64(u.MaxClicks)
+}
+
+// urlColumns — список колонок в порядке, ожидаемом scanURL.
+const urlColumns = "id, originalURL, COALESCE(userID, ''), deleted, created_at, expires_at, COALESCE(max_clicks, 0), clicks"
+
+// scanURL читает строку, выбранную с колонками urlColumns.
+func scanURL(row pgx.Row) (URL, error) {
+	var u URL
+	err := row.Scan(&u.ID, &u.OriginalURL, &u.UserID, &u.Deleted, &u.CreatedAt, &u.ExpiresAt, &u.MaxClicks, &u.Clicks)
+	return u, err
+}
+
+// PrimaryKeyConstraint — имя ограничения первичного ключа таблицы urls (см. миграцию 0002).
+const PrimaryKeyConstraint = "urls_pkey"
+
 // ErrURLDeleted сигнализирует, что ссылка помечена как удаленная.
 var ErrURLDeleted = errors.New("url_deleted")
 
-// ErrDuplicateOriginalURL сигнализирует, что оригинальный URL уже существует.
-var ErrDuplicateOriginalURL = errors.New("duplicate_original_url")
+// ErrURLExpired сигнализирует, что срок жизни ссылки истек или исчерпан лимит переходов.
+var ErrURLExpired = errors.New("url_expired")
+
+// SelectURL возвращает запись по id. Для удаленной или истекшей ссылки
+// запись возвращается вместе с ErrURLDeleted / ErrURLExpired.
+func SelectURL(ctx context.Context, id string) (URL, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return URL{}, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	query := "SELECT " + urlColumns + " FROM urls WHERE id = $1"
+
+	u, err := scanURL(db.QueryRow(timeoutCtx, query, id))
+	if err != nil {
+		return URL{}, err
+	}
+	if u.Deleted {
+		return u, ErrURLDeleted
+	}
+	if u.Expired(time.Now()) {
+		return u, ErrURLExpired
+	}
+
+	return u, nil
+}
 
-// SelectURL возвращает оригинальный URL по id.
-func SelectURL(ctx context.Context, id string) (string, error) {
+// ResolveURL атомарно засчитывает переход по ссылке и возвращает исходный URL.
+// Переход не засчитывается для удаленной или истекшей ссылки.
+func ResolveURL(ctx context.Context, id string) (string, error) {
 	instance, err := SQLInstance()
 	if err != nil {
 		return "", err
 	}
-	db := instance.PgSQL
+	db := traced(instance.PgSQL)
 
 	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
 	defer cancel()
 
-	query := "SELECT id, originalURL, deleted FROM urls WHERE id = $1"
+	query := `UPDATE urls SET clicks = clicks + 1
+		WHERE id = $1 AND NOT deleted
+			AND (expires_at IS NULL OR expires_at > now())
+			AND (max_clicks IS NULL OR clicks < max_clicks)
+		RETURNING originalURL`
 
-	var u URL
-	err = db.QueryRow(timeoutCtx, query, id).Scan(&u.ID, &u.OriginalURL, &u.Deleted)
+	var originalURL string
+	err = db.QueryRow(timeoutCtx, query, id).Scan(&originalURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// ссылки нет, либо она удалена или истекла — уточняем причину
+		u, err := SelectURL(ctx, id)
+		if err == nil {
+			// истекла между UPDATE и SELECT по часам БД
+			return u.OriginalURL, ErrURLExpired
+		}
+		return u.OriginalURL, err
+	}
 	if err != nil {
 		return "", err
 	}
-	if u.Deleted == 1 {
-		return u.OriginalURL, ErrURLDeleted
+	return originalURL, nil
+}
+
+// AddClicks прибавляет к счетчикам переходов накопленные значения counts;
+// удаленные ссылки пропускаются.
+func AddClicks(ctx context.Context, counts map[string]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	instance, err := SQLInstance()
+	if err != nil {
+		return err
 	}
+	db := traced(instance.PgSQL)
 
-	return u.OriginalURL, nil
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	ids := make([]string, 0, len(counts))
+	ns := make([]int64, 0, len(counts))
+	for id, n := range counts {
+		ids = append(ids, id)
+		ns = append(ns, n)
+	}
+	query := `UPDATE urls u SET clicks = u.clicks + c.n
+		FROM unnest($1::text[], $2::bigint[]) AS c(id, n)
+		WHERE u.id = c.id AND NOT u.deleted`
+	_, err = db.Exec(timeoutCtx, query, ids, ns)
+	return err
 }
 
-// InsertURL сохраняет новый URL, возвращая ошибку при дубликате.
-func InsertURL(ctx context.Context, id string, originalURL string, userID string) error {
+// InsertURL сохраняет новый URL. При дубликате возвращается ошибка
+// unique_violation с именем нарушенного ограничения.
+func InsertURL(ctx context.Context, u URL) error {
 	instance, err := SQLInstance()
 	if err != nil {
 		return err
 	}
-	db := instance.PgSQL
+	db := traced(instance.PgSQL)
 
 	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
 	defer cancel()
 
-	query := "INSERT INTO urls (id, originalURL, userID) VALUES ($1, $2, $3) ON CONFLICT (originalURL) DO NOTHING RETURNING id;"
+	query := `INSERT INTO urls (id, originalURL, userID, expires_at, max_clicks)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0))`
 
-	var returnedID string
-	err = db.QueryRow(timeoutCtx, query, id, originalURL, userID).Scan(&returnedID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return ErrDuplicateOriginalURL
+	_, err = db.Exec(timeoutCtx, query, u.ID, u.OriginalURL, u.UserID, u.ExpiresAt, u.MaxClicks)
+	return err
+}
+
+// InsertURLs вставляет пачку ссылок одним запросом в транзакции. Строки,
+// нарушающие уникальность id или исходного URL, пропускаются. Возвращает id
+// вставленных строк и существующие строки, с которыми конфликтуют пропущенные
+// (по id или исходному URL), — чтобы вызывающий мог определить причину.
+// Вставленные строки возвращаются как id → исходный URL.
+func InsertURLs(ctx context.Context, urls []URL) (map[string]string, []URL, error) {
+	return saveURLs(ctx, urls, func(ctx context.Context, db tracedDB) (pgx.Rows, error) {
+		ids := make([]string, len(urls))
+		originals := make([]string, len(urls))
+		users := make([]string, len(urls))
+		expires := make([]*time.Time, len(urls))
+		maxClicks := make([]int32, len(urls))
+		for i, u := range urls {
+			ids[i], originals[i], users[i], expires[i], maxClicks[i] = u.ID, u.OriginalURL, u.UserID, u.ExpiresAt, int32(u.MaxClicks)
+		}
+
+		query := `INSERT INTO urls (id, originalURL, userID, expires_at, max_clicks)
+			SELECT id, originalURL, userID, expires_at, NULLIF(max_clicks, 0)
+			FROM unnest($1::text[], $2::text[], $3::text[], $4::timestamptz[], $5::int[])
+				AS t(id, originalURL, userID, expires_at, max_clicks)
+			ON CONFLICT DO NOTHING
+			RETURNING id, originalURL`
+		return db.Query(ctx, query, ids, originals, users, expires, maxClicks)
+	})
+}
+
+// CopyURLs работает как InsertURLs, но загружает пачку через COPY во временную
+// таблицу и переносит ее в urls одним INSERT; выгоднее для больших пачек.
+func CopyURLs(ctx context.Context, urls []URL) (map[string]string, []URL, error) {
+	return saveURLs(ctx, urls, func(ctx context.Context, db tracedDB) (pgx.Rows, error) {
+		_, err := db.Exec(ctx, `CREATE TEMP TABLE urls_import (
+			id TEXT, originalURL TEXT, userID TEXT, expires_at TIMESTAMPTZ, max_clicks INT
+		) ON COMMIT DROP`)
+		if err != nil {
+			return nil, err
+		}
+
+		columns := []string{"id", "originalurl", "userid", "expires_at", "max_clicks"}
+		_, err = db.CopyFrom(ctx, pgx.Identifier{"urls_import"}, columns,
+			pgx.CopyFromSlice(len(urls), func(i int) ([]interface{}, error) {
+				u := urls[i]
+				return []interface{}{u.ID, u.OriginalURL, u.UserID, u.ExpiresAt, int32(u.MaxClicks)}, nil
+			}))
+		if err != nil {
+			return nil, err
+		}
+
+		query := `INSERT INTO urls (id, originalURL, userID, expires_at, max_clicks)
+			SELECT id, originalURL, userID, expires_at, NULLIF(max_clicks, 0)
+			FROM urls_import
+			ON CONFLICT DO NOTHING
+			RETURNING id, originalURL`
+		return db.Query(ctx, query)
+	})
+}
+
+// saveURLs выполняет insert в транзакции и выбирает существующие строки,
+// конфликтующие с невставленными ссылками. insert возвращает id и исходный URL
+// вставленных строк.
+func saveURLs(ctx context.Context, urls []URL, insert func(ctx context.Context, db tracedDB) (pgx.Rows, error)) (map[string]string, []URL, error) {
+	if len(urls) == 0 {
+		return nil, nil, nil
 	}
+	instance, err := SQLInstance()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return nil
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	tx, err := instance.PgSQL.Begin(timeoutCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(context.Background())
+	db := traced(tx)
+
+	rows, err := insert(timeoutCtx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	inserted := make(map[string]string, len(urls))
+	for rows.Next() {
+		var id, originalURL string
+		if err := rows.Scan(&id, &originalURL); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		inserted[id] = originalURL
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var conflictIDs, conflictURLs []string
+	for _, u := range urls {
+		if inserted[u.ID] != u.OriginalURL {
+			conflictIDs = append(conflictIDs, u.ID)
+			conflictURLs = append(conflictURLs, u.OriginalURL)
+		}
+	}
+
+	var existing []URL
+	if len(conflictIDs) > 0 {
+		rows, err := db.Query(timeoutCtx, "SELECT "+urlColumns+" FROM urls WHERE id = ANY($1) OR (originalURL = ANY($2) AND NOT deleted)", conflictIDs, conflictURLs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			u, err := scanURL(rows)
+			if err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			existing = append(existing, u)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(timeoutCtx); err != nil {
+		return nil, nil, err
+	}
+	return inserted, existing, nil
 }
 
-// SelectIDByOriginalURL возвращает id по оригинальному URL.
-func SelectIDByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+// PurgeExpiredURLs удаляет истекшие ссылки вместе с их статистикой переходов
+// и возвращает id удаленных ссылок.
+func PurgeExpiredURLs(ctx context.Context) ([]string, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return nil, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	query := `WITH purged AS (
+			DELETE FROM urls
+			WHERE (expires_at IS NOT NULL AND expires_at <= now())
+				OR (max_clicks IS NOT NULL AND clicks >= max_clicks)
+			RETURNING id
+		), purged_clicks AS (
+			DELETE FROM url_clicks WHERE link_id IN (SELECT id FROM purged)
+		)
+		SELECT id FROM purged`
+
+	rows, err := db.Query(timeoutCtx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var purged []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		purged = append(purged, id)
+	}
+	return purged, rows.Err()
+}
+
+// SelectIDByOriginalURL возвращает id неудаленной ссылки на оригинальный URL:
+// среди ссылок пользователя userID или, если он пуст, среди всех.
+func SelectIDByOriginalURL(ctx context.Context, originalURL string, userID string) (string, error) {
 	instance, err := SQLInstance()
 	if err != nil {
 		return "", err
 	}
-	db := instance.PgSQL
+	db := traced(instance.PgSQL)
 
 	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
 	defer cancel()
 
-	query := "SELECT id FROM urls WHERE originalURL = $1"
+	query := "SELECT id FROM urls WHERE originalURL = $1 AND NOT deleted AND ($2 = '' OR userID = $2) ORDER BY created_at LIMIT 1"
 
 	var id string
-	err = db.QueryRow(timeoutCtx, query, originalURL).Scan(&id)
+	err = db.QueryRow(timeoutCtx, query, originalURL, userID).Scan(&id)
 	if err != nil {
 		return "", err
 	}
 	return id, nil
 }
 
-// SelectURLsByUser возвращает все активные URL пользователя.
-func SelectURLsByUser(ctx context.Context, userID string) ([]URL, error) {
+// CountURLs возвращает число неудаленных ссылок.
+func CountURLs(ctx context.Context) (int, error) {
+	return countQuery(ctx, "SELECT count(*) FROM urls WHERE NOT deleted")
+}
+
+// CountUsers возвращает число различных владельцев неудаленных ссылок.
+func CountUsers(ctx context.Context) (int, error) {
+	return countQuery(ctx, "SELECT count(DISTINCT userID) FROM urls WHERE NOT deleted AND userID <> ''")
+}
+
+// countQuery выполняет запрос, возвращающий одно число.
+func countQuery(ctx context.Context, query string) (int, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return 0, err
+	}
+	db := traced(instance.PgSQL)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	var n int
+	if err := db.QueryRow(timeoutCtx, query).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// NextSequenceID возвращает следующее значение последовательности urls_id_seq (см. миграцию 0008).
+func NextSequenceID(ctx context.Context) (uint64, error) {
+	n, err := countQuery(ctx, "SELECT nextval('urls_id_seq')")
+	return uint64(n), err
+}
+
+// ListFilter — параметры выборки ссылок пользователя.
+type ListFilter struct {
+	// Limit — максимум строк; 0 — без ограничения.
+	Limit int
+	// AfterCreatedAt и AfterID — ключ последней строки предыдущей страницы.
+	AfterCreatedAt *time.Time
+	AfterID        string
+	// Desc — сортировка по убыванию (created_at, id).
+	Desc bool
+	// Contains — подстрока исходного URL без учета регистра.
+	Contains string
+	// Domain — домен исходного URL в нижнем регистре; поддомены тоже подходят.
+	Domain string
+}
+
+// urlHostExpr извлекает хост из исходного URL в нижнем регистре.
+const urlHostExpr = `lower(substring(originalURL from '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^@/?#]*@)?([^:/?#]+)'))`
+
+// SelectURLsByUser возвращает активные URL пользователя, упорядоченные по
+// (created_at, id), начиная после ключа из f и с учетом фильтров.
+func SelectURLsByUser(ctx context.Context, userID string, f ListFilter) ([]URL, error) {
 	instance, err := SQLInstance()
 	if err != nil {
 		return nil, err
 	}
-	db := instance.PgSQL
+	db := traced(instance.PgSQL)
 	ctx, cancel := context.WithTimeout(ctx, instance.Timeout)
 	defer cancel()
 
-	query := "SELECT id, originalURL, deleted FROM urls WHERE userID = $1"
-	rows, err := db.Query(ctx, query, userID)
+	conds := []string{"userID = $1", "NOT deleted"}
+	args := []interface{}{userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	order, cmp := "ASC", ">"
+	if f.Desc {
+		order, cmp = "DESC", "<"
+	}
+	if f.AfterCreatedAt != nil {
+		conds = append(conds, fmt.Sprintf("(created_at, id) %s (%s, %s)", cmp, arg(*f.AfterCreatedAt), arg(f.AfterID)))
+	}
+	if f.Contains != "" {
+		conds = append(conds, fmt.Sprintf("strpos(lower(originalURL), lower(%s)) > 0", arg(f.Contains)))
+	}
+	if f.Domain != "" {
+		d := arg(f.Domain)
+		conds = append(conds, fmt.Sprintf("(%s = %s OR right(%s, length(%s) + 1) = '.' || %s)", urlHostExpr, d, urlHostExpr, d, d))
+	}
+
+	query := "SELECT " + urlColumns + " FROM urls WHERE " + strings.Join(conds, " AND ") +
+		fmt.Sprintf(" ORDER BY created_at %s, id %s", order, order)
+	if f.Limit > 0 {
+		query += " LIMIT " + arg(f.Limit)
+	}
+
+	rows, err := db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var results []URL
-	for rows.Next() {
-		var u URL
-		if err := rows.Scan(&u.ID, &u.OriginalURL, &u.Deleted); err != nil {
-			return nil, err
-		}
-		if u.Deleted == 0 {
-			results = append(results, u)
-		}
-	}
-	return results, nil
+	err = eachURL(rows, func(u URL) error {
+		results = append(results, u)
+		return nil
+	})
+	return results, err
 }
 
-// CreateTables создает необходимые таблицы, если их нет.
-func CreateTables(db *SQLConnection) error {
-	ctx := context.Background()
-	_, err := db.PgSQL.Exec(ctx,
-		`CREATE TABLE IF NOT EXISTS urls (
-			id TEXT,
-			userID TEXT,
-			originalURL TEXT UNIQUE,
-			deleted INTEGER DEFAULT 0
-		)`)
+// EachURLByUser вызывает fn для каждой ссылки пользователя, включая удаленные,
+// в порядке создания. Строки читаются из курсора по мере обработки и не
+// накапливаются в памяти; ошибка fn прерывает обход. Время обхода ограничено
+// только ctx: выгрузка длится столько, сколько клиент ее читает.
+func EachURLByUser(ctx context.Context, userID string, fn func(URL) error) error {
+	instance, err := SQLInstance()
 	if err != nil {
 		return err
 	}
-	return nil
+	db := traced(instance.PgSQL)
+
+	query := "SELECT " + urlColumns + " FROM urls WHERE userID = $1 ORDER BY created_at, id"
+	rows, err := db.Query(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	return eachURL(rows, fn)
+}
+
+// eachURL читает строки, выбранные с колонками urlColumns, и закрывает rows.
+func eachURL(rows pgx.Rows, fn func(URL) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		u, err := scanURL(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
-// PrepareDB выполняет начальную подготовку БД (миграции/создание таблиц).
+// PrepareDB выполняет начальную подготовку БД — накатывает миграции.
 func PrepareDB(db *SQLConnection) {
-	err := CreateTables(db)
+	applied, err := MigrateUp(context.Background(), db.PgSQL)
 	if err != nil {
-		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("DB CreateTables ERROR: %s", err)})
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("DB migrations ERROR: %s", err)})
+		return
+	}
+	if len(applied) > 0 {
+		logger.Log.Info(&message.LogMessage{Message: fmt.Sprintf("DB migrations applied: %v", applied)})
 	}
 }
 
@@ -159,18 +512,18 @@ func DeleteURL(ctx context.Context, id string, userID string) error {
 	if err != nil {
 		return err
 	}
-	db := instance.PgSQL
+	db := traced(instance.PgSQL)
 
 	args := []interface{}{userID, id}
 
-	query := "UPDATE urls SET deleted = 1 WHERE userID = $1 AND id = $2"
+	query := "UPDATE urls SET deleted = TRUE WHERE userID = $1 AND id = $2"
 
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, instance.Timeout)
 	defer cancel()
 
 	_, err = db.Exec(ctxWithTimeout, query, args...)
 	if err != nil {
-		log.Printf("Batch delete failed: %v", err)
+		log.Printf("Delete of URL %s failed: %v", id, err)
 	}
 	return err
 }
@@ -185,7 +538,7 @@ func BatchDeleteURLs(ctx context.Context, ids []string, userID string) error {
 	if err != nil {
 		return err
 	}
-	db := instance.PgSQL
+	db := traced(instance.PgSQL)
 
 	args := make([]interface{}, 0, len(ids)+1)
 	args = append(args, userID)
@@ -196,7 +549,42 @@ func BatchDeleteURLs(ctx context.Context, ids []string, userID string) error {
 		placeholders = append(placeholders, fmt.Sprintf("$%d", i+2))
 	}
 
-	query := fmt.Sprintf(`UPDATE urls SET deleted = 1 WHERE userID = $1 AND id IN (%s)`, strings.Join(placeholders, ", "))
+	query := fmt.Sprintf(`UPDATE urls SET deleted = TRUE WHERE userID = $1 AND id IN (%s)`, strings.Join(placeholders, ", "))
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	_, err = db.Exec(ctxWithTimeout, query, args...)
+	return err
+}
+
+// UserURL — пара (владелец, id ссылки).
+type UserURL struct {
+	UserID string
+	ID     string
+}
+
+// DeleteUserURLs помечает удаленными ссылки нескольких пользователей одним
+// запросом UPDATE ... WHERE (userID, id) IN (...).
+func DeleteUserURLs(ctx context.Context, pairs []UserURL) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	instance, err := SQLInstance()
+	if err != nil {
+		return err
+	}
+	db := traced(instance.PgSQL)
+
+	args := make([]interface{}, 0, 2*len(pairs))
+	tuples := make([]string, 0, len(pairs))
+	for i, p := range pairs {
+		args = append(args, p.UserID, p.ID)
+		tuples = append(tuples, fmt.Sprintf("($%d, $%d)", 2*i+1, 2*i+2))
+	}
+
+	query := fmt.Sprintf(`UPDATE urls SET deleted = TRUE WHERE NOT deleted AND (userID, id) IN (%s)`, strings.Join(tuples, ", "))
 
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, instance.Timeout)
 	defer cancel()
@@ -204,3 +592,26 @@ func BatchDeleteURLs(ctx context.Context, ids []string, userID string) error {
 	_, err = db.Exec(ctxWithTimeout, query, args...)
 	return err
 }
+
+// ReassignURLs передает ссылки пользователя from пользователю to и возвращает
+// их число. При skipDuplicates неудаленные ссылки на URL, который у to уже
+// есть, остаются у from, чтобы не нарушить уникальность в пределах пользователя.
+func ReassignURLs(ctx context.Context, from, to string, skipDuplicates bool) (int64, error) {
+	instance, err := SQLInstance()
+	if err != nil {
+		return 0, err
+	}
+	db := traced(instance.PgSQL)
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, instance.Timeout)
+	defer cancel()
+
+	query := `UPDATE urls u SET userID = $2
+		WHERE u.userID = $1 AND (NOT $3 OR u.deleted OR NOT EXISTS (
+			SELECT 1 FROM urls a WHERE a.userID = $2 AND a.originalURL = u.originalURL AND NOT a.deleted))`
+	tag, err := db.Exec(ctxWithTimeout, query, from, to, skipDuplicates)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,75 +2,426 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/json"
-	"github.com/zauremazhikovayandex/url/internal/config"
-	"log"
+	"errors"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 )
 
-// Store — глобальное in-memory хранилище, инициализируемое при старте.
-var Store *Storage
+// Record — полная запись о короткой ссылке.
+type Record struct {
+	ID          string     `json:"id"`
+	OriginalURL string     `json:"original_url"`
+	UserID      string     `json:"user_id"`
+	Deleted     bool       `json:"deleted"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxClicks   int        `json:"max_clicks,omitempty"`
+	Clicks      int        `json:"clicks,omitempty"`
+}
+
+// Expired сообщает, истек ли срок жизни ссылки или исчерпан лимит переходов.
+func (r Record) Expired(now time.Time) bool {
+	if r.ExpiresAt != nil && !now.Before(*r.ExpiresAt) {
+		return true
+	}
+	return r.MaxClicks > 0 && r.Clicks >= r.MaxClicks
+}
+
+// ErrIDExists сигнализирует, что запись с таким id уже есть.
+var ErrIDExists = errors.New("id_exists")
+
+// ErrURLExists сигнализирует, что оригинальный URL уже сохранен.
+var ErrURLExists = errors.New("url_exists")
+
+// ErrNoRecord сигнализирует, что записи с таким id нет.
+var ErrNoRecord = errors.New("no_record")
+
+// ErrRecordDeleted сигнализирует, что запись помечена как удаленная.
+var ErrRecordDeleted = errors.New("record_deleted")
+
+// ErrRecordExpired сигнализирует, что срок жизни записи истек.
+var ErrRecordExpired = errors.New("record_expired")
 
-// Storage представляет потокобезопасное хранилище ключ→значение.
+// DedupScope определяет, среди каких ссылок исходный URL должен быть уникален.
+type DedupScope string
+
+// Области уникальности исходного URL; удаленные ссылки не учитываются ни в одной.
+const (
+	// DedupGlobal — один URL на весь сервис.
+	DedupGlobal DedupScope = "global"
+	// DedupUser — один URL на пользователя.
+	DedupUser DedupScope = "user"
+	// DedupNone — дубли разрешены.
+	DedupNone DedupScope = "none"
+)
+
+// Storage представляет потокобезопасное хранилище записей
+// с индексом по оригинальному URL. Если подключен журнал, каждое изменение
+// дописывается в него до применения в памяти.
 type Storage struct {
-	data map[string]string
-	mu   sync.RWMutex
+	data    map[string]*Record
+	byURL   map[string]string
+	dedup   DedupScope
+	journal *Journal
+	mu      sync.RWMutex
 }
 
-// InitStorage инициализирует глобальное хранилище и загружает данные из файла (если указан путь).
-func InitStorage() {
-	Store = &Storage{data: make(map[string]string)}
+// New создает пустое хранилище с глобальной уникальностью URL.
+func New() *Storage {
+	return &Storage{
+		data:  make(map[string]*Record),
+		byURL: make(map[string]string),
+		dedup: DedupGlobal,
+	}
+}
 
-	filePath := config.AppConfig.FileStorage
-	if err := Store.LoadFromFile(filePath); err != nil {
-		log.Printf("Failed to load store from file: %v", err)
+// SetDedupScope меняет область уникальности URL и перестраивает индекс.
+func (s *Storage) SetDedupScope(scope DedupScope) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dedup = scope
+	s.byURL = make(map[string]string)
+	for _, rec := range s.data {
+		s.indexLocked(rec)
 	}
 }
 
-// Set сохраняет значение по ключу.
-func (s *Storage) Set(key, value string) {
+// dedupKey возвращает ключ индекса уникальности; false — запись не индексируется.
+func (s *Storage) dedupKey(userID, originalURL string) (string, bool) {
+	switch s.dedup {
+	case DedupNone:
+		return "", false
+	case DedupUser:
+		return userID + "\x00" + originalURL, true
+	}
+	return originalURL, true
+}
+
+// AttachJournal подключает журнал, в который будут писаться изменения.
+func (s *Storage) AttachJournal(j *Journal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.journal = j
+}
+
+// Add атомарно сохраняет запись. Если оригинальный URL уже сохранен
+// в неудаленной записи той же области уникальности, возвращает ее id
+// и ErrURLExists; если занят id — ErrIDExists.
+func (s *Storage) Add(rec Record) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.data[key] = value
+	if key, ok := s.dedupKey(rec.UserID, rec.OriginalURL); ok {
+		if existing, ok := s.byURL[key]; ok {
+			return existing, ErrURLExists
+		}
+	}
+	if _, ok := s.data[rec.ID]; ok {
+		return rec.ID, ErrIDExists
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now().UTC()
+	}
+	if err := s.logLocked(opCreate, rec); err != nil {
+		return "", err
+	}
+	s.putLocked(rec)
+	return rec.ID, nil
 }
 
-// Get возвращает значение по ключу.
-func (s *Storage) Get(key string) (string, bool) {
+// Get возвращает копию записи по ключу.
+func (s *Storage) Get(key string) (Record, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	val, ok := s.data[key]
-	return val, ok
+	rec, ok := s.data[key]
+	if !ok {
+		return Record{}, false
+	}
+	return *rec, true
+}
+
+// Hit засчитывает переход по ссылке и возвращает запись. Для отсутствующей,
+// удаленной или истекшей записи переход не засчитывается и возвращается ошибка.
+// Переходы по ссылкам с лимитом пишутся в журнал, чтобы лимит переживал рестарт;
+// остальные счетчики сохраняются при компакции.
+func (s *Storage) Hit(key string, now time.Time) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.data[key]
+	switch {
+	case !ok:
+		return Record{}, ErrNoRecord
+	case rec.Deleted:
+		return *rec, ErrRecordDeleted
+	case rec.Expired(now):
+		return *rec, ErrRecordExpired
+	}
+	if rec.MaxClicks > 0 {
+		if err := s.logLocked(opClick, Record{ID: key}); err != nil {
+			return Record{}, err
+		}
+	}
+	rec.Clicks++
+	return *rec, nil
+}
+
+// AddClicks засчитывает накопленные переходы по неудаленным записям без
+// проверки срока и лимита. Как и переходы без лимита в Hit, счетчики не пишутся
+// в журнал и сохраняются при компакции.
+func (s *Storage) AddClicks(counts map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, n := range counts {
+		if rec, ok := s.data[key]; ok && !rec.Deleted {
+			rec.Clicks += n
+		}
+	}
+}
+
+// PurgeExpired физически удаляет истекшие записи и возвращает их ключи.
+func (s *Storage) PurgeExpired(now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged []string
+	for key, rec := range s.data {
+		if !rec.Expired(now) {
+			continue
+		}
+		if err := s.logLocked(opPurge, Record{ID: key}); err != nil {
+			return purged, err
+		}
+		s.unindexLocked(rec)
+		delete(s.data, key)
+		purged = append(purged, key)
+	}
+	return purged, nil
+}
+
+// GetKey возвращает ключ неудаленной записи с оригинальным URL value
+// в области уникальности пользователя userID.
+func (s *Storage) GetKey(userID, value string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idx, ok := s.dedupKey(userID, value)
+	if !ok {
+		return "", false
+	}
+	key, ok := s.byURL[idx]
+	return key, ok
+}
+
+// ListByOwner возвращает записи пользователя в порядке создания;
+// удаленные записи включаются, только если withDeleted.
+func (s *Storage) ListByOwner(userID string, withDeleted bool) []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var res []Record
+	for _, rec := range s.data {
+		if rec.UserID == userID && (withDeleted || !rec.Deleted) {
+			res = append(res, *rec)
+		}
+	}
+	sortRecords(res)
+	return res
+}
+
+// Count возвращает число неудаленных записей и число их различных владельцев.
+func (s *Storage) Count() (urls int, users int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	owners := make(map[string]struct{})
+	for _, rec := range s.data {
+		if rec.Deleted {
+			continue
+		}
+		urls++
+		if rec.UserID != "" {
+			owners[rec.UserID] = struct{}{}
+		}
+	}
+	return urls, len(owners)
 }
 
-// Delete удаляет значение по ключу.
-func (s *Storage) Delete(key string) {
+// MarkDeleted помечает записи пользователя как удаленные; чужие ключи пропускаются.
+func (s *Storage) MarkDeleted(keys []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range keys {
+		rec, ok := s.data[k]
+		if !ok || rec.UserID != userID || rec.Deleted {
+			continue
+		}
+		if err := s.logLocked(opDelete, Record{ID: k, UserID: userID}); err != nil {
+			return err
+		}
+		rec.Deleted = true
+		s.unindexLocked(rec)
+	}
+	return nil
+}
+
+// Reassign передает записи пользователя from пользователю to и возвращает их
+// число. Неудаленная запись, URL которой у to уже есть в той же области
+// уникальности, остается у from.
+func (s *Storage) Reassign(from, to string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, rec := range s.recordsLocked() {
+		if rec.UserID != from {
+			continue
+		}
+		if key, ok := s.dedupKey(to, rec.OriginalURL); ok && !rec.Deleted {
+			if existing, ok := s.byURL[key]; ok && existing != rec.ID {
+				continue
+			}
+		}
+		if err := s.logLocked(opOwner, Record{ID: rec.ID, UserID: to}); err != nil {
+			return n, err
+		}
+		cur := s.data[rec.ID]
+		s.unindexLocked(cur)
+		cur.UserID = to
+		s.indexLocked(cur)
+		n++
+	}
+	return n, nil
+}
+
+// Delete физически удаляет запись по ключу.
+func (s *Storage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.data[key]
+	if !ok {
+		return nil
+	}
+	if err := s.logLocked(opPurge, Record{ID: key}); err != nil {
+		return err
+	}
+	s.unindexLocked(rec)
 	delete(s.data, key)
+	return nil
+}
+
+// logLocked пишет изменение в журнал, если он подключен; вызывается под блокировкой.
+func (s *Storage) logLocked(op string, rec Record) error {
+	if s.journal == nil {
+		return nil
+	}
+	return s.journal.Append(op, rec)
+}
+
+// putLocked сохраняет запись во всех индексах; вызывается под блокировкой.
+func (s *Storage) putLocked(rec Record) {
+	s.data[rec.ID] = &rec
+	s.indexLocked(&rec)
+}
+
+// indexLocked добавляет неудаленную запись в индекс уникальности; вызывается под блокировкой.
+func (s *Storage) indexLocked(rec *Record) {
+	if rec.Deleted {
+		return
+	}
+	if key, ok := s.dedupKey(rec.UserID, rec.OriginalURL); ok {
+		s.byURL[key] = rec.ID
+	}
+}
+
+// unindexLocked убирает запись из индекса уникальности; вызывается под блокировкой.
+func (s *Storage) unindexLocked(rec *Record) {
+	key, ok := s.dedupKey(rec.UserID, rec.OriginalURL)
+	if ok && s.byURL[key] == rec.ID {
+		delete(s.byURL, key)
+	}
+}
+
+// recordsLocked возвращает копию всех записей в порядке создания;
+// вызывается под блокировкой.
+func (s *Storage) recordsLocked() []Record {
+	res := make([]Record, 0, len(s.data))
+	for _, rec := range s.data {
+		res = append(res, *rec)
+	}
+	sortRecords(res)
+	return res
+}
+
+// sortRecords упорядочивает записи по времени создания, затем по id.
+func sortRecords(recs []Record) {
+	sort.Slice(recs, func(i, j int) bool {
+		if !recs[i].CreatedAt.Equal(recs[j].CreatedAt) {
+			return recs[i].CreatedAt.Before(recs[j].CreatedAt)
+		}
+		return recs[i].ID < recs[j].ID
+	})
 }
 
 // ShutdownSaveToFile сохраняет данные хранилища в файл перед остановкой.
 func (s *Storage) ShutdownSaveToFile(filename string) error {
+	return s.Compact(filename)
+}
+
+// Compact записывает снимок всех записей в filename и очищает журнал.
+// На время компакции изменения блокируются, поэтому ни одна запись
+// не теряется между снимком и очисткой журнала.
+func (s *Storage) Compact(filename string) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	if err := writeSnapshot(filename, s.recordsLocked()); err != nil {
+		return err
+	}
+	if s.journal != nil {
+		return s.journal.Reset()
+	}
+	return nil
+}
+
+// writeSnapshot атомарно записывает снимок: во временный файл, fsync, rename.
+func writeSnapshot(filename string, records []Record) error {
 	if err := os.MkdirAll(getDir(filename), 0755); err != nil {
 		return err
 	}
 
-	f, err := os.Create(filename)
+	tmp := filename + ".tmp"
+	f, err := os.Create(tmp)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
 	encoder := json.NewEncoder(f)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(s.data)
+	if err := encoder.Encode(records); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
 }
 
-// LoadFromFile загружает данные хранилища из файла.
+// LoadFromFile загружает снимок из файла и применяет поверх него журнал.
+// Поддерживается и прежний формат снимка — объект id → URL без владельцев.
 func (s *Storage) LoadFromFile(filename string) error {
-	f, err := os.Open(filename)
+	if err := s.loadSnapshot(filename); err != nil {
+		return err
+	}
+	return s.replayJournal(JournalPath(filename))
+}
+
+// loadSnapshot загружает записи из файла-снимка.
+func (s *Storage) loadSnapshot(filename string) error {
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		// если файл не найден — это не ошибка
 		if os.IsNotExist(err) {
@@ -78,25 +429,34 @@ func (s *Storage) LoadFromFile(filename string) error {
 		}
 		return err
 	}
-	defer f.Close()
 
-	var loaded map[string]string
-	if err := json.NewDecoder(f).Decode(&loaded); err != nil {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil
+	}
+
+	var loaded []Record
+	if trimmed[0] == '{' {
+		var legacy map[string]string
+		if err := json.Unmarshal(trimmed, &legacy); err != nil {
+			return err
+		}
+		for k, v := range legacy {
+			loaded = append(loaded, Record{ID: k, OriginalURL: v})
+		}
+	} else if err := json.Unmarshal(trimmed, &loaded); err != nil {
 		return err
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	for k, v := range loaded {
-		s.data[k] = v
+	for _, rec := range loaded {
+		s.putLocked(rec)
 	}
 	return nil
 }
 
 // getDir - Получение директории хранилища
 func getDir(path string) string {
-	if idx := len(path) - len("/url_history.json"); idx > 0 {
-		return path[:idx]
-	}
-	return "."
+	return filepath.Dir(path)
 }
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SyncPolicy определяет, когда журнал сбрасывается на диск (fsync).
+type SyncPolicy string
+
+// Политики fsync журнала.
+const (
+	// SyncAlways — fsync после каждой записи.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval — fsync в фоне раз в интервал.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever — сброс на диск оставляется операционной системе.
+	SyncNever SyncPolicy = "never"
+)
+
+// Операции журнала.
+const (
+	opCreate = "create"
+	opDelete = "delete"
+	opPurge  = "purge"
+	opClick  = "click"
+	opOwner  = "owner"
+)
+
+// journalEntry — одна строка журнала (JSON lines).
+type journalEntry struct {
+	Op     string `json:"op"`
+	Record Record `json:"record"`
+}
+
+// Journal — append-only журнал изменений хранилища в формате JSON lines.
+type Journal struct {
+	f      *os.File
+	w      *bufio.Writer
+	policy SyncPolicy
+	dirty  bool
+	mu     sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// JournalPath возвращает путь журнала для файла-снимка.
+func JournalPath(snapshotPath string) string {
+	return snapshotPath + ".journal"
+}
+
+// OpenJournal открывает (или создает) журнал для дозаписи.
+// Для политики SyncInterval запускается фоновый fsync раз в interval.
+func OpenJournal(path string, policy SyncPolicy, interval time.Duration) (*Journal, error) {
+	if err := os.MkdirAll(getDir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{f: f, w: bufio.NewWriter(f), policy: policy}
+	if policy == SyncInterval && interval > 0 {
+		j.stop = make(chan struct{})
+		j.done = make(chan struct{})
+		go j.syncLoop(interval)
+	}
+	return j, nil
+}
+
+// Append дописывает запись в журнал в соответствии с политикой fsync.
+func (j *Journal) Append(op string, rec Record) error {
+	line, err := json.Marshal(journalEntry{Op: op, Record: rec})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(line); err != nil {
+		return err
+	}
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	if j.policy == SyncAlways {
+		return j.f.Sync()
+	}
+	j.dirty = true
+	return nil
+}
+
+// Reset очищает журнал после того, как его содержимое попало в снимок.
+func (j *Journal) Reset() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	j.dirty = false
+	return j.f.Sync()
+}
+
+// Close останавливает фоновый fsync, сбрасывает буфер и закрывает файл.
+func (j *Journal) Close() error {
+	if j.stop != nil {
+		close(j.stop)
+		<-j.done
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	return j.f.Close()
+}
+
+// syncLoop периодически выполняет fsync, если были записи.
+func (j *Journal) syncLoop(interval time.Duration) {
+	defer close(j.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+			j.mu.Lock()
+			if j.dirty {
+				_ = j.f.Sync()
+				j.dirty = false
+			}
+			j.mu.Unlock()
+		}
+	}
+}
+
+// replayJournal применяет журнал к хранилищу. Оборванная последняя строка
+// (например, после kill -9 во время записи) отбрасывается, а файл обрезается
+// до последней целой записи, чтобы следующие записи не склеились с мусором.
+func (s *Storage) replayJournal(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var offset int64
+	r := bufio.NewReader(bytes.NewReader(data))
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				// последняя строка без перевода строки — запись не завершена
+				return os.Truncate(path, offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var e journalEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			if int(offset)+len(line) == len(data) {
+				return os.Truncate(path, offset)
+			}
+			return fmt.Errorf("journal %s: corrupted line %d: %w", path, lineNo, err)
+		}
+		s.applyLocked(e)
+		offset += int64(len(line))
+	}
+}
+
+// applyLocked применяет одну запись журнала; вызывается под блокировкой.
+func (s *Storage) applyLocked(e journalEntry) {
+	switch e.Op {
+	case opCreate:
+		if prev, ok := s.data[e.Record.ID]; ok {
+			s.unindexLocked(prev)
+		}
+		s.putLocked(e.Record)
+	case opDelete:
+		if rec, ok := s.data[e.Record.ID]; ok && rec.UserID == e.Record.UserID {
+			rec.Deleted = true
+			s.unindexLocked(rec)
+		}
+	case opClick:
+		if rec, ok := s.data[e.Record.ID]; ok {
+			rec.Clicks++
+		}
+	case opOwner:
+		if rec, ok := s.data[e.Record.ID]; ok {
+			s.unindexLocked(rec)
+			rec.UserID = e.Record.UserID
+			s.indexLocked(rec)
+		}
+	case opPurge:
+		if rec, ok := s.data[e.Record.ID]; ok {
+			s.unindexLocked(rec)
+			delete(s.data, e.Record.ID)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package deletion реализует фоновую очередь удаления ссылок: запросы
+// пользователей копятся и удаляются пачками по размеру или по времени.
+package deletion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"sync"
+	"time"
+)
+
+// ErrClosed возвращается при постановке в уже закрытую очередь.
+var ErrClosed = errors.New("deletion queue is closed")
+
+// maxRetryBackoff — предельная пауза между повторами неудачной пачки.
+const maxRetryBackoff = 30 * time.Second
+
+// Sink удаляет пачку ссылок разных пользователей; реализуется services.URLService.
+type Sink interface {
+	DeleteMany(ctx context.Context, items []store.Deletion) error
+}
+
+// Options задает параметры очереди.
+type Options struct {
+	// Workers — число воркеров, пишущих пачки в хранилище.
+	Workers int
+	// BatchSize — максимальный размер пачки.
+	BatchSize int
+	// FlushInterval — максимальное время ожидания неполной пачки.
+	FlushInterval time.Duration
+	// QueueSize — емкость буфера очереди.
+	QueueSize int
+	// FlushTimeout — таймаут записи одной пачки.
+	FlushTimeout time.Duration
+	// RetryBackoff — пауза перед первым повтором неудачной пачки; дальше она
+	// удваивается до maxRetryBackoff.
+	RetryBackoff time.Duration
+	// Path — файл для сохранения незавершенных удалений; пусто — без сохранения.
+	Path string
+}
+
+// Queue — очередь удаления с пулом воркеров.
+type Queue struct {
+	sink Sink
+	opts Options
+	in   chan entry
+	stop chan struct{}
+	// closing закрывается в начале Close, до захвата mu: отправители,
+	// ждущие места в буфере под mu.RLock, прекращают ждать и отпускают его
+	closing   chan struct{}
+	closeOnce sync.Once
+	wal       *wal
+	workers   sync.WaitGroup
+	mu        sync.RWMutex
+	closed    bool
+}
+
+// NewQueue создает очередь и запускает воркеров. Если задан Path, удаления,
+// не выполненные до прошлой остановки, загружаются из файла и ставятся в очередь.
+func NewQueue(sink Sink, opts Options) (*Queue, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 500 * time.Millisecond
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = 10 * time.Second
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 100 * time.Millisecond
+	}
+
+	q := &Queue{
+		sink:    sink,
+		opts:    opts,
+		in:      make(chan entry, opts.QueueSize),
+		stop:    make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+
+	var restored []entry
+	if opts.Path != "" {
+		w, items, err := openWAL(opts.Path)
+		if err != nil {
+			return nil, err
+		}
+		q.wal = w
+		restored = items
+	}
+
+	for i := 0; i < opts.Workers; i++ {
+		q.workers.Add(1)
+		go q.worker()
+	}
+
+	if len(restored) > 0 {
+		logger.Log.Info(&message.LogMessage{Message: fmt.Sprintf("Deletion queue: restored %d pending deletes", len(restored))})
+		go func() {
+			q.mu.RLock()
+			defer q.mu.RUnlock()
+			if q.closed {
+				// остались в файле до следующего запуска
+				return
+			}
+			for _, it := range restored {
+				select {
+				case q.in <- it:
+				case <-q.closing:
+					// не попавшие в очередь остаются в файле до следующего запуска
+					return
+				}
+			}
+		}()
+	}
+	return q, nil
+}
+
+// Enqueue ставит удаление ссылок пользователя в очередь. Если буфер заполнен,
+// ждет освобождения места, отмены ctx или закрытия очереди.
+func (q *Queue) Enqueue(ctx context.Context, userID string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	items := make([]store.Deletion, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, store.Deletion{UserID: userID, ID: id})
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrClosed
+	}
+
+	var entries []entry
+	if q.wal != nil {
+		var err error
+		if entries, err = q.wal.append(items); err != nil {
+			return err
+		}
+	} else {
+		entries = make([]entry, len(items))
+		for i, it := range items {
+			entries[i] = entry{d: it}
+		}
+	}
+
+	for i, e := range entries {
+		select {
+		case q.in <- e:
+		case <-ctx.Done():
+			q.reject(entries[i:])
+			return ctx.Err()
+		case <-q.closing:
+			q.reject(entries[i:])
+			return ErrClosed
+		}
+	}
+	return nil
+}
+
+// reject снимает с журнала удаления, не попавшие в очередь.
+func (q *Queue) reject(entries []entry) {
+	if q.wal != nil {
+		q.wal.done(entries)
+	}
+}
+
+// Close перестает принимать удаления, дожидается записи всех накопленных
+// пачек и закрывает файл. Если ctx истек раньше, оставшиеся удаления
+// сохраняются в файле (если он задан) до следующего запуска.
+func (q *Queue) Close(ctx context.Context) error {
+	q.closeOnce.Do(func() { close(q.closing) })
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.in)
+	q.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		close(q.stop)
+		<-drained
+		err = ctx.Err()
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Deletion queue: not drained before shutdown, %d deletes left", len(q.in))})
+	}
+
+	if q.wal != nil {
+		if cerr := q.wal.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// worker собирает пачки из очереди и отправляет их в Sink.
+func (q *Queue) worker() {
+	defer q.workers.Done()
+
+	batch := make([]entry, 0, q.opts.BatchSize)
+	timer := time.NewTimer(q.opts.FlushInterval)
+	defer timer.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		q.flush(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case it, ok := <-q.in:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, it)
+			if len(batch) >= q.opts.BatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+			timer.Reset(q.opts.FlushInterval)
+		}
+	}
+}
+
+// flush записывает пачку, повторяя ее с растущей паузой, пока запись не
+// удастся или очередь не остановят; невыполненные удаления остаются в файле
+// до следующего запуска.
+func (q *Queue) flush(batch []entry) {
+	items := make([]store.Deletion, len(batch))
+	for i, e := range batch {
+		items[i] = e.d
+	}
+
+	backoff := q.opts.RetryBackoff
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), q.opts.FlushTimeout)
+		err := q.sink.DeleteMany(ctx, items)
+		cancel()
+		if err == nil {
+			if q.wal != nil {
+				q.wal.done(batch)
+			}
+			return
+		}
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Deletion queue: failed to delete %d URLs, retry in %s: %s", len(items), backoff, err)})
+
+		select {
+		case <-q.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package deletion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/store"
+)
+
+// recordingSink запоминает пришедшие пачки; пока fail выставлен, возвращает ошибку.
+// Если задан gate, каждая пачка ждет его закрытия.
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]store.Deletion
+	fail    bool
+	gate    chan struct{}
+}
+
+func (s *recordingSink) DeleteMany(_ context.Context, items []store.Deletion) error {
+	if s.gate != nil {
+		<-s.gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("db is down")
+	}
+	s.batches = append(s.batches, append([]store.Deletion(nil), items...))
+	return nil
+}
+
+func (s *recordingSink) setFail(fail bool) {
+	s.mu.Lock()
+	s.fail = fail
+	s.mu.Unlock()
+}
+
+func (s *recordingSink) all() []store.Deletion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []store.Deletion
+	for _, b := range s.batches {
+		res = append(res, b...)
+	}
+	return res
+}
+
+func TestMain(m *testing.M) {
+	logger.New("error")
+	os.Exit(m.Run())
+}
+
+func TestQueueBatchesAcrossUsers(t *testing.T) {
+	sink := &recordingSink{}
+	q, err := NewQueue(sink, Options{Workers: 1, BatchSize: 4, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, "u1", []string{"a", "b"}))
+	require.NoError(t, q.Enqueue(ctx, "u2", []string{"c", "d"}))
+
+	// пачка заполнилась по размеру, не дожидаясь таймера
+	require.Eventually(t, func() bool { return len(sink.all()) == 4 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, q.Close(ctx))
+
+	require.Len(t, sink.batches, 1)
+	assert.ElementsMatch(t, []store.Deletion{
+		{UserID: "u1", ID: "a"}, {UserID: "u1", ID: "b"},
+		{UserID: "u2", ID: "c"}, {UserID: "u2", ID: "d"},
+	}, sink.batches[0])
+}
+
+func TestQueueCloseDrainsPending(t *testing.T) {
+	sink := &recordingSink{}
+	q, err := NewQueue(sink, Options{Workers: 2, BatchSize: 100, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	require.NoError(t, q.Enqueue(context.Background(), "u1", []string{"a", "b", "c"}))
+	require.NoError(t, q.Close(context.Background()))
+
+	assert.Len(t, sink.all(), 3)
+	assert.ErrorIs(t, q.Enqueue(context.Background(), "u1", []string{"d"}), ErrClosed)
+}
+
+func TestQueueRestoresFailedDeletesFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deletes.jsonl")
+
+	failing := &recordingSink{fail: true}
+	q, err := NewQueue(failing, Options{BatchSize: 10, FlushInterval: time.Hour, Path: path})
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(context.Background(), "u1", []string{"a", "b"}))
+	// повторы не успевают до остановки
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Close(ctx), context.DeadlineExceeded)
+
+	// после перезапуска неудачные удаления повторяются, а журнал очищается
+	sink := &recordingSink{}
+	q, err = NewQueue(sink, Options{BatchSize: 2, FlushInterval: time.Hour, Path: path})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(sink.all()) == 2 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, q.Close(context.Background()))
+
+	assert.ElementsMatch(t, []store.Deletion{{UserID: "u1", ID: "a"}, {UserID: "u1", ID: "b"}}, sink.all())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestQueueRetriesFailedBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deletes.jsonl")
+	sink := &recordingSink{fail: true}
+	q, err := NewQueue(sink, Options{BatchSize: 2, FlushInterval: time.Hour, RetryBackoff: time.Millisecond, Path: path})
+	require.NoError(t, err)
+
+	require.NoError(t, q.Enqueue(context.Background(), "u1", []string{"a", "b"}))
+	time.Sleep(20 * time.Millisecond)
+	sink.setFail(false)
+
+	// пачка повторяется без перезапуска, после чего журнал очищается
+	require.Eventually(t, func() bool { return len(sink.all()) == 2 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, q.Close(context.Background()))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestQueueCancelledEnqueueLeavesJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deletes.jsonl")
+	sink := &recordingSink{gate: make(chan struct{})}
+	q, err := NewQueue(sink, Options{Workers: 1, BatchSize: 1, FlushInterval: time.Hour, QueueSize: 1, Path: path})
+	require.NoError(t, err)
+
+	// воркер занят первой пачкой, в буфере место на одно удаление:
+	// остальные не попадают в очередь до отмены
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Enqueue(ctx, "u1", []string{"a", "b", "c", "d"}), context.DeadlineExceeded)
+	close(sink.gate)
+	require.NoError(t, q.Close(context.Background()))
+
+	// принятые удаления выполнены, а не принятые не держат журнал
+	assert.ElementsMatch(t, []store.Deletion{{UserID: "u1", ID: "a"}, {UserID: "u1", ID: "b"}}, sink.all())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestQueueCloseWithRestoredBacklog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deletes.jsonl")
+	var journal []byte
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		line, err := json.Marshal(store.Deletion{UserID: "u1", ID: id})
+		require.NoError(t, err)
+		journal = append(append(journal, line...), '\n')
+	}
+	require.NoError(t, os.WriteFile(path, journal, 0644))
+
+	// восстановлено больше QueueSize, а хранилище недоступно: Close
+	// все равно завершается по дедлайну
+	sink := &recordingSink{fail: true}
+	q, err := NewQueue(sink, Options{Workers: 1, BatchSize: 1, QueueSize: 1, FlushInterval: time.Hour, RetryBackoff: time.Millisecond, Path: path})
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	closed := make(chan error, 1)
+	go func() { closed <- q.Close(ctx) }()
+	select {
+	case err := <-closed:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close hangs behind the restore goroutine")
+	}
+
+	// невыполненные удаления остались в журнале до следующего запуска
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, journal, data)
+}
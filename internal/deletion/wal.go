@@ -0,0 +1,168 @@
+package deletion
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// walMinRewrite — сколько строк должно накопиться в журнале, прежде чем он
+// будет переписан с одними незавершенными удалениями.
+const walMinRewrite = 1024
+
+// entry — удаление в очереди с номером его записи в журнале.
+type entry struct {
+	seq uint64
+	d   store.Deletion
+}
+
+// wal — журнал незавершенных удалений. Удаления дописываются при постановке
+// в очередь и отмечаются выполненными поштучно; файл очищается, когда
+// незавершенных не осталось, и переписывается с одними незавершенными, когда
+// выполненных в нем становится больше половины. Повторное удаление безопасно,
+// поэтому при сбое журнал просто проигрывается заново.
+type wal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	next    uint64
+	pending map[uint64]store.Deletion
+	// lines — число строк в файле журнала
+	lines int
+}
+
+// openWAL открывает журнал и возвращает удаления, не выполненные до прошлой остановки.
+// Оборванная последняя строка пропускается.
+func openWAL(path string) (*wal, []entry, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &wal{path: path, file: file, pending: make(map[uint64]store.Deletion)}
+	var items []entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		w.lines++
+		var d store.Deletion
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Deletion queue: skip broken record in %s: %s", path, err)})
+			continue
+		}
+		w.next++
+		w.pending[w.next] = d
+		items = append(items, entry{seq: w.next, d: d})
+	}
+	if err := scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return w, items, nil
+}
+
+// append сохраняет удаления на диск до постановки в очередь и возвращает их
+// с номерами записей.
+func (w *wal) append(items []store.Deletion) ([]entry, error) {
+	buf := make([]byte, 0, 64*len(items))
+	for _, it := range items {
+		line, err := json.Marshal(it)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.file.Write(buf); err != nil {
+		return nil, err
+	}
+	if err := w.file.Sync(); err != nil {
+		return nil, err
+	}
+	w.lines += len(items)
+	entries := make([]entry, len(items))
+	for i, it := range items {
+		w.next++
+		w.pending[w.next] = it
+		entries[i] = entry{seq: w.next, d: it}
+	}
+	return entries, nil
+}
+
+// done отмечает удаления выполненными (или снятыми с очереди) и сжимает журнал.
+func (w *wal) done(entries []entry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, e := range entries {
+		delete(w.pending, e.seq)
+	}
+
+	var err error
+	switch {
+	case len(w.pending) == 0:
+		if err = w.file.Truncate(0); err == nil {
+			w.lines = 0
+		}
+	case w.lines >= walMinRewrite && w.lines > 2*len(w.pending):
+		err = w.rewriteLocked()
+	}
+	if err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Deletion queue: compact journal ERROR: %s", err)})
+	}
+}
+
+// rewriteLocked атомарно заменяет файл журнала незавершенными удалениями;
+// вызывается под мьютексом. Дескриптор нового файла остается открытым после
+// rename, так что дозапись продолжается в него.
+func (w *wal) rewriteLocked() error {
+	tmp := w.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, d := range w.pending {
+		if err := enc.Encode(d); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		f.Close()
+		return err
+	}
+	_ = w.file.Close()
+	w.file = f
+	w.lines = len(w.pending)
+	return nil
+}
+
+// close закрывает файл журнала.
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
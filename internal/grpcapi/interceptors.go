@@ -0,0 +1,122 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/grpcapi/pb"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+// AuthorizationKey — ключ метаданных с JWT пользователя.
+const AuthorizationKey = "authorization"
+
+// bearerPrefix — префикс значения AuthorizationKey.
+const bearerPrefix = "Bearer "
+
+// LoggingInterceptor пишет в access-лог метод, код ответа и длительность вызова.
+func LoggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	timeStart := time.Now()
+	resp, err := handler(ctx, req)
+
+	st := status.Convert(err)
+	body := st.Message()
+	if st.Code() == codes.OK {
+		body = "OK"
+	}
+	logger.Logging.WriteToLog(timeStart, info.FullMethod, "GRPC", int(st.Code()), body)
+	return resp, err
+}
+
+// methodAuth — требования метода к аутентификации: политика и право API-ключа.
+type methodAuth struct {
+	policy auth.Policy
+	// scope — право, которое должно быть у API-ключа; пустое — не проверяется
+	scope auth.Scope
+}
+
+// defaultMethodAuth — требования методов, повторяющие политики групп
+// HTTP-маршрутов и права RequireScope; неизвестные методы требуют пользователя.
+var defaultMethodAuth = map[string]methodAuth{
+	pb.Shortener_Shorten_FullMethodName:        {policy: auth.PolicyOptional, scope: auth.ScopeShorten},
+	pb.Shortener_ShortenBatch_FullMethodName:   {policy: auth.PolicyOptional, scope: auth.ScopeShorten},
+	pb.Shortener_Expand_FullMethodName:         {policy: auth.PolicyOptional},
+	pb.Shortener_Ping_FullMethodName:           {policy: auth.PolicyOptional},
+	pb.Shortener_ListUserURLs_FullMethodName:   {policy: auth.PolicyRequired, scope: auth.ScopeRead},
+	pb.Shortener_DeleteUserURLs_FullMethodName: {policy: auth.PolicyRequired, scope: auth.ScopeDelete},
+}
+
+// AuthInterceptor создает интерцептор, определяющий пользователя вызова, как
+// auth.Identify и политики маршрутов делают это для HTTP. Метаданные
+// AuthorizationKey принимают JWT и, если задан keys, API-ключ; неверные
+// учетные данные отклоняются с Unauthenticated. Без них методы с
+// PolicyOptional создают нового пользователя и отправляют его токен в
+// заголовке ответа, а методы с PolicyRequired отклоняются с Unauthenticated.
+// Туда же отправляется продленный JWT, если текущий скоро истечет.
+func AuthInterceptor(keys auth.KeyAuthenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ma, ok := defaultMethodAuth[info.FullMethod]
+		if !ok {
+			ma = methodAuth{policy: auth.PolicyRequired}
+		}
+
+		var userID, token string
+		var scopes []auth.Scope
+		var claims *auth.Claims
+		var err error
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(AuthorizationKey); len(v) > 0 {
+				userID, scopes, claims, err = auth.Authenticate(ctx, keys, strings.TrimSpace(strings.TrimPrefix(v[0], bearerPrefix)))
+			}
+		}
+
+		switch {
+		case errors.Is(err, auth.ErrRevocationUnavailable):
+			// список отозванных сессий недоступен: публичные методы
+			// обслуживаются анонимно, без замены токена клиента
+			if ma.policy != auth.PolicyOptional {
+				return nil, status.Error(codes.Unavailable, "service unavailable")
+			}
+			if userID, _, err = auth.NewUser(); err != nil {
+				return nil, status.Error(codes.Internal, "failed to issue token")
+			}
+		case err != nil:
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		case userID == "" && ma.policy == auth.PolicyRequired:
+			return nil, status.Error(codes.Unauthenticated, "authentication required")
+		case userID == "" && ma.policy == auth.PolicyOptional:
+			if userID, token, err = auth.NewUser(); err != nil {
+				return nil, status.Error(codes.Internal, "failed to issue token")
+			}
+		case claims != nil && auth.NeedsRefresh(claims):
+			if token, _, err = auth.Refresh(claims); err != nil {
+				token = ""
+			}
+		}
+
+		if token != "" {
+			if err := grpc.SetHeader(ctx, metadata.Pairs(AuthorizationKey, bearerPrefix+token)); err != nil {
+				return nil, status.Error(codes.Internal, "failed to send token")
+			}
+		}
+		if claims != nil {
+			ctx = auth.WithClaims(ctx, claims)
+		}
+		if scopes != nil {
+			ctx = auth.WithScopes(ctx, scopes)
+		}
+		if ma.scope != "" && !auth.HasScope(ctx, ma.scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "scope %q is required", ma.scope)
+		}
+		if userID != "" {
+			ctx = auth.WithUserID(ctx, userID)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,267 @@
+// Package grpcapi реализует gRPC API сервиса поверх services.URLService.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/apikeys"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/db/postgres"
+	"github.com/zauremazhikovayandex/url/internal/deletion"
+	"github.com/zauremazhikovayandex/url/internal/grpcapi/pb"
+	"github.com/zauremazhikovayandex/url/internal/links"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/services"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strings"
+	"time"
+)
+
+// Server реализует pb.ShortenerServer.
+type Server struct {
+	pb.UnimplementedShortenerServer
+	urlService services.URLService
+	deletes    *deletion.Queue
+	idGen      links.IDGenerator
+	apiKeys    *apikeys.Service
+}
+
+// Option настраивает необязательные зависимости Server.
+type Option func(*Server)
+
+// WithDeleteQueue включает асинхронное удаление ссылок через очередь q.
+func WithDeleteQueue(q *deletion.Queue) Option {
+	return func(s *Server) {
+		s.deletes = q
+	}
+}
+
+// WithIDGenerator задает стратегию генерации коротких id.
+func WithIDGenerator(gen links.IDGenerator) Option {
+	return func(s *Server) {
+		s.idGen = gen
+	}
+}
+
+// WithAPIKeys включает вход по API-ключам с проверкой их прав.
+func WithAPIKeys(keys *apikeys.Service) Option {
+	return func(s *Server) {
+		s.apiKeys = keys
+	}
+}
+
+// NewServer создает gRPC-сервер с интерцепторами логирования и аутентификации
+// и регистрирует на нем сервис Shortener.
+func NewServer(urlService services.URLService, opts ...Option) *grpc.Server {
+	s := &Server{urlService: urlService, idGen: links.NewRandomGenerator(8)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	var keyAuth auth.KeyAuthenticator
+	if s.apiKeys != nil {
+		keyAuth = s.apiKeys
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(LoggingInterceptor, AuthInterceptor(keyAuth)))
+	pb.RegisterShortenerServer(srv, s)
+	return srv
+}
+
+// shortURL формирует короткую ссылку по id.
+func shortURL(id string) string {
+	return fmt.Sprintf("%s/%s", config.AppConfig.BaseURL, id)
+}
+
+// Shorten сокращает одну ссылку.
+func (s *Server) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	userID := auth.GetUserID(ctx)
+
+	originalURL := strings.TrimSpace(req.GetUrl())
+	if !links.IsValidURL(originalURL) {
+		return nil, status.Error(codes.InvalidArgument, "invalid URL format")
+	}
+
+	var expiresAt *time.Time
+	if req.GetExpiresAt() != nil {
+		t := req.GetExpiresAt().AsTime()
+		expiresAt = &t
+	}
+	maxClicks := int(req.GetMaxClicks())
+	expiresAt, err := links.ResolveExpiry(expiresAt, req.GetTtlSeconds(), maxClicks, time.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	alias := strings.TrimSpace(req.GetAlias())
+	if alias != "" {
+		if err := links.ValidateAlias(alias); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	id, err := s.saveURL(ctx, store.URL{
+		ID:          alias,
+		OriginalURL: originalURL,
+		UserID:      userID,
+		ExpiresAt:   expiresAt,
+		MaxClicks:   maxClicks,
+	})
+	if errors.Is(err, services.ErrIDGeneration) {
+		logger.Log.Error(&message.LogMessage{Message: err.Error()})
+		return nil, status.Error(codes.Internal, "failed to generate short ID")
+	}
+	if err != nil {
+		if alias != "" && errors.Is(err, store.ErrIDConflict) {
+			return nil, status.Error(codes.AlreadyExists, links.ErrAliasTaken.Error())
+		}
+		return nil, s.insertError(ctx, originalURL, err)
+	}
+	return &pb.ShortenResponse{Result: shortURL(id)}, nil
+}
+
+// saveURL сохраняет ссылку под алиасом u.ID, а без алиаса — под сгенерированным id.
+func (s *Server) saveURL(ctx context.Context, u store.URL) (string, error) {
+	if u.ID != "" {
+		return u.ID, s.urlService.SaveURL(ctx, u)
+	}
+	return services.SaveWithGeneratedID(ctx, s.urlService, s.idGen, u)
+}
+
+// insertError приводит ошибку сохранения к статусу gRPC; для уже
+// сокращенного URL в сообщении возвращается существующая короткая ссылка.
+func (s *Server) insertError(ctx context.Context, originalURL string, err error) error {
+	if errors.Is(err, store.ErrDuplicateURL) {
+		existingID, getErr := s.urlService.GetShortIDByOriginalURL(ctx, auth.GetUserID(ctx), originalURL)
+		if getErr != nil || existingID == "" {
+			return status.Error(codes.AlreadyExists, "url already shortened")
+		}
+		return status.Error(codes.AlreadyExists, shortURL(existingID))
+	}
+	logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Storage ERROR: %s", err)})
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// ShortenBatch сокращает набор ссылок и возвращает статус каждого элемента:
+// created или exists с короткой ссылкой, invalid или error с причиной.
+func (s *Server) ShortenBatch(ctx context.Context, req *pb.ShortenBatchRequest) (*pb.ShortenBatchResponse, error) {
+	if len(req.GetItems()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty batch")
+	}
+
+	items := make([]services.BatchItem, len(req.GetItems()))
+	for i, item := range req.GetItems() {
+		items[i] = services.BatchItem{CorrelationID: item.GetCorrelationId(), OriginalURL: item.GetOriginalUrl(), Alias: item.GetAlias()}
+	}
+
+	results, err := services.ShortenBatch(ctx, s.urlService, s.idGen, auth.GetUserID(ctx), items)
+	if err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Batch storage ERROR: %s", err)})
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	resp := &pb.ShortenBatchResponse{Items: make([]*pb.ShortenBatchResponse_Item, len(results))}
+	for i, res := range results {
+		item := &pb.ShortenBatchResponse_Item{CorrelationId: res.CorrelationID, Status: res.Status, Error: res.Reason}
+		if res.OK() {
+			item.ShortUrl = shortURL(res.ID)
+		}
+		resp.Items[i] = item
+	}
+	return resp, nil
+}
+
+// Expand возвращает исходный URL по короткому id, не засчитывая переход.
+func (s *Server) Expand(ctx context.Context, req *pb.ExpandRequest) (*pb.ExpandResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing ID")
+	}
+	u, err := s.urlService.GetURL(ctx, req.GetId())
+	switch {
+	case errors.Is(err, store.ErrDeleted):
+		return nil, status.Error(codes.FailedPrecondition, "URL deleted")
+	case errors.Is(err, store.ErrExpired):
+		return nil, status.Error(codes.FailedPrecondition, "URL expired")
+	case errors.Is(err, store.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "URL not found")
+	case err != nil:
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Storage ERROR: %s", err)})
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &pb.ExpandResponse{OriginalUrl: u.OriginalURL}, nil
+}
+
+// ListUserURLs возвращает страницу ссылок текущего пользователя; размер
+// страницы ограничен так же, как в HTTP API.
+func (s *Server) ListUserURLs(ctx context.Context, req *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	opts := store.ListOptions{Limit: store.DefaultPageSize}
+	if n := req.GetLimit(); n != 0 {
+		if n < 1 || n > store.MaxPageSize {
+			return nil, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", store.MaxPageSize)
+		}
+		opts.Limit = int(n)
+	}
+	if req.GetCursor() != "" {
+		c, err := store.ParseCursor(req.GetCursor())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		opts.After = c
+	}
+
+	page, err := s.urlService.GetURLsByUserID(ctx, auth.GetUserID(ctx), opts)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "server error")
+	}
+
+	resp := &pb.ListUserURLsResponse{}
+	if page.Next != nil {
+		resp.NextCursor = page.Next.String()
+	}
+	for _, u := range page.URLs {
+		pair := &pb.URLPair{
+			ShortUrl:    shortURL(u.ID),
+			OriginalUrl: u.OriginalURL,
+			MaxClicks:   int32(u.MaxClicks),
+			Clicks:      u.Clicks,
+		}
+		if u.ExpiresAt != nil {
+			pair.ExpiresAt = timestamppb.New(*u.ExpiresAt)
+		}
+		resp.Urls = append(resp.Urls, pair)
+	}
+	return resp, nil
+}
+
+// DeleteUserURLs помечает ссылки текущего пользователя удаленными.
+func (s *Server) DeleteUserURLs(ctx context.Context, req *pb.DeleteUserURLsRequest) (*pb.DeleteUserURLsResponse, error) {
+	if len(req.GetIds()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty ID list")
+	}
+	if s.deletes != nil {
+		if err := s.deletes.Enqueue(ctx, auth.GetUserID(ctx), req.GetIds()); err != nil {
+			logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Failed to enqueue URLs deletion: %s", err)})
+			return nil, status.Errorf(codes.Unavailable, "failed to enqueue URLs deletion: %s", err)
+		}
+		return &pb.DeleteUserURLsResponse{}, nil
+	}
+	if err := s.urlService.BatchDelete(ctx, req.GetIds(), auth.GetUserID(ctx)); err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Failed to mark URLs as deleted: %s", err)})
+		return nil, status.Errorf(codes.Internal, "failed to mark URLs as deleted: %s", err)
+	}
+	return &pb.DeleteUserURLsResponse{}, nil
+}
+
+// Ping проверяет доступность подключения к БД.
+func (s *Server) Ping(_ context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
+	conn, err := postgres.SQLInstance()
+	if conn == nil || err != nil {
+		return nil, status.Error(codes.Unavailable, "fail DB connection")
+	}
+	return &pb.PingResponse{}, nil
+}
@@ -0,0 +1,218 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/apikeys"
+	"github.com/zauremazhikovayandex/url/internal/auth"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/grpcapi/pb"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"github.com/zauremazhikovayandex/url/internal/services"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type discardDriver struct{}
+
+func (discardDriver) Debug(*message.LogMessage) {}
+func (discardDriver) Info(*message.LogMessage)  {}
+func (discardDriver) Warn(*message.LogMessage)  {}
+func (discardDriver) Error(*message.LogMessage) {}
+func (discardDriver) Fatal(*message.LogMessage) {}
+func (discardDriver) Panic(*message.LogMessage) {}
+
+type noopAccessLogger struct{}
+
+func (noopAccessLogger) WriteToLog(time.Time, string, string, int, string) {}
+
+// startServer поднимает сервер на bufconn поверх хранилища в памяти и возвращает клиента.
+func startServer(t *testing.T) pb.ShortenerClient {
+	client, _ := startServerWithKeys(t)
+	return client
+}
+
+// startServerWithKeys поднимает сервер с API-ключами и возвращает клиента и сервис ключей.
+func startServerWithKeys(t *testing.T) (pb.ShortenerClient, *apikeys.Service) {
+	t.Helper()
+
+	prevCfg, prevLog, prevLogging := config.AppConfig, logger.Log, logger.Logging
+	config.AppConfig = &config.Config{
+		BaseURL:       "http://localhost:8080",
+		PGConfig:      &config.PostgresConfig{DBTimeout: 10},
+		StorageType:   "Memory",
+		JWTSecretKey:  "grpc-test-secret-0123456789abcdef",
+		JWTTokenExp:   time.Hour,
+		JWTCookieName: "auth_token",
+	}
+	logger.Log = discardDriver{}
+	logger.Logging = noopAccessLogger{}
+
+	lis := bufconn.Listen(1 << 20)
+	keys := apikeys.NewService(apikeys.NewMemoryStore())
+	srv := NewServer(services.NewURLService(store.NewMemoryStore()), WithAPIKeys(keys))
+	go func() { _ = srv.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+		config.AppConfig, logger.Log, logger.Logging = prevCfg, prevLog, prevLogging
+	})
+	return pb.NewShortenerClient(conn), keys
+}
+
+// login выполняет публичный вызов без токена и возвращает контекст с выданным сервером JWT.
+func login(t *testing.T, client pb.ShortenerClient) context.Context {
+	t.Helper()
+	var header metadata.MD
+	_, err := client.Expand(context.Background(), &pb.ExpandRequest{Id: "login"}, grpc.Header(&header))
+	require.Equal(t, codes.NotFound, status.Code(err))
+	tokens := header.Get(AuthorizationKey)
+	require.Len(t, tokens, 1)
+	return metadata.AppendToOutgoingContext(context.Background(), AuthorizationKey, tokens[0])
+}
+
+func TestShortenAndExpand(t *testing.T) {
+	client := startServer(t)
+	ctx := login(t, client)
+
+	resp, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com", Alias: "promo"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/promo", resp.GetResult())
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.Equal(t, "http://localhost:8080/promo", status.Convert(err).Message())
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "not a url"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	exp, err := client.Expand(ctx, &pb.ExpandRequest{Id: "promo"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", exp.GetOriginalUrl())
+
+	_, err = client.Expand(ctx, &pb.ExpandRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestShortenBatchListAndDelete(t *testing.T) {
+	client := startServer(t)
+	ctx := login(t, client)
+
+	batch, err := client.ShortenBatch(ctx, &pb.ShortenBatchRequest{Items: []*pb.ShortenBatchRequest_Item{
+		{CorrelationId: "1", OriginalUrl: "https://a.example"},
+		{CorrelationId: "2", OriginalUrl: "https://b.example", Alias: "bee"},
+		{CorrelationId: "3", OriginalUrl: "bad"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, batch.GetItems(), 3)
+	assert.Equal(t, "created", batch.GetItems()[0].GetStatus())
+	assert.Equal(t, "http://localhost:8080/bee", batch.GetItems()[1].GetShortUrl())
+	assert.Equal(t, "invalid", batch.GetItems()[2].GetStatus())
+	assert.Empty(t, batch.GetItems()[2].GetShortUrl())
+	assert.NotEmpty(t, batch.GetItems()[2].GetError())
+
+	list, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.GetUrls(), 2)
+
+	// другой пользователь не видит чужие ссылки и не может их удалить
+	other := login(t, client)
+	list, err = client.ListUserURLs(other, &pb.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, list.GetUrls())
+	_, err = client.DeleteUserURLs(other, &pb.DeleteUserURLsRequest{Ids: []string{"bee"}})
+	require.NoError(t, err)
+	_, err = client.Expand(ctx, &pb.ExpandRequest{Id: "bee"})
+	require.NoError(t, err)
+
+	_, err = client.DeleteUserURLs(ctx, &pb.DeleteUserURLsRequest{Ids: []string{"bee"}})
+	require.NoError(t, err)
+	_, err = client.Expand(ctx, &pb.ExpandRequest{Id: "bee"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestAuthInterceptorPolicies(t *testing.T) {
+	client := startServer(t)
+
+	// пользовательские методы не создают пользователя
+	var header metadata.MD
+	_, err := client.ListUserURLs(context.Background(), &pb.ListUserURLsRequest{}, grpc.Header(&header))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Empty(t, header.Get(AuthorizationKey))
+	_, err = client.DeleteUserURLs(context.Background(), &pb.DeleteUserURLsRequest{Ids: []string{"a"}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// неверный токен отклоняется и на публичных методах
+	bad := metadata.AppendToOutgoingContext(context.Background(), AuthorizationKey, "Bearer garbage")
+	_, err = client.Shorten(bad, &pb.ShortenRequest{Url: "https://example.com"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// с валидным токеном новый не выдается
+	ctx := login(t, client)
+	header = nil
+	_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Empty(t, header.Get(AuthorizationKey))
+}
+
+func TestAuthInterceptorAPIKeyScopes(t *testing.T) {
+	client, keys := startServerWithKeys(t)
+
+	_, secret, err := keys.Create(context.Background(), "owner", "ci", []auth.Scope{auth.ScopeShorten})
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), AuthorizationKey, "Bearer "+secret)
+
+	var header metadata.MD
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com", Alias: "ci-key"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Empty(t, header.Get(AuthorizationKey))
+
+	// ссылка принадлежит владельцу ключа, но читать ее ключом без права read нельзя
+	_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.DeleteUserURLs(ctx, &pb.DeleteUserURLsRequest{Ids: []string{"ci-key"}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestListUserURLsPages(t *testing.T) {
+	client := startServer(t)
+	ctx := login(t, client)
+
+	for _, alias := range []string{"one", "two", "three"} {
+		_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://" + alias + ".example", Alias: alias})
+		require.NoError(t, err)
+	}
+
+	first, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, first.GetUrls(), 2)
+	require.NotEmpty(t, first.GetNextCursor())
+
+	second, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{Limit: 2, Cursor: first.GetNextCursor()})
+	require.NoError(t, err)
+	assert.Len(t, second.GetUrls(), 1)
+	assert.Empty(t, second.GetNextCursor())
+
+	_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{Limit: 5000})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{Cursor: "garbage"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
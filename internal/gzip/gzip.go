@@ -10,16 +10,36 @@ import (
 // compressWriter реализует интерфейс http.ResponseWriter и позволяет прозрачно для сервера
 // сжимать передаваемые данные и выставлять правильные HTTP-заголовки
 type compressWriter struct {
-	w  http.ResponseWriter
-	zw *gzip.Writer
+	w          http.ResponseWriter
+	zw         *gzip.Writer
+	raw        int64
+	compressed int64
+}
+
+// countingWriter считает байты, записанные в w.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+// Write записывает p в w и увеличивает счетчик.
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
 }
 
 // NewCompressWriter возвращает http.ResponseWriter, сжимающий ответ gzip'ом.
 func NewCompressWriter(w http.ResponseWriter) *compressWriter {
-	return &compressWriter{
-		w:  w,
-		zw: gzip.NewWriter(w),
-	}
+	c := &compressWriter{w: w}
+	c.zw = gzip.NewWriter(countingWriter{w: w, n: &c.compressed})
+	return c
+}
+
+// Sizes возвращает размер ответа до и после сжатия; полные значения
+// доступны после Close.
+func (c *compressWriter) Sizes() (raw int64, compressed int64) {
+	return c.raw, c.compressed
 }
 
 // Header возвращает заголовки исходного http.ResponseWriter.
@@ -29,7 +49,9 @@ func (c *compressWriter) Header() http.Header {
 
 // Write записывает несжатые данные p, сжимая их во внутренний gzip.Writer.
 func (c *compressWriter) Write(p []byte) (int, error) {
-	return c.zw.Write(p)
+	n, err := c.zw.Write(p)
+	c.raw += int64(n)
+	return n, err
 }
 
 // WriteHeader устанавливает статус ответа и проставляет Content-Encoding: gzip
@@ -41,6 +63,20 @@ func (c *compressWriter) WriteHeader(statusCode int) {
 	c.w.WriteHeader(statusCode)
 }
 
+// Flush досылает клиенту уже сжатые данные, не закрывая поток; нужен для
+// потоковых ответов.
+func (c *compressWriter) Flush() {
+	if err := c.zw.Flush(); err != nil {
+		return
+	}
+	_ = http.NewResponseController(c.w).Flush()
+}
+
+// Unwrap возвращает исходный http.ResponseWriter для http.ResponseController.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.w
+}
+
 // Close закрывает внутренний gzip.Writer, досылая все буферизированные данные.
 func (c *compressWriter) Close() error {
 	return c.zw.Close()
@@ -0,0 +1,48 @@
+// Package links содержит правила создания коротких ссылок, общие для HTTP и gRPC API.
+package links
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Ограничения на длину пользовательского алиаса.
+const (
+	minAliasLen = 3
+	maxAliasLen = 64
+)
+
+// reservedAliases — алиасы, совпадающие с маршрутами сервиса или
+// зарезервированные под них; проверяются без учета регистра.
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"debug":   {},
+	"metrics": {},
+	"health":  {},
+	"static":  {},
+	"admin":   {},
+}
+
+// ErrAliasTaken сигнализирует о занятом алиасе.
+var ErrAliasTaken = errors.New("alias is already taken")
+
+// ValidateAlias проверяет алиас: допустимы латинские буквы, цифры, '-' и '_',
+// длина от minAliasLen до maxAliasLen, без зарезервированных слов.
+func ValidateAlias(alias string) error {
+	if len(alias) < minAliasLen || len(alias) > maxAliasLen {
+		return fmt.Errorf("alias length must be between %d and %d", minAliasLen, maxAliasLen)
+	}
+	for _, c := range alias {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return fmt.Errorf("alias contains invalid character %q", c)
+		}
+	}
+	if _, ok := reservedAliases[strings.ToLower(alias)]; ok {
+		return fmt.Errorf("alias %q is reserved", alias)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package links
+
+import (
+	"errors"
+	"time"
+)
+
+// ResolveExpiry вычисляет момент истечения ссылки из expires_at или ttl_seconds
+// и проверяет лимит переходов. Одновременно задать expires_at и ttl_seconds нельзя.
+func ResolveExpiry(expiresAt *time.Time, ttlSeconds int64, maxClicks int, now time.Time) (*time.Time, error) {
+	if maxClicks < 0 {
+		return nil, errors.New("max_clicks must be positive")
+	}
+	switch {
+	case expiresAt != nil && ttlSeconds != 0:
+		return nil, errors.New("expires_at and ttl_seconds are mutually exclusive")
+	case ttlSeconds < 0:
+		return nil, errors.New("ttl_seconds must be positive")
+	case ttlSeconds > 0:
+		t := now.Add(time.Duration(ttlSeconds) * time.Second).UTC()
+		return &t, nil
+	case expiresAt != nil:
+		if !expiresAt.After(now) {
+			return nil, errors.New("expires_at must be in the future")
+		}
+		t := expiresAt.UTC()
+		return &t, nil
+	}
+	return nil, nil
+}
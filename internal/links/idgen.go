@@ -0,0 +1,204 @@
+package links
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Стратегии генерации коротких идентификаторов.
+const (
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+	StrategyHash    = "hash"
+	StrategySqids   = "sqids"
+)
+
+// base62 — алфавит идентификаторов без символов, требующих экранирования в URL.
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// IDGenerator выдает короткие идентификаторы. attempt — номер попытки для
+// одной ссылки: при коллизии вызывающий повторяет вызов с attempt+1, и
+// детерминированные стратегии обязаны выдать другой id.
+type IDGenerator interface {
+	Generate(ctx context.Context, originalURL string, attempt int) (string, error)
+}
+
+// CounterSource выдает следующее значение монотонного счетчика.
+type CounterSource interface {
+	Next(ctx context.Context) (uint64, error)
+}
+
+// AtomicCounter — счетчик в памяти процесса.
+type AtomicCounter struct {
+	n atomic.Uint64
+}
+
+// NewAtomicCounter создает счетчик, первое значение которого равно start+1.
+func NewAtomicCounter(start uint64) *AtomicCounter {
+	c := &AtomicCounter{}
+	c.n.Store(start)
+	return c
+}
+
+// Next возвращает следующее значение счетчика.
+func (c *AtomicCounter) Next(context.Context) (uint64, error) {
+	return c.n.Add(1), nil
+}
+
+// CounterFunc позволяет использовать функцию (например, nextval последовательности) как CounterSource.
+type CounterFunc func(ctx context.Context) (uint64, error)
+
+// Next вызывает f.
+func (f CounterFunc) Next(ctx context.Context) (uint64, error) {
+	return f(ctx)
+}
+
+// encodeBase62 кодирует n алфавитом alphabet.
+func encodeBase62(n uint64, alphabet string) string {
+	if n == 0 {
+		return alphabet[:1]
+	}
+	var buf [11]byte
+	i := len(buf)
+	base := uint64(len(alphabet))
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf[i:])
+}
+
+// RandomGenerator выдает криптографически случайные base62-строки длины Length.
+type RandomGenerator struct {
+	Length int
+}
+
+// NewRandomGenerator создает генератор случайных id длины length.
+func NewRandomGenerator(length int) *RandomGenerator {
+	return &RandomGenerator{Length: length}
+}
+
+// Generate возвращает случайный id; attempt не используется.
+func (g *RandomGenerator) Generate(context.Context, string, int) (string, error) {
+	max := big.NewInt(int64(len(base62)))
+	b := make([]byte, g.Length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = base62[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// CounterGenerator выдает значения счетчика в base62.
+type CounterGenerator struct {
+	counter CounterSource
+}
+
+// NewCounterGenerator создает генератор поверх счетчика.
+func NewCounterGenerator(counter CounterSource) *CounterGenerator {
+	return &CounterGenerator{counter: counter}
+}
+
+// Generate берет следующее значение счетчика; при коллизии повтор дает следующее.
+func (g *CounterGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	n, err := g.counter.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encodeBase62(n, base62), nil
+}
+
+// HashGenerator выдает префикс SHA-256 от URL длины Length: одна и та же
+// ссылка получает один и тот же id, а при коллизии к URL добавляется номер попытки.
+type HashGenerator struct {
+	Length int
+}
+
+// NewHashGenerator создает генератор id из хеша URL.
+func NewHashGenerator(length int) *HashGenerator {
+	return &HashGenerator{Length: length}
+}
+
+// Generate возвращает id, вычисленный из originalURL и attempt.
+func (g *HashGenerator) Generate(_ context.Context, originalURL string, attempt int) (string, error) {
+	input := originalURL
+	if attempt > 0 {
+		input += "#" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+
+	var sb strings.Builder
+	for i := 0; sb.Len() < g.Length && i+8 <= len(sum); i += 8 {
+		sb.WriteString(encodeBase62(binary.BigEndian.Uint64(sum[i:i+8]), base62))
+	}
+	id := sb.String()
+	if len(id) > g.Length {
+		id = id[:g.Length]
+	}
+	return id, nil
+}
+
+// sqidsBits — разрядность, в которой перемешиваются значения счетчика.
+const sqidsBits = 48
+
+// sqidsMultiplier — нечетный множитель; умножение на него по модулю 2^48 обратимо.
+const sqidsMultiplier = 0x5DEECE66D
+
+// SqidsGenerator выдает значения счетчика, перемешанные в стиле Hashids/Sqids:
+// соседние значения дают непохожие id, а алфавит переставлен по соли.
+type SqidsGenerator struct {
+	counter   CounterSource
+	alphabet  string
+	mask      uint64
+	minLength int
+}
+
+// NewSqidsGenerator создает генератор поверх счетчика с солью salt и минимальной длиной id.
+func NewSqidsGenerator(counter CounterSource, salt string, minLength int) *SqidsGenerator {
+	sum := sha256.Sum256([]byte(salt))
+	return &SqidsGenerator{
+		counter:   counter,
+		alphabet:  shuffle(base62, sum[:]),
+		mask:      binary.BigEndian.Uint64(sum[8:16]) & (1<<sqidsBits - 1),
+		minLength: minLength,
+	}
+}
+
+// Generate берет следующее значение счетчика и обратимо перемешивает его.
+func (g *SqidsGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	n, err := g.counter.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+	if n >= 1<<sqidsBits {
+		return "", fmt.Errorf("counter %d exceeds %d bits", n, sqidsBits)
+	}
+	scrambled := (n*sqidsMultiplier)&(1<<sqidsBits-1) ^ g.mask
+
+	id := encodeBase62(scrambled, g.alphabet)
+	if pad := g.minLength - len(id); pad > 0 {
+		id = strings.Repeat(g.alphabet[:1], pad) + id
+	}
+	return id, nil
+}
+
+// shuffle детерминированно переставляет алфавит по seed (Фишер — Йетс).
+func shuffle(alphabet string, seed []byte) string {
+	b := []byte(alphabet)
+	for i := len(b) - 1; i > 0; i-- {
+		j := int(seed[i%len(seed)]+byte(i)) % (i + 1)
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
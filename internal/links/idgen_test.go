@@ -0,0 +1,85 @@
+package links
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isBase62 проверяет, что id состоит только из символов base62.
+func isBase62(id string) bool {
+	for _, c := range id {
+		if !strings.ContainsRune(base62, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRandomGenerator(t *testing.T) {
+	gen := NewRandomGenerator(10)
+	seen := make(map[string]struct{})
+	for i := 0; i < 1000; i++ {
+		id, err := gen.Generate(context.Background(), "", 0)
+		require.NoError(t, err)
+		require.Len(t, id, 10)
+		require.True(t, isBase62(id), id)
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, 1000)
+}
+
+func TestCounterGenerator(t *testing.T) {
+	gen := NewCounterGenerator(NewAtomicCounter(60))
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := gen.Generate(context.Background(), "", 0)
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	assert.Equal(t, []string{"z", "10", "11"}, ids)
+}
+
+func TestHashGenerator(t *testing.T) {
+	gen := NewHashGenerator(8)
+	ctx := context.Background()
+
+	a, err := gen.Generate(ctx, "https://a.example", 0)
+	require.NoError(t, err)
+	again, err := gen.Generate(ctx, "https://a.example", 0)
+	require.NoError(t, err)
+	retry, err := gen.Generate(ctx, "https://a.example", 1)
+	require.NoError(t, err)
+
+	assert.Len(t, a, 8)
+	assert.True(t, isBase62(a), a)
+	assert.Equal(t, a, again)
+	assert.NotEqual(t, a, retry)
+}
+
+func TestSqidsGenerator(t *testing.T) {
+	ctx := context.Background()
+	gen := NewSqidsGenerator(NewAtomicCounter(0), "salt", 6)
+	seen := make(map[string]struct{})
+	var prev string
+	for i := 0; i < 1000; i++ {
+		id, err := gen.Generate(ctx, "", 0)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, len(id), 6)
+		require.True(t, isBase62(id), id)
+		require.NotEqual(t, prev, id)
+		seen[id] = struct{}{}
+		prev = id
+	}
+	assert.Len(t, seen, 1000)
+
+	// другая соль дает другую последовательность
+	other, err := NewSqidsGenerator(NewAtomicCounter(0), "pepper", 6).Generate(ctx, "", 0)
+	require.NoError(t, err)
+	first, err := NewSqidsGenerator(NewAtomicCounter(0), "salt", 6).Generate(ctx, "", 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+}
@@ -0,0 +1,14 @@
+package links
+
+import (
+	"net/url"
+)
+
+// IsValidURL - Проверка на корректный URL
+func IsValidURL(rawURL string) bool {
+	parsed, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return false
+	}
+	return parsed.Scheme == "http" || parsed.Scheme == "https"
+}
@@ -63,13 +63,22 @@ func (l *Writer) WriteToLog(timeStart time.Time, originalURL string, requestType
 	})
 }
 
+// StatusClientClosedRequest — нестандартный код 499 (как в nginx) для запросов,
+// которые клиент отменил до получения ответа.
+const StatusClientClosedRequest = 499
+
 // RequestLogger — middleware, логирующий каждый HTTP-запрос/ответ с помощью Logging.
-// Он перехватывает статус ответа и передает сводку в WriteToLog.
+// Он перехватывает статус ответа и передает сводку в WriteToLog. Если ответ
+// не был записан, а клиент отменил запрос, в лог попадает код 499.
 func RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		timeStart := time.Now()
 		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(lrw, r)
+		if !lrw.wrote && r.Context().Err() != nil {
+			Logging.WriteToLog(timeStart, r.RequestURI, r.Method, StatusClientClosedRequest, "Client Closed Request")
+			return
+		}
 		Logging.WriteToLog(timeStart, r.RequestURI, r.Method, lrw.statusCode, http.StatusText(lrw.statusCode))
 	})
 }
@@ -78,10 +87,23 @@ func RequestLogger(next http.Handler) http.Handler {
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	wrote      bool
 }
 
 // WriteHeader сохраняет HTTP-статус в обертке и проксирует вызов исходному ResponseWriter.
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.statusCode = code
+	lrw.wrote = true
 	lrw.ResponseWriter.WriteHeader(code)
 }
+
+// Write отмечает, что ответ начат, и проксирует запись.
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	lrw.wrote = true
+	return lrw.ResponseWriter.Write(b)
+}
+
+// Unwrap возвращает исходный http.ResponseWriter для http.ResponseController.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
@@ -0,0 +1,114 @@
+// Package metrics содержит метрики Prometheus сервиса и middleware для их сбора.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+// namespace — общий префикс имен метрик.
+const namespace = "shortener"
+
+// Registry — реестр, в котором зарегистрированы все метрики сервиса.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests — число HTTP-запросов по шаблону маршрута chi, методу и статусу.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by chi route pattern, method and status.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration — длительность обработки HTTP-запросов.
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by chi route pattern, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// StorageDuration — длительность операций хранилища по бэкенду и методу.
+	StorageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Storage operation latency by backend and method.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"backend", "method"})
+
+	// StorageErrors — число ошибок операций хранилища по бэкенду и методу.
+	// Ожидаемые ответы вроде «не найдено» ошибками не считаются.
+	StorageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_errors_total",
+		Help:      "Storage operation failures by backend and method.",
+	}, []string{"backend", "method"})
+
+	// LinksCreated — число созданных коротких ссылок.
+	LinksCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "links_created_total",
+		Help:      "Short links created.",
+	})
+
+	// LinksDeleted — число ссылок, запрошенных на удаление.
+	LinksDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "links_deleted_total",
+		Help:      "Short links requested for deletion.",
+	})
+
+	// Redirects — число разрешений коротких ссылок по результату:
+	// ok, not_found, deleted, expired, error.
+	Redirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Short link resolutions by result.",
+	}, []string{"result"})
+
+	// CacheLookups — число обращений к кэшу ссылок по результату: hit, miss.
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Short link cache lookups by result.",
+	}, []string{"result"})
+
+	// GzipRatio — отношение размера сжатого ответа к исходному.
+	GzipRatio = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gzip_compression_ratio",
+		Help:      "Compressed to uncompressed response size ratio.",
+		Buckets:   []float64{.05, .1, .2, .3, .4, .5, .6, .7, .8, .9, 1, 1.5},
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		StorageDuration,
+		StorageErrors,
+		LinksCreated,
+		LinksDeleted,
+		Redirects,
+		CacheLookups,
+		GzipRatio,
+	)
+}
+
+// Handler отдает метрики в текстовом формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveGzip учитывает степень сжатия ответа; пустые ответы пропускаются.
+func ObserveGzip(raw, compressed int64) {
+	if raw <= 0 {
+		return
+	}
+	GzipRatio.Observe(float64(compressed) / float64(raw))
+}
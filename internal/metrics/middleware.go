@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute — метка маршрута для запросов, не попавших ни в один шаблон,
+// чтобы произвольные URL не раздували число временных рядов.
+const unmatchedRoute = "unmatched"
+
+// statusWriter запоминает статус ответа и то, что ответ начат.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+// WriteHeader сохраняет статус и проксирует вызов.
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write отмечает, что ответ начат, и проксирует запись.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap возвращает исходный http.ResponseWriter для http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// HTTPMiddleware считает запросы и их длительность по шаблону маршрута chi
+// (например, "/{id}"), методу и статусу. Как и в logger.RequestLogger, запрос,
+// отмененный до начала ответа, учитывается с кодом 499.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeStart := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		// шаблон известен только после маршрутизации
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if p := rctx.RoutePattern(); p != "" {
+				route = p
+			}
+		}
+		code := sw.status
+		if !sw.wrote && r.Context().Err() != nil {
+			code = logger.StatusClientClosedRequest
+		}
+		status := strconv.Itoa(code)
+		HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+		HTTPDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(timeStart).Seconds())
+	})
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPMiddlewareUsesRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(HTTPMiddleware)
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Method(http.MethodGet, "/metrics", Handler())
+
+	before := testutil.ToFloat64(HTTPRequests.WithLabelValues("/{id}", http.MethodGet, "307"))
+	for _, id := range []string{"abc", "def"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+id, nil))
+	}
+	assert.Equal(t, before+2, testutil.ToFloat64(HTTPRequests.WithLabelValues("/{id}", http.MethodGet, "307")))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.True(t, strings.Contains(body, `shortener_http_requests_total{method="GET",route="/{id}",status="307"}`))
+	assert.True(t, strings.Contains(body, "shortener_http_request_duration_seconds_bucket"))
+	assert.False(t, strings.Contains(body, `route="/abc"`))
+}
+
+func TestHTTPMiddlewareCountsCancelledRequests(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(HTTPMiddleware)
+	r.Get("/slow", func(http.ResponseWriter, *http.Request) {})
+
+	before := testutil.ToFloat64(HTTPRequests.WithLabelValues("/slow", http.MethodGet, "499"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+	assert.Equal(t, before+1, testutil.ToFloat64(HTTPRequests.WithLabelValues("/slow", http.MethodGet, "499")))
+}
+
+func TestObserveGzip(t *testing.T) {
+	// пустой ответ не учитывается
+	ObserveGzip(0, 10)
+	ObserveGzip(1000, 250)
+	assert.Contains(t, gatherText(t), "shortener_gzip_compression_ratio_count 1")
+}
+
+// gatherText возвращает текущее состояние реестра в текстовом формате.
+func gatherText(t *testing.T) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return w.Body.String()
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/links"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"strings"
+)
+
+// Статусы элементов пакетного сокращения.
+const (
+	// BatchCreated — ссылка создана.
+	BatchCreated = "created"
+	// BatchExists — URL уже сокращен, в результате существующий id.
+	BatchExists = "exists"
+	// BatchInvalid — элемент отклонен из-за некорректных данных.
+	BatchInvalid = "invalid"
+	// BatchError — элемент не сохранен из-за ошибки сервиса.
+	BatchError = "error"
+)
+
+// BatchItem — элемент пакетного запроса на сокращение.
+type BatchItem struct {
+	CorrelationID string
+	OriginalURL   string
+	Alias         string
+}
+
+// BatchResult — итог обработки элемента пакета.
+type BatchResult struct {
+	CorrelationID string
+	ID            string
+	Status        string
+	Reason        string
+}
+
+// OK сообщает, получил ли элемент короткую ссылку.
+func (r BatchResult) OK() bool {
+	return r.Status == BatchCreated || r.Status == BatchExists
+}
+
+// ShortenBatch сокращает набор ссылок пользователя и возвращает итог по каждому
+// элементу в порядке запроса. Корректные элементы сохраняются одной операцией;
+// элементы со сгенерированным id, попавшим в коллизию, сохраняются повторно с новым id.
+// Ошибка возвращается, только если не удалось выполнить сохранение целиком.
+func ShortenBatch(ctx context.Context, svc URLService, gen links.IDGenerator, userID string, items []BatchItem) ([]BatchResult, error) {
+	results := make([]BatchResult, len(items))
+	var pending []int
+	urls := make(map[int]store.URL, len(items))
+	aliased := make([]bool, len(items))
+	seenAliases := make(map[string]string)
+
+	for i, item := range items {
+		results[i].CorrelationID = item.CorrelationID
+		originalURL := strings.TrimSpace(item.OriginalURL)
+		alias := strings.TrimSpace(item.Alias)
+
+		if !links.IsValidURL(originalURL) {
+			results[i].Status, results[i].Reason = BatchInvalid, "invalid URL format"
+			continue
+		}
+		if alias != "" {
+			if err := links.ValidateAlias(alias); err != nil {
+				results[i].Status, results[i].Reason = BatchInvalid, err.Error()
+				continue
+			}
+			if prev, ok := seenAliases[alias]; ok {
+				results[i].Status, results[i].Reason = BatchInvalid, fmt.Sprintf("alias %q is also used by correlation_id=%s", alias, prev)
+				continue
+			}
+			seenAliases[alias] = item.CorrelationID
+			aliased[i] = true
+		}
+
+		urls[i] = store.URL{ID: alias, OriginalURL: originalURL, UserID: userID}
+		pending = append(pending, i)
+	}
+
+	for attempt := 0; attempt < MaxIDAttempts && len(pending) > 0; attempt++ {
+		batch := make([]store.URL, 0, len(pending))
+		ready := pending[:0:0]
+		for _, i := range pending {
+			u := urls[i]
+			if !aliased[i] {
+				id, err := gen.Generate(ctx, u.OriginalURL, attempt)
+				if err != nil || id == "" {
+					results[i].Status, results[i].Reason = BatchError, ErrIDGeneration.Error()
+					continue
+				}
+				u.ID = id
+				urls[i] = u
+			}
+			batch = append(batch, u)
+			ready = append(ready, i)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		errs, err := svc.SaveURLs(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		pending = pending[:0]
+		for k, i := range ready {
+			err := errs[k]
+			switch {
+			case err == nil:
+				results[i].ID, results[i].Status = urls[i].ID, BatchCreated
+			case errors.Is(err, store.ErrDuplicateURL):
+				existingID, getErr := svc.GetShortIDByOriginalURL(ctx, userID, urls[i].OriginalURL)
+				if getErr != nil || existingID == "" {
+					results[i].Status, results[i].Reason = BatchError, "url already shortened but its id is unavailable"
+					continue
+				}
+				results[i].ID, results[i].Status = existingID, BatchExists
+			case errors.Is(err, store.ErrIDConflict) && aliased[i]:
+				results[i].Status, results[i].Reason = BatchInvalid, links.ErrAliasTaken.Error()
+			case errors.Is(err, store.ErrIDConflict):
+				pending = append(pending, i)
+			default:
+				results[i].Status, results[i].Reason = BatchError, "storage error"
+			}
+		}
+	}
+
+	for _, i := range pending {
+		results[i].Status, results[i].Reason = BatchError, ErrIDGeneration.Error()
+	}
+	return results, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/links"
+	"github.com/zauremazhikovayandex/url/internal/store"
+)
+
+func TestShortenBatchStatuses(t *testing.T) {
+	ctx := context.Background()
+	svc := NewURLService(store.NewMemoryStore())
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "1", OriginalURL: "https://old.example", UserID: "u1"}))
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "promo", OriginalURL: "https://promo.example", UserID: "u1"}))
+
+	// счетчик первым выдает занятый id 1 — элемент сохраняется повторно с новым id;
+	// id 2 уходит на уже сокращенный URL
+	gen := links.NewCounterGenerator(links.NewAtomicCounter(0))
+	results, err := ShortenBatch(ctx, svc, gen, "u1", []BatchItem{
+		{CorrelationID: "a", OriginalURL: "https://new.example"},
+		{CorrelationID: "b", OriginalURL: "https://old.example"},
+		{CorrelationID: "c", OriginalURL: "not a url"},
+		{CorrelationID: "d", OriginalURL: "https://other.example", Alias: "promo"},
+		{CorrelationID: "e", OriginalURL: "https://x.example", Alias: "mine"},
+		{CorrelationID: "f", OriginalURL: "https://y.example", Alias: "mine"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 6)
+
+	want := []struct{ status, id string }{
+		{BatchCreated, "3"},
+		{BatchExists, "1"},
+		{BatchInvalid, ""},
+		{BatchInvalid, ""},
+		{BatchCreated, "mine"},
+		{BatchInvalid, ""},
+	}
+	for i, w := range want {
+		assert.Equal(t, w.status, results[i].Status, "item %s", results[i].CorrelationID)
+		assert.Equal(t, w.id, results[i].ID, "item %s", results[i].CorrelationID)
+		assert.Equal(t, results[i].OK(), results[i].Reason == "", "item %s", results[i].CorrelationID)
+	}
+}
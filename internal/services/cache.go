@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/zauremazhikovayandex/url/internal/cache"
+	"github.com/zauremazhikovayandex/url/internal/metrics"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"hash/maphash"
+	"sync/atomic"
+	"time"
+)
+
+// cachedURL — запись кэша: исходный URL или надгробие с ошибкой хранилища
+// для удаленных, истекших и неизвестных ссылок.
+type cachedURL struct {
+	originalURL string
+	err         error
+}
+
+// generationStripes — число счетчиков поколений, между которыми делятся id.
+const generationStripes = 256
+
+// clickFlushInterval — как часто переходы из кэша сбрасываются в хранилище.
+const clickFlushInterval = time.Second
+
+// CachingURLService — декоратор URLService с read-through LRU-кэшем для GetOriginalURL.
+// Ссылки с лимитом переходов не кэшируются, чтобы лимит проверялся хранилищем;
+// переходы по остальным на кэшированном пути засчитываются пачками в фоне.
+type CachingURLService struct {
+	URLService
+	lru    *cache.LRU[string, cachedURL]
+	clicks *clickCounter
+	// generations увеличиваются при каждой инвалидации id; промах, во время
+	// которого поколение его id сменилось, не оставляет запись в кэше
+	generations [generationStripes]atomic.Uint64
+	seed        maphash.Seed
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+}
+
+// WithCache оборачивает svc кэшем на size записей со временем жизни ttl.
+// Накопленные переходы сбрасываются в svc при Close.
+func WithCache(svc URLService, size int, ttl time.Duration) *CachingURLService {
+	return &CachingURLService{
+		URLService: svc,
+		lru:        cache.NewLRU[string, cachedURL](size, ttl),
+		clicks:     newClickCounter(svc, clickFlushInterval),
+		seed:       maphash.MakeSeed(),
+	}
+}
+
+// Close сбрасывает в хранилище переходы, засчитанные на кэшированном пути.
+func (s *CachingURLService) Close() {
+	s.clicks.Close()
+}
+
+// Stats возвращает число попаданий и промахов кэша.
+func (s *CachingURLService) Stats() (hits, misses uint64) {
+	return s.hits.Load(), s.misses.Load()
+}
+
+// GetOriginalURL возвращает исходный URL из кэша, а при промахе — из хранилища.
+// Переход засчитывается в обоих случаях: при промахе хранилищем, при
+// попадании — фоновым счетчиком.
+func (s *CachingURLService) GetOriginalURL(ctx context.Context, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if c, ok := s.lru.Get(id); ok {
+		s.hits.Add(1)
+		metrics.CacheLookups.WithLabelValues("hit").Inc()
+		if c.err == nil {
+			s.clicks.Add(id)
+		}
+		return c.originalURL, c.err
+	}
+	s.misses.Add(1)
+	metrics.CacheLookups.WithLabelValues("miss").Inc()
+
+	gen := s.generation(id).Load()
+	originalURL, err := s.URLService.GetOriginalURL(ctx, id)
+	if err != nil {
+		if isTombstone(err) {
+			s.cache(id, gen, cachedURL{err: err}, time.Time{})
+		}
+		return originalURL, err
+	}
+
+	u, err := s.URLService.GetURL(ctx, id)
+	if err != nil || u.MaxClicks > 0 {
+		// переход уже засчитан; без метаданных ссылка просто не кэшируется
+		return originalURL, nil
+	}
+	var until time.Time
+	if u.ExpiresAt != nil {
+		until = *u.ExpiresAt
+	}
+	s.cache(id, gen, cachedURL{originalURL: originalURL}, until)
+	return originalURL, nil
+}
+
+// generation возвращает счетчик поколений для id.
+func (s *CachingURLService) generation(id string) *atomic.Uint64 {
+	return &s.generations[maphash.String(s.seed, id)%generationStripes]
+}
+
+// cache сохраняет результат промаха, прочитанный в поколении gen. Если id
+// инвалидировали после чтения, запись удаляется: либо здесь, либо Remove в
+// invalidate, выполненным после нее.
+func (s *CachingURLService) cache(id string, gen uint64, c cachedURL, until time.Time) {
+	s.lru.AddUntil(id, c, until)
+	if s.generation(id).Load() != gen {
+		s.lru.Remove(id)
+	}
+}
+
+// invalidate вытесняет id из кэша и не дает промахам, начатым раньше,
+// вернуть в кэш устаревшее значение.
+func (s *CachingURLService) invalidate(id string) {
+	s.generation(id).Add(1)
+	s.lru.Remove(id)
+}
+
+// isTombstone сообщает, можно ли закэшировать ошибку как надгробие.
+func isTombstone(err error) bool {
+	return errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrDeleted) || errors.Is(err, store.ErrExpired)
+}
+
+// SaveURL сохраняет ссылку и сбрасывает надгробие для ее id.
+func (s *CachingURLService) SaveURL(ctx context.Context, u store.URL) error {
+	err := s.URLService.SaveURL(ctx, u)
+	if err == nil {
+		s.invalidate(u.ID)
+	}
+	return err
+}
+
+// SaveURLs сохраняет пачку ссылок и сбрасывает надгробия сохраненных id.
+func (s *CachingURLService) SaveURLs(ctx context.Context, urls []store.URL) ([]error, error) {
+	errs, err := s.URLService.SaveURLs(ctx, urls)
+	if err == nil {
+		for i, e := range errs {
+			if e == nil {
+				s.invalidate(urls[i].ID)
+			}
+		}
+	}
+	return errs, err
+}
+
+// DeleteForUser удаляет ссылку и вытесняет ее из кэша.
+func (s *CachingURLService) DeleteForUser(ctx context.Context, id string, userID string) error {
+	err := s.URLService.DeleteForUser(ctx, id, userID)
+	s.invalidate(id)
+	return err
+}
+
+// BatchDelete удаляет набор ссылок и вытесняет их из кэша.
+func (s *CachingURLService) BatchDelete(ctx context.Context, ids []string, userID string) error {
+	err := s.URLService.BatchDelete(ctx, ids, userID)
+	for _, id := range ids {
+		s.invalidate(id)
+	}
+	return err
+}
+
+// DeleteMany удаляет ссылки нескольких пользователей и вытесняет их из кэша.
+func (s *CachingURLService) DeleteMany(ctx context.Context, items []store.Deletion) error {
+	err := s.URLService.DeleteMany(ctx, items)
+	for _, it := range items {
+		s.invalidate(it.ID)
+	}
+	return err
+}
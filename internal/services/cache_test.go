@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/store"
+)
+
+func TestCachingURLService(t *testing.T) {
+	ctx := context.Background()
+	svc := WithCache(NewURLService(store.NewMemoryStore()), 100, time.Minute)
+	defer svc.Close()
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "a1", OriginalURL: "https://a.example", UserID: "u1"}))
+
+	for i := 0; i < 3; i++ {
+		u, err := svc.GetOriginalURL(ctx, "a1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://a.example", u)
+	}
+	hits, misses := svc.Stats()
+	assert.EqualValues(t, 2, hits)
+	assert.EqualValues(t, 1, misses)
+
+	// удаление вытесняет запись, а следующий промах кэширует надгробие
+	require.NoError(t, svc.BatchDelete(ctx, []string{"a1"}, "u1"))
+	_, err := svc.GetOriginalURL(ctx, "a1")
+	assert.ErrorIs(t, err, store.ErrDeleted)
+	_, err = svc.GetOriginalURL(ctx, "a1")
+	assert.ErrorIs(t, err, store.ErrDeleted)
+	hits, misses = svc.Stats()
+	assert.EqualValues(t, 3, hits)
+	assert.EqualValues(t, 2, misses)
+
+	// надгробие неизвестного id сбрасывается при сохранении ссылки с этим id
+	_, err = svc.GetOriginalURL(ctx, "b1")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "b1", OriginalURL: "https://b.example", UserID: "u1"}))
+	u, err := svc.GetOriginalURL(ctx, "b1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://b.example", u)
+}
+
+func TestCachingURLServiceSkipsClickLimitedLinks(t *testing.T) {
+	ctx := context.Background()
+	svc := WithCache(NewURLService(store.NewMemoryStore()), 100, time.Minute)
+	defer svc.Close()
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "once", OriginalURL: "https://once.example", MaxClicks: 1}))
+
+	_, err := svc.GetOriginalURL(ctx, "once")
+	require.NoError(t, err)
+	_, err = svc.GetOriginalURL(ctx, "once")
+	assert.ErrorIs(t, err, store.ErrExpired)
+}
+
+func TestCachingURLServiceCountsClicks(t *testing.T) {
+	ctx := context.Background()
+	base := NewURLService(store.NewMemoryStore())
+	svc := WithCache(base, 100, time.Minute)
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "a1", OriginalURL: "https://a.example", UserID: "u1"}))
+
+	for i := 0; i < 5; i++ {
+		_, err := svc.GetOriginalURL(ctx, "a1")
+		require.NoError(t, err)
+	}
+	hits, _ := svc.Stats()
+	require.EqualValues(t, 4, hits)
+
+	// переходы из кэша досчитываются при сбросе счетчика
+	svc.Close()
+	u, err := base.GetURL(ctx, "a1")
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, u.Clicks)
+}
+
+// racingService удаляет ссылку между чтением ее записи и возвратом результата,
+// как если бы удаление пришло во время промаха кэша.
+type racingService struct {
+	URLService
+	onGetURL func()
+}
+
+func (s *racingService) GetURL(ctx context.Context, id string) (store.URL, error) {
+	u, err := s.URLService.GetURL(ctx, id)
+	if s.onGetURL != nil {
+		s.onGetURL()
+		s.onGetURL = nil
+	}
+	return u, err
+}
+
+func TestCachingURLServiceStaleMissAfterDelete(t *testing.T) {
+	ctx := context.Background()
+	inner := &racingService{URLService: NewURLService(store.NewMemoryStore())}
+	svc := WithCache(inner, 100, time.Minute)
+	defer svc.Close()
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "a1", OriginalURL: "https://a.example", UserID: "u1"}))
+
+	inner.onGetURL = func() {
+		require.NoError(t, svc.BatchDelete(ctx, []string{"a1"}, "u1"))
+	}
+	_, err := svc.GetOriginalURL(ctx, "a1")
+	require.NoError(t, err)
+
+	// устаревший результат промаха не попал в кэш
+	_, err = svc.GetOriginalURL(ctx, "a1")
+	assert.ErrorIs(t, err, store.ErrDeleted)
+}
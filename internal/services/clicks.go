@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"sync"
+	"time"
+)
+
+// clickCounter накапливает переходы, отданные из кэша, и раз в interval
+// сбрасывает их в сервис одной пачкой. Add не блокируется на хранилище.
+type clickCounter struct {
+	sink     URLService
+	interval time.Duration
+	mu       sync.Mutex
+	pending  map[string]int64
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newClickCounter создает и запускает счетчик.
+func newClickCounter(sink URLService, interval time.Duration) *clickCounter {
+	c := &clickCounter{
+		sink:     sink,
+		interval: interval,
+		pending:  make(map[string]int64),
+		stop:     make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.run()
+	return c
+}
+
+// Add засчитывает один переход по id.
+func (c *clickCounter) Add(id string) {
+	c.mu.Lock()
+	c.pending[id]++
+	c.mu.Unlock()
+}
+
+// Close останавливает счетчик и сбрасывает накопленные переходы.
+func (c *clickCounter) Close() {
+	close(c.stop)
+	c.wg.Wait()
+}
+
+// run периодически сбрасывает накопленные переходы.
+func (c *clickCounter) run() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case <-c.stop:
+			c.flush()
+			return
+		}
+	}
+}
+
+// flush передает накопленные переходы в sink; при ошибке они теряются,
+// как и записи аналитики.
+func (c *clickCounter) flush() {
+	c.mu.Lock()
+	batch := c.pending
+	if len(batch) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	c.pending = make(map[string]int64, len(batch))
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.sink.AddClicks(ctx, batch); err != nil {
+		logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Click counters flush ERROR: %s", err)})
+	}
+}
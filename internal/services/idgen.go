@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/links"
+	"github.com/zauremazhikovayandex/url/internal/store"
+)
+
+// MaxIDAttempts — сколько раз генерируется новый id при коллизии.
+const MaxIDAttempts = 5
+
+// ErrIDGeneration сигнализирует, что свободный id получить не удалось.
+var ErrIDGeneration = errors.New("failed to generate short ID")
+
+// SaveWithGeneratedID сохраняет ссылку под id от gen, повторяя генерацию при
+// коллизии id. Возвращает сохраненный id.
+func SaveWithGeneratedID(ctx context.Context, svc URLService, gen links.IDGenerator, u store.URL) (string, error) {
+	for attempt := 0; attempt < MaxIDAttempts; attempt++ {
+		id, err := gen.Generate(ctx, u.OriginalURL, attempt)
+		if err != nil || id == "" {
+			return "", fmt.Errorf("%w: %v", ErrIDGeneration, err)
+		}
+		u.ID = id
+		err = svc.SaveURL(ctx, u)
+		if !errors.Is(err, store.ErrIDConflict) {
+			return id, err
+		}
+	}
+	return "", fmt.Errorf("%w: %d collisions in a row", ErrIDGeneration, MaxIDAttempts)
+}
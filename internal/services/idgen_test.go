@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/links"
+	"github.com/zauremazhikovayandex/url/internal/store"
+)
+
+func TestSaveWithGeneratedIDRetriesCollisions(t *testing.T) {
+	ctx := context.Background()
+	svc := NewURLService(store.NewMemoryStore())
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "1", OriginalURL: "https://taken.example"}))
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "2", OriginalURL: "https://taken2.example"}))
+
+	// счетчик выдает 1 и 2, занятые выше, и только затем свободный 3
+	gen := links.NewCounterGenerator(links.NewAtomicCounter(0))
+	id, err := SaveWithGeneratedID(ctx, svc, gen, store.URL{OriginalURL: "https://new.example"})
+	require.NoError(t, err)
+	assert.Equal(t, "3", id)
+
+	u, err := svc.GetURL(ctx, "3")
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example", u.OriginalURL)
+}
+
+func TestSaveWithGeneratedIDGivesUp(t *testing.T) {
+	ctx := context.Background()
+	svc := NewURLService(store.NewMemoryStore())
+	require.NoError(t, svc.SaveURL(ctx, store.URL{ID: "same", OriginalURL: "https://taken.example"}))
+
+	_, err := SaveWithGeneratedID(ctx, svc, constGenerator("same"), store.URL{OriginalURL: "https://new.example"})
+	assert.ErrorIs(t, err, ErrIDGeneration)
+}
+
+// constGenerator всегда выдает один и тот же id.
+type constGenerator string
+
+func (g constGenerator) Generate(context.Context, string, int) (string, error) {
+	return string(g), nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/zauremazhikovayandex/url/internal/metrics"
+	"github.com/zauremazhikovayandex/url/internal/store"
+)
+
+// MetricsURLService — декоратор URLService, считающий бизнес-события:
+// созданные и удаленные ссылки и переходы по ним.
+type MetricsURLService struct {
+	URLService
+}
+
+// WithMetrics оборачивает svc сбором бизнес-метрик.
+func WithMetrics(svc URLService) *MetricsURLService {
+	return &MetricsURLService{URLService: svc}
+}
+
+// GetOriginalURL разрешает ссылку и учитывает результат перехода.
+func (s *MetricsURLService) GetOriginalURL(ctx context.Context, id string) (string, error) {
+	originalURL, err := s.URLService.GetOriginalURL(ctx, id)
+	metrics.Redirects.WithLabelValues(redirectResult(err)).Inc()
+	return originalURL, err
+}
+
+// redirectResult возвращает метку результата перехода.
+func redirectResult(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, store.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, store.ErrDeleted):
+		return "deleted"
+	case errors.Is(err, store.ErrExpired):
+		return "expired"
+	}
+	return "error"
+}
+
+// SaveURL сохраняет ссылку и учитывает успешное создание.
+func (s *MetricsURLService) SaveURL(ctx context.Context, u store.URL) error {
+	err := s.URLService.SaveURL(ctx, u)
+	if err == nil {
+		metrics.LinksCreated.Inc()
+	}
+	return err
+}
+
+// SaveURLs сохраняет пачку ссылок и учитывает созданные.
+func (s *MetricsURLService) SaveURLs(ctx context.Context, urls []store.URL) ([]error, error) {
+	errs, err := s.URLService.SaveURLs(ctx, urls)
+	if err == nil {
+		for _, e := range errs {
+			if e == nil {
+				metrics.LinksCreated.Inc()
+			}
+		}
+	}
+	return errs, err
+}
+
+// DeleteForUser удаляет ссылку и учитывает запрос на удаление.
+func (s *MetricsURLService) DeleteForUser(ctx context.Context, id string, userID string) error {
+	err := s.URLService.DeleteForUser(ctx, id, userID)
+	if err == nil {
+		metrics.LinksDeleted.Inc()
+	}
+	return err
+}
+
+// BatchDelete удаляет набор ссылок и учитывает их количество.
+func (s *MetricsURLService) BatchDelete(ctx context.Context, ids []string, userID string) error {
+	err := s.URLService.BatchDelete(ctx, ids, userID)
+	if err == nil {
+		metrics.LinksDeleted.Add(float64(len(ids)))
+	}
+	return err
+}
+
+// DeleteMany удаляет ссылки нескольких пользователей и учитывает их количество.
+func (s *MetricsURLService) DeleteMany(ctx context.Context, items []store.Deletion) error {
+	err := s.URLService.DeleteMany(ctx, items)
+	if err == nil {
+		metrics.LinksDeleted.Add(float64(len(items)))
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/logger"
+	"github.com/zauremazhikovayandex/url/internal/logger/message"
+	"time"
+)
+
+// RunReaper раз в interval удаляет истекшие ссылки, пока ctx не отменен.
+// onPurge, если задан, получает id удаленных ссылок, чтобы убрать связанные
+// с ними данные (например, статистику переходов).
+func RunReaper(ctx context.Context, svc URLService, interval time.Duration, onPurge func(ctx context.Context, ids []string) error) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ids, err := svc.PurgeExpired(ctx)
+			if err != nil {
+				logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Reaper ERROR: %s", err)})
+				continue
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			logger.Log.Info(&message.LogMessage{Message: fmt.Sprintf("Reaper purged %d expired URLs", len(ids))})
+			if onPurge != nil {
+				if err := onPurge(ctx, ids); err != nil {
+					logger.Log.Error(&message.LogMessage{Message: fmt.Sprintf("Reaper ERROR: cleanup of purged URLs: %s", err)})
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"github.com/zauremazhikovayandex/url/internal/store"
+	"github.com/zauremazhikovayandex/url/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingURLService — декоратор URLService, открывающий спан на каждый метод.
+type TracingURLService struct {
+	next URLService
+}
+
+// WithTracing оборачивает svc спанами OpenTelemetry.
+func WithTracing(svc URLService) *TracingURLService {
+	return &TracingURLService{next: svc}
+}
+
+// start открывает спан метода сервиса.
+func (s *TracingURLService) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "URLService."+method, trace.WithAttributes(attrs...))
+}
+
+// end закрывает спан; штатные ответы хранилища ошибкой не считаются.
+func end(span trace.Span, err error) {
+	if err != nil && !store.IsExpectedErr(err) {
+		tracing.RecordError(span, err)
+	}
+	span.End()
+}
+
+// GetOriginalURL возвращает исходный URL и засчитывает переход.
+func (s *TracingURLService) GetOriginalURL(ctx context.Context, id string) (string, error) {
+	ctx, span := s.start(ctx, "GetOriginalURL", attribute.String("link.id", id))
+	originalURL, err := s.next.GetOriginalURL(ctx, id)
+	end(span, err)
+	return originalURL, err
+}
+
+// GetURL возвращает запись о ссылке.
+func (s *TracingURLService) GetURL(ctx context.Context, id string) (store.URL, error) {
+	ctx, span := s.start(ctx, "GetURL", attribute.String("link.id", id))
+	u, err := s.next.GetURL(ctx, id)
+	end(span, err)
+	return u, err
+}
+
+// GetURLsByUserID возвращает страницу ссылок пользователя.
+func (s *TracingURLService) GetURLsByUserID(ctx context.Context, userID string, opts store.ListOptions) (store.Page, error) {
+	ctx, span := s.start(ctx, "GetURLsByUserID", attribute.Int("page.limit", opts.Limit))
+	page, err := s.next.GetURLsByUserID(ctx, userID, opts)
+	span.SetAttributes(attribute.Int("links.count", len(page.URLs)))
+	end(span, err)
+	return page, err
+}
+
+// ExportUserURLs выгружает ссылки пользователя.
+func (s *TracingURLService) ExportUserURLs(ctx context.Context, userID string, fn func(store.URL) error) error {
+	ctx, span := s.start(ctx, "ExportUserURLs")
+	var n int
+	err := s.next.ExportUserURLs(ctx, userID, func(u store.URL) error {
+		n++
+		return fn(u)
+	})
+	span.SetAttributes(attribute.Int("links.count", n))
+	end(span, err)
+	return err
+}
+
+// GetShortIDByOriginalURL возвращает id по исходному URL.
+func (s *TracingURLService) GetShortIDByOriginalURL(ctx context.Context, userID, originalURL string) (string, error) {
+	ctx, span := s.start(ctx, "GetShortIDByOriginalURL")
+	id, err := s.next.GetShortIDByOriginalURL(ctx, userID, originalURL)
+	end(span, err)
+	return id, err
+}
+
+// AddClicks засчитывает накопленные переходы.
+func (s *TracingURLService) AddClicks(ctx context.Context, counts map[string]int64) error {
+	ctx, span := s.start(ctx, "AddClicks", attribute.Int("links.count", len(counts)))
+	err := s.next.AddClicks(ctx, counts)
+	end(span, err)
+	return err
+}
+
+// MergeUserURLs передает ссылки пользователя другому пользователю.
+func (s *TracingURLService) MergeUserURLs(ctx context.Context, from, to string) (int, error) {
+	ctx, span := s.start(ctx, "MergeUserURLs")
+	n, err := s.next.MergeUserURLs(ctx, from, to)
+	span.SetAttributes(attribute.Int("links.count", n))
+	end(span, err)
+	return n, err
+}
+
+// SaveURL сохраняет ссылку.
+func (s *TracingURLService) SaveURL(ctx context.Context, u store.URL) error {
+	ctx, span := s.start(ctx, "SaveURL", attribute.String("link.id", u.ID))
+	err := s.next.SaveURL(ctx, u)
+	end(span, err)
+	return err
+}
+
+// SaveURLs сохраняет пачку ссылок.
+func (s *TracingURLService) SaveURLs(ctx context.Context, urls []store.URL) ([]error, error) {
+	ctx, span := s.start(ctx, "SaveURLs", attribute.Int("links.count", len(urls)))
+	errs, err := s.next.SaveURLs(ctx, urls)
+	end(span, err)
+	return errs, err
+}
+
+// DeleteForUser удаляет ссылку пользователя.
+func (s *TracingURLService) DeleteForUser(ctx context.Context, id string, userID string) error {
+	ctx, span := s.start(ctx, "DeleteForUser", attribute.String("link.id", id))
+	err := s.next.DeleteForUser(ctx, id, userID)
+	end(span, err)
+	return err
+}
+
+// BatchDelete удаляет набор ссылок пользователя.
+func (s *TracingURLService) BatchDelete(ctx context.Context, ids []string, userID string) error {
+	ctx, span := s.start(ctx, "BatchDelete", attribute.Int("links.count", len(ids)))
+	err := s.next.BatchDelete(ctx, ids, userID)
+	end(span, err)
+	return err
+}
+
+// DeleteMany удаляет ссылки нескольких пользователей.
+func (s *TracingURLService) DeleteMany(ctx context.Context, items []store.Deletion) error {
+	ctx, span := s.start(ctx, "DeleteMany", attribute.Int("links.count", len(items)))
+	err := s.next.DeleteMany(ctx, items)
+	end(span, err)
+	return err
+}
+
+// PurgeExpired удаляет истекшие ссылки.
+func (s *TracingURLService) PurgeExpired(ctx context.Context) ([]string, error) {
+	ctx, span := s.start(ctx, "PurgeExpired")
+	ids, err := s.next.PurgeExpired(ctx)
+	span.SetAttributes(attribute.Int("links.purged", len(ids)))
+	end(span, err)
+	return ids, err
+}
+
+// CountURLs возвращает число ссылок.
+func (s *TracingURLService) CountURLs(ctx context.Context) (int, error) {
+	ctx, span := s.start(ctx, "CountURLs")
+	n, err := s.next.CountURLs(ctx)
+	end(span, err)
+	return n, err
+}
+
+// CountUsers возвращает число пользователей.
+func (s *TracingURLService) CountUsers(ctx context.Context) (int, error) {
+	ctx, span := s.start(ctx, "CountUsers")
+	n, err := s.next.CountUsers(ctx)
+	end(span, err)
+	return n, err
+}
@@ -3,22 +3,45 @@ package services
 
 import (
 	"context"
-	"github.com/zauremazhikovayandex/url/internal/db/postgres"
+	"github.com/zauremazhikovayandex/url/internal/store"
 )
 
 // URLService описывает набор операций над короткими ссылками и пользовательскими данными.
-// Конкретная реализация (например, работа через PostgreSQL) должна обеспечивать эти методы.
+// Реализация не зависит от конкретного бэкенда хранения (память, файл, PostgreSQL).
 type URLService interface {
-	// GetOriginalURL возвращает исходный URL по короткому идентификатору.
+	// GetOriginalURL возвращает исходный URL по короткому идентификатору
+	// и засчитывает переход по ссылке.
 	GetOriginalURL(ctx context.Context, id string) (string, error)
-	// GetURLsByUserID возвращает список активных ссылок пользователя.
-	GetURLsByUserID(ctx context.Context, userID string) ([]postgres.URL, error)
-	// GetShortIDByOriginalURL возвращает короткий идентификатор по исходному URL.
-	GetShortIDByOriginalURL(ctx context.Context, originalURL string) (string, error)
-	// SaveURL сохраняет новую короткую ссылку для пользователя.
-	SaveURL(ctx context.Context, id string, originalURL string, userID string) error
+	// AddClicks засчитывает переходы, отданные в обход хранилища; см. store.Store.AddClicks.
+	AddClicks(ctx context.Context, counts map[string]int64) error
+	// GetURL возвращает запись о ссылке без учета перехода.
+	GetURL(ctx context.Context, id string) (store.URL, error)
+	// GetURLsByUserID возвращает страницу активных ссылок пользователя.
+	GetURLsByUserID(ctx context.Context, userID string, opts store.ListOptions) (store.Page, error)
+	// ExportUserURLs передает в fn все ссылки пользователя, включая удаленные,
+	// в порядке создания, не загружая их в память целиком.
+	ExportUserURLs(ctx context.Context, userID string, fn func(store.URL) error) error
+	// GetShortIDByOriginalURL возвращает короткий идентификатор уже сокращенного URL,
+	// видимый пользователю userID в настроенной области уникальности.
+	GetShortIDByOriginalURL(ctx context.Context, userID, originalURL string) (string, error)
+	// SaveURL сохраняет новую короткую ссылку (id, исходный URL, владелец, срок жизни).
+	SaveURL(ctx context.Context, u store.URL) error
+	// SaveURLs сохраняет пачку ссылок за одну операцию; ошибки отдельных ссылок
+	// возвращаются по их позициям.
+	SaveURLs(ctx context.Context, urls []store.URL) ([]error, error)
+	// MergeUserURLs передает ссылки пользователя from пользователю to и
+	// возвращает их число; см. store.Store.Reassign.
+	MergeUserURLs(ctx context.Context, from, to string) (int, error)
 	// DeleteForUser помечает ссылку как удаленную для указанного пользователя.
 	DeleteForUser(ctx context.Context, id string, userID string) error
 	// BatchDelete помечает на удаление набор ссылок пользователя.
 	BatchDelete(ctx context.Context, ids []string, userID string) error
+	// DeleteMany помечает на удаление ссылки нескольких пользователей за одну операцию.
+	DeleteMany(ctx context.Context, items []store.Deletion) error
+	// PurgeExpired удаляет истекшие ссылки и возвращает их id.
+	PurgeExpired(ctx context.Context) ([]string, error)
+	// CountURLs возвращает число сокращенных (неудаленных) ссылок.
+	CountURLs(ctx context.Context) (int, error)
+	// CountUsers возвращает число пользователей, у которых есть ссылки.
+	CountUsers(ctx context.Context) (int, error)
 }
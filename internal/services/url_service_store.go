@@ -0,0 +1,139 @@
+// Package services содержит бизнес-логику поверх слоев хранилища.
+package services
+
+import (
+	"context"
+	"github.com/zauremazhikovayandex/url/internal/store"
+)
+
+// StoreURLService реализует операции с URL поверх любого store.Store.
+// Отмененный контекст запроса прерывает операцию до обращения к хранилищу,
+// в том числе для бэкендов, которые сами контекст не учитывают.
+type StoreURLService struct {
+	store store.Store
+}
+
+// NewURLService создает сервис поверх указанного хранилища.
+func NewURLService(st store.Store) *StoreURLService {
+	return &StoreURLService{store: st}
+}
+
+// GetOriginalURL возвращает оригинальный URL по id и засчитывает переход.
+func (s *StoreURLService) GetOriginalURL(ctx context.Context, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.store.Resolve(ctx, id)
+}
+
+// AddClicks засчитывает накопленные переходы.
+func (s *StoreURLService) AddClicks(ctx context.Context, counts map[string]int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.AddClicks(ctx, counts)
+}
+
+// GetURL возвращает запись о ссылке по id.
+func (s *StoreURLService) GetURL(ctx context.Context, id string) (store.URL, error) {
+	if err := ctx.Err(); err != nil {
+		return store.URL{}, err
+	}
+	return s.store.Get(ctx, id)
+}
+
+// GetURLsByUserID возвращает страницу ссылок пользователя.
+func (s *StoreURLService) GetURLsByUserID(ctx context.Context, userID string, opts store.ListOptions) (store.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return store.Page{}, err
+	}
+	return s.store.ListByUser(ctx, userID, opts)
+}
+
+// ExportUserURLs обходит все ссылки пользователя.
+func (s *StoreURLService) ExportUserURLs(ctx context.Context, userID string, fn func(store.URL) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.EachByUser(ctx, userID, fn)
+}
+
+// GetShortIDByOriginalURL возвращает id по оригинальному URL.
+func (s *StoreURLService) GetShortIDByOriginalURL(ctx context.Context, userID, originalURL string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.store.GetIDByOriginalURL(ctx, userID, originalURL)
+}
+
+// SaveURL сохраняет новую короткую ссылку.
+func (s *StoreURLService) SaveURL(ctx context.Context, u store.URL) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Save(ctx, u)
+}
+
+// SaveURLs сохраняет пачку ссылок.
+func (s *StoreURLService) SaveURLs(ctx context.Context, urls []store.URL) ([]error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.store.SaveBatch(ctx, urls)
+}
+
+// MergeUserURLs передает ссылки пользователя другому пользователю.
+func (s *StoreURLService) MergeUserURLs(ctx context.Context, from, to string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.Reassign(ctx, from, to)
+}
+
+// DeleteForUser помечает ссылку как удаленную для пользователя.
+func (s *StoreURLService) DeleteForUser(ctx context.Context, id string, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.DeleteForUser(ctx, id, userID)
+}
+
+// BatchDelete помечает на удаление набор ссылок пользователя.
+func (s *StoreURLService) BatchDelete(ctx context.Context, ids []string, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.BatchDelete(ctx, ids, userID)
+}
+
+// DeleteMany помечает на удаление ссылки нескольких пользователей.
+func (s *StoreURLService) DeleteMany(ctx context.Context, items []store.Deletion) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.DeleteMany(ctx, items)
+}
+
+// PurgeExpired удаляет истекшие ссылки.
+func (s *StoreURLService) PurgeExpired(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.store.PurgeExpired(ctx)
+}
+
+// CountURLs возвращает число неудаленных ссылок.
+func (s *StoreURLService) CountURLs(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.CountURLs(ctx)
+}
+
+// CountUsers возвращает число пользователей, у которых есть ссылки.
+func (s *StoreURLService) CountUsers(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.CountUsers(ctx)
+}
@@ -1 +1,18 @@
 package store
+
+import "errors"
+
+// ErrNotFound сигнализирует, что ссылка с указанным id не найдена.
+var ErrNotFound = errors.New("url_not_found")
+
+// ErrDeleted сигнализирует, что ссылка помечена как удаленная.
+var ErrDeleted = errors.New("url_deleted")
+
+// ErrExpired сигнализирует, что срок жизни ссылки истек или исчерпан лимит переходов.
+var ErrExpired = errors.New("url_expired")
+
+// ErrDuplicateURL сигнализирует, что оригинальный URL уже сохранен.
+var ErrDuplicateURL = errors.New("duplicate_original_url")
+
+// ErrIDConflict сигнализирует, что короткий идентификатор (или алиас) уже занят.
+var ErrIDConflict = errors.New("id_conflict")
@@ -1 +1,91 @@
 package store
+
+import (
+	"github.com/zauremazhikovayandex/url/internal/db/storage"
+	"log"
+	"sync"
+	"time"
+)
+
+// FileOptions задает параметры журнала файлового хранилища.
+type FileOptions struct {
+	// SyncPolicy — политика fsync журнала.
+	SyncPolicy storage.SyncPolicy
+	// SyncInterval — период фонового fsync для storage.SyncInterval.
+	SyncInterval time.Duration
+	// CompactInterval — период компакции журнала в снимок; 0 отключает компакцию.
+	CompactInterval time.Duration
+	// DedupScope — область уникальности исходного URL; пусто — глобальная.
+	DedupScope storage.DedupScope
+}
+
+// FileStore — хранилище в памяти, каждое изменение которого дописывается
+// в журнал рядом с файлом-снимком. При старте снимок загружается и поверх
+// него проигрывается журнал; периодически журнал сворачивается в новый снимок.
+type FileStore struct {
+	*MemoryStore
+	path    string
+	journal *storage.Journal
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewFileStore создает файловое хранилище и загружает данные из path.
+// Если снимок или журнал не удалось прочитать целиком либо журнал не
+// открывается, возвращается ошибка: частично загруженное хранилище при
+// компакции затерло бы непроигранную часть журнала, а без журнала изменения
+// не переживали бы перезапуск. Файлы при этом не изменяются.
+func NewFileStore(path string, opts FileOptions) (*FileStore, error) {
+	data := storage.New()
+	if opts.DedupScope != "" {
+		data.SetDedupScope(opts.DedupScope)
+	}
+	if err := data.LoadFromFile(path); err != nil {
+		return nil, err
+	}
+
+	fs := &FileStore{MemoryStore: &MemoryStore{data: data}, path: path, stop: make(chan struct{})}
+
+	j, err := storage.OpenJournal(storage.JournalPath(path), opts.SyncPolicy, opts.SyncInterval)
+	if err != nil {
+		return nil, err
+	}
+	fs.journal = j
+	data.AttachJournal(j)
+
+	if opts.CompactInterval > 0 {
+		fs.wg.Add(1)
+		go fs.compactLoop(opts.CompactInterval)
+	}
+	return fs, nil
+}
+
+// compactLoop периодически сворачивает журнал в снимок.
+func (f *FileStore) compactLoop(interval time.Duration) {
+	defer f.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			if err := f.data.Compact(f.path); err != nil {
+				log.Printf("Failed to compact store: %v", err)
+			}
+		}
+	}
+}
+
+// Close останавливает компакцию, сохраняет снимок и закрывает журнал.
+func (f *FileStore) Close() error {
+	close(f.stop)
+	f.wg.Wait()
+	if err := f.data.ShutdownSaveToFile(f.path); err != nil {
+		return err
+	}
+	if f.journal != nil {
+		return f.journal.Close()
+	}
+	return nil
+}
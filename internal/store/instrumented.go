@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/zauremazhikovayandex/url/internal/metrics"
+	"time"
+)
+
+// InstrumentedStore — декоратор Store, собирающий метрики длительности
+// и ошибок каждой операции.
+type InstrumentedStore struct {
+	next    Store
+	backend string
+}
+
+// Instrumented оборачивает st сбором метрик с меткой backend.
+func Instrumented(st Store, backend string) *InstrumentedStore {
+	return &InstrumentedStore{next: st, backend: backend}
+}
+
+// observe учитывает длительность операции method и неожиданную ошибку.
+func (s *InstrumentedStore) observe(method string, timeStart time.Time, err error) {
+	metrics.StorageDuration.WithLabelValues(s.backend, method).Observe(time.Since(timeStart).Seconds())
+	if err != nil && !IsExpectedErr(err) {
+		metrics.StorageErrors.WithLabelValues(s.backend, method).Inc()
+	}
+}
+
+// IsExpectedErr сообщает, является ли ошибка штатным ответом хранилища, а не сбоем.
+func IsExpectedErr(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrDeleted) || errors.Is(err, ErrExpired) ||
+		errors.Is(err, ErrDuplicateURL) || errors.Is(err, ErrIDConflict)
+}
+
+// Get возвращает запись по id.
+func (s *InstrumentedStore) Get(ctx context.Context, id string) (URL, error) {
+	timeStart := time.Now()
+	u, err := s.next.Get(ctx, id)
+	s.observe("Get", timeStart, err)
+	return u, err
+}
+
+// Resolve засчитывает переход и возвращает исходный URL.
+func (s *InstrumentedStore) Resolve(ctx context.Context, id string) (string, error) {
+	timeStart := time.Now()
+	originalURL, err := s.next.Resolve(ctx, id)
+	s.observe("Resolve", timeStart, err)
+	return originalURL, err
+}
+
+// GetIDByOriginalURL возвращает id по исходному URL.
+func (s *InstrumentedStore) GetIDByOriginalURL(ctx context.Context, userID, originalURL string) (string, error) {
+	timeStart := time.Now()
+	id, err := s.next.GetIDByOriginalURL(ctx, userID, originalURL)
+	s.observe("GetIDByOriginalURL", timeStart, err)
+	return id, err
+}
+
+// ListByUser возвращает страницу активных ссылок пользователя.
+func (s *InstrumentedStore) ListByUser(ctx context.Context, userID string, opts ListOptions) (Page, error) {
+	timeStart := time.Now()
+	page, err := s.next.ListByUser(ctx, userID, opts)
+	s.observe("ListByUser", timeStart, err)
+	return page, err
+}
+
+// EachByUser обходит ссылки пользователя; длительность включает обработку строк в fn.
+func (s *InstrumentedStore) EachByUser(ctx context.Context, userID string, fn func(URL) error) error {
+	timeStart := time.Now()
+	err := s.next.EachByUser(ctx, userID, fn)
+	s.observe("EachByUser", timeStart, err)
+	return err
+}
+
+// AddClicks засчитывает накопленные переходы.
+func (s *InstrumentedStore) AddClicks(ctx context.Context, counts map[string]int64) error {
+	timeStart := time.Now()
+	err := s.next.AddClicks(ctx, counts)
+	s.observe("AddClicks", timeStart, err)
+	return err
+}
+
+// Reassign передает ссылки другому пользователю.
+func (s *InstrumentedStore) Reassign(ctx context.Context, from, to string) (int, error) {
+	timeStart := time.Now()
+	n, err := s.next.Reassign(ctx, from, to)
+	s.observe("Reassign", timeStart, err)
+	return n, err
+}
+
+// Save сохраняет новую ссылку.
+func (s *InstrumentedStore) Save(ctx context.Context, u URL) error {
+	timeStart := time.Now()
+	err := s.next.Save(ctx, u)
+	s.observe("Save", timeStart, err)
+	return err
+}
+
+// SaveBatch сохраняет пачку ссылок.
+func (s *InstrumentedStore) SaveBatch(ctx context.Context, urls []URL) ([]error, error) {
+	timeStart := time.Now()
+	errs, err := s.next.SaveBatch(ctx, urls)
+	s.observe("SaveBatch", timeStart, err)
+	return errs, err
+}
+
+// DeleteForUser удаляет ссылку пользователя.
+func (s *InstrumentedStore) DeleteForUser(ctx context.Context, id string, userID string) error {
+	timeStart := time.Now()
+	err := s.next.DeleteForUser(ctx, id, userID)
+	s.observe("DeleteForUser", timeStart, err)
+	return err
+}
+
+// BatchDelete удаляет набор ссылок пользователя.
+func (s *InstrumentedStore) BatchDelete(ctx context.Context, ids []string, userID string) error {
+	timeStart := time.Now()
+	err := s.next.BatchDelete(ctx, ids, userID)
+	s.observe("BatchDelete", timeStart, err)
+	return err
+}
+
+// DeleteMany удаляет ссылки нескольких пользователей.
+func (s *InstrumentedStore) DeleteMany(ctx context.Context, items []Deletion) error {
+	timeStart := time.Now()
+	err := s.next.DeleteMany(ctx, items)
+	s.observe("DeleteMany", timeStart, err)
+	return err
+}
+
+// PurgeExpired удаляет истекшие ссылки.
+func (s *InstrumentedStore) PurgeExpired(ctx context.Context) ([]string, error) {
+	timeStart := time.Now()
+	ids, err := s.next.PurgeExpired(ctx)
+	s.observe("PurgeExpired", timeStart, err)
+	return ids, err
+}
+
+// CountURLs возвращает число неудаленных ссылок.
+func (s *InstrumentedStore) CountURLs(ctx context.Context) (int, error) {
+	timeStart := time.Now()
+	n, err := s.next.CountURLs(ctx)
+	s.observe("CountURLs", timeStart, err)
+	return n, err
+}
+
+// CountUsers возвращает число различных владельцев неудаленных ссылок.
+func (s *InstrumentedStore) CountUsers(ctx context.Context) (int, error) {
+	timeStart := time.Now()
+	n, err := s.next.CountUsers(ctx)
+	s.observe("CountUsers", timeStart, err)
+	return n, err
+}
+
+// Close закрывает обернутое хранилище.
+func (s *InstrumentedStore) Close() error {
+	return s.next.Close()
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor — курсор страницы поврежден или выдан не этим сервисом.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor — позиция в списке ссылок пользователя: ключ сортировки
+// последней отданной ссылки.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// cursorOf возвращает курсор, указывающий на ссылку u.
+func cursorOf(u URL) *Cursor {
+	return &Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+}
+
+// String кодирует курсор в непрозрачную строку для URL.
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID))
+}
+
+// ParseCursor разбирает курсор, полученный из Cursor.String.
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: t, ID: id}, nil
+}
+
+const (
+	// DefaultPageSize — размер страницы списка ссылок по умолчанию.
+	DefaultPageSize = 100
+	// MaxPageSize — наибольший допустимый размер страницы.
+	MaxPageSize = 1000
+)
+
+// ListOptions — параметры выборки ссылок пользователя.
+type ListOptions struct {
+	// Limit — размер страницы; 0 — без ограничения.
+	Limit int
+	// After — курсор последней ссылки предыдущей страницы; nil — с начала.
+	After *Cursor
+	// Desc — сортировка от новых ссылок к старым; по умолчанию от старых к новым.
+	Desc bool
+	// Contains — подстрока исходного URL без учета регистра.
+	Contains string
+	// Domain — домен исходного URL; поддомены тоже подходят.
+	Domain string
+}
+
+// Page — страница ссылок пользователя.
+type Page struct {
+	URLs []URL
+	// Next — курсор следующей страницы; nil, если страница последняя.
+	Next *Cursor
+}
+
+// MatchDomain сообщает, относится ли rawURL к домену domain или его поддомену.
+func MatchDomain(rawURL, domain string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	domain = strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// paginate фильтрует и нарезает ссылки, упорядоченные по (CreatedAt, ID),
+// так же, как это делает SQL-реализация.
+func paginate(urls []URL, opts ListOptions) Page {
+	contains := strings.ToLower(opts.Contains)
+	res := make([]URL, 0, len(urls))
+	for i := range urls {
+		u := urls[i]
+		if opts.Desc {
+			u = urls[len(urls)-1-i]
+		}
+		if contains != "" && !strings.Contains(strings.ToLower(u.OriginalURL), contains) {
+			continue
+		}
+		if opts.Domain != "" && !MatchDomain(u.OriginalURL, opts.Domain) {
+			continue
+		}
+		if opts.After != nil && !afterCursor(u, *opts.After, opts.Desc) {
+			continue
+		}
+		res = append(res, u)
+	}
+
+	var page Page
+	if opts.Limit > 0 && len(res) > opts.Limit {
+		res = res[:opts.Limit]
+		page.Next = cursorOf(res[len(res)-1])
+	}
+	page.URLs = res
+	return page
+}
+
+// afterCursor сообщает, идет ли ссылка u после курсора c в выбранном порядке.
+func afterCursor(u URL, c Cursor, desc bool) bool {
+	cmp := u.CreatedAt.Compare(c.CreatedAt)
+	if cmp == 0 {
+		cmp = strings.Compare(u.ID, c.ID)
+	}
+	if desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
@@ -1 +1,194 @@
 package store
+
+import (
+	"context"
+	"errors"
+	"github.com/zauremazhikovayandex/url/internal/db/storage"
+	"time"
+)
+
+// MemoryStore реализует Store поверх in-memory storage.Storage.
+type MemoryStore struct {
+	data *storage.Storage
+}
+
+// NewMemoryStore создает пустое хранилище в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: storage.New()}
+}
+
+// SetDedupScope задает область уникальности исходного URL.
+func (m *MemoryStore) SetDedupScope(scope storage.DedupScope) {
+	m.data.SetDedupScope(scope)
+}
+
+// fromRecord преобразует запись storage в URL.
+func fromRecord(rec storage.Record) URL {
+	return URL{
+		ID:          rec.ID,
+		OriginalURL: rec.OriginalURL,
+		UserID:      rec.UserID,
+		Deleted:     rec.Deleted,
+		CreatedAt:   rec.CreatedAt,
+		ExpiresAt:   rec.ExpiresAt,
+		MaxClicks:   rec.MaxClicks,
+		Clicks:      int64(rec.Clicks),
+	}
+}
+
+// mapStorageErr приводит ошибки storage к ошибкам пакета store.
+func mapStorageErr(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNoRecord):
+		return ErrNotFound
+	case errors.Is(err, storage.ErrRecordDeleted):
+		return ErrDeleted
+	case errors.Is(err, storage.ErrRecordExpired):
+		return ErrExpired
+	}
+	return err
+}
+
+// Get возвращает запись по id; для удаленной записи возвращает ErrDeleted,
+// для истекшей — ErrExpired.
+func (m *MemoryStore) Get(_ context.Context, id string) (URL, error) {
+	rec, ok := m.data.Get(id)
+	switch {
+	case !ok:
+		return URL{}, ErrNotFound
+	case rec.Deleted:
+		return fromRecord(rec), ErrDeleted
+	case rec.Expired(time.Now()):
+		return fromRecord(rec), ErrExpired
+	}
+	return fromRecord(rec), nil
+}
+
+// Resolve засчитывает переход по ссылке и возвращает исходный URL.
+func (m *MemoryStore) Resolve(_ context.Context, id string) (string, error) {
+	rec, err := m.data.Hit(id, time.Now())
+	return rec.OriginalURL, mapStorageErr(err)
+}
+
+// AddClicks засчитывает накопленные переходы.
+func (m *MemoryStore) AddClicks(_ context.Context, counts map[string]int64) error {
+	c := make(map[string]int, len(counts))
+	for id, n := range counts {
+		c[id] = int(n)
+	}
+	m.data.AddClicks(c)
+	return nil
+}
+
+// PurgeExpired удаляет истекшие ссылки.
+func (m *MemoryStore) PurgeExpired(_ context.Context) ([]string, error) {
+	return m.data.PurgeExpired(time.Now())
+}
+
+// CountURLs возвращает число неудаленных ссылок.
+func (m *MemoryStore) CountURLs(_ context.Context) (int, error) {
+	urls, _ := m.data.Count()
+	return urls, nil
+}
+
+// CountUsers возвращает число различных владельцев неудаленных ссылок.
+func (m *MemoryStore) CountUsers(_ context.Context) (int, error) {
+	_, users := m.data.Count()
+	return users, nil
+}
+
+// GetIDByOriginalURL возвращает id неудаленной ссылки на исходный URL в области уникальности userID.
+func (m *MemoryStore) GetIDByOriginalURL(_ context.Context, userID, originalURL string) (string, error) {
+	id, ok := m.data.GetKey(userID, originalURL)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return id, nil
+}
+
+// ListByUser возвращает страницу активных ссылок пользователя.
+func (m *MemoryStore) ListByUser(_ context.Context, userID string, opts ListOptions) (Page, error) {
+	recs := m.data.ListByOwner(userID, false)
+	res := make([]URL, 0, len(recs))
+	for _, rec := range recs {
+		res = append(res, fromRecord(rec))
+	}
+	return paginate(res, opts), nil
+}
+
+// EachByUser обходит ссылки пользователя, включая удаленные, по снимку хранилища.
+func (m *MemoryStore) EachByUser(ctx context.Context, userID string, fn func(URL) error) error {
+	for _, rec := range m.data.ListByOwner(userID, true) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(fromRecord(rec)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reassign передает ссылки другому пользователю; в файловом хранилище
+// изменение пишется в журнал.
+func (m *MemoryStore) Reassign(_ context.Context, from, to string) (int, error) {
+	return m.data.Reassign(from, to)
+}
+
+// Save атомарно сохраняет ссылку, проверяя уникальность id и исходного URL.
+func (m *MemoryStore) Save(_ context.Context, u URL) error {
+	rec := storage.Record{
+		ID:          u.ID,
+		OriginalURL: u.OriginalURL,
+		UserID:      u.UserID,
+		CreatedAt:   u.CreatedAt,
+		ExpiresAt:   u.ExpiresAt,
+		MaxClicks:   u.MaxClicks,
+	}
+	_, err := m.data.Add(rec)
+	switch {
+	case errors.Is(err, storage.ErrURLExists):
+		return ErrDuplicateURL
+	case errors.Is(err, storage.ErrIDExists):
+		return ErrIDConflict
+	}
+	return err
+}
+
+// SaveBatch сохраняет ссылки по одной; ошибки ссылок возвращаются по позициям.
+func (m *MemoryStore) SaveBatch(ctx context.Context, urls []URL) ([]error, error) {
+	errs := make([]error, len(urls))
+	for i, u := range urls {
+		errs[i] = m.Save(ctx, u)
+	}
+	return errs, nil
+}
+
+// DeleteForUser помечает ссылку пользователя как удаленную.
+func (m *MemoryStore) DeleteForUser(_ context.Context, id string, userID string) error {
+	return m.data.MarkDeleted([]string{id}, userID)
+}
+
+// BatchDelete помечает набор ссылок пользователя как удаленные.
+func (m *MemoryStore) BatchDelete(_ context.Context, ids []string, userID string) error {
+	return m.data.MarkDeleted(ids, userID)
+}
+
+// DeleteMany помечает удаленными ссылки нескольких пользователей.
+func (m *MemoryStore) DeleteMany(_ context.Context, items []Deletion) error {
+	byUser := make(map[string][]string)
+	for _, it := range items {
+		byUser[it.UserID] = append(byUser[it.UserID], it.ID)
+	}
+	for userID, ids := range byUser {
+		if err := m.data.MarkDeleted(ids, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close ничего не делает для хранилища в памяти.
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,331 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zauremazhikovayandex/url/internal/db/storage"
+	"github.com/zauremazhikovayandex/url/internal/metrics"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	require.NoError(t, s.Save(ctx, URL{ID: "a1", OriginalURL: "https://a.example", UserID: "u1"}))
+	require.NoError(t, s.Save(ctx, URL{ID: "b1", OriginalURL: "https://b.example", UserID: "u2"}))
+
+	err := s.Save(ctx, URL{ID: "a2", OriginalURL: "https://a.example", UserID: "u2"})
+	assert.ErrorIs(t, err, ErrDuplicateURL)
+
+	id, err := s.GetIDByOriginalURL(ctx, "u2", "https://a.example")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", id)
+
+	page, err := s.ListByUser(ctx, "u1", ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Equal(t, "https://a.example", page.URLs[0].OriginalURL)
+
+	// чужую ссылку удалить нельзя
+	require.NoError(t, s.BatchDelete(ctx, []string{"a1", "b1"}, "u2"))
+	_, err = s.Get(ctx, "a1")
+	assert.NoError(t, err)
+	_, err = s.Get(ctx, "b1")
+	assert.ErrorIs(t, err, ErrDeleted)
+
+	page, err = s.ListByUser(ctx, "u2", ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.URLs)
+}
+
+func TestMemoryStoreDedupScope(t *testing.T) {
+	ctx := context.Background()
+
+	global := NewMemoryStore()
+	require.NoError(t, global.Save(ctx, URL{ID: "g1", OriginalURL: "https://a.example", UserID: "u1"}))
+	assert.ErrorIs(t, global.Save(ctx, URL{ID: "g2", OriginalURL: "https://a.example", UserID: "u2"}), ErrDuplicateURL)
+	// удаленная ссылка не мешает сократить URL заново
+	require.NoError(t, global.DeleteForUser(ctx, "g1", "u1"))
+	require.NoError(t, global.Save(ctx, URL{ID: "g3", OriginalURL: "https://a.example", UserID: "u2"}))
+
+	perUser := NewMemoryStore()
+	perUser.SetDedupScope(storage.DedupUser)
+	require.NoError(t, perUser.Save(ctx, URL{ID: "p1", OriginalURL: "https://a.example", UserID: "u1"}))
+	require.NoError(t, perUser.Save(ctx, URL{ID: "p2", OriginalURL: "https://a.example", UserID: "u2"}))
+	assert.ErrorIs(t, perUser.Save(ctx, URL{ID: "p3", OriginalURL: "https://a.example", UserID: "u1"}), ErrDuplicateURL)
+	id, err := perUser.GetIDByOriginalURL(ctx, "u2", "https://a.example")
+	require.NoError(t, err)
+	assert.Equal(t, "p2", id)
+
+	none := NewMemoryStore()
+	none.SetDedupScope(storage.DedupNone)
+	require.NoError(t, none.Save(ctx, URL{ID: "n1", OriginalURL: "https://a.example", UserID: "u1"}))
+	require.NoError(t, none.Save(ctx, URL{ID: "n2", OriginalURL: "https://a.example", UserID: "u1"}))
+	_, err = none.GetIDByOriginalURL(ctx, "u1", "https://a.example")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFileStoreLegacyFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "url_history.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"a1": "https://a.example"}`), 0644))
+
+	fs, err := NewFileStore(path, FileOptions{SyncPolicy: storage.SyncAlways})
+	require.NoError(t, err)
+	u, err := fs.Get(context.Background(), "a1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example", u.OriginalURL)
+}
+
+func TestFileStoreReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "url_history.json")
+
+	fs, err := NewFileStore(path, FileOptions{SyncPolicy: storage.SyncAlways})
+	require.NoError(t, err)
+	require.NoError(t, fs.Save(ctx, URL{ID: "a1", OriginalURL: "https://a.example", UserID: "u1"}))
+	require.NoError(t, fs.Save(ctx, URL{ID: "b1", OriginalURL: "https://b.example", UserID: "u1"}))
+	require.NoError(t, fs.DeleteForUser(ctx, "b1", "u1"))
+	require.NoError(t, fs.Close())
+
+	fs, err = NewFileStore(path, FileOptions{SyncPolicy: storage.SyncAlways})
+	require.NoError(t, err)
+	u, err := fs.Get(ctx, "a1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example", u.OriginalURL)
+	assert.Equal(t, "u1", u.UserID)
+	assert.False(t, u.CreatedAt.IsZero())
+
+	_, err = fs.Get(ctx, "b1")
+	assert.ErrorIs(t, err, ErrDeleted)
+
+	err = fs.Save(ctx, URL{ID: "a2", OriginalURL: "https://a.example"})
+	assert.ErrorIs(t, err, ErrDuplicateURL)
+}
+
+func TestFileStoreJournalRecovery(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "url_history.json")
+	opts := FileOptions{SyncPolicy: storage.SyncAlways}
+
+	// Закрытия нет — имитируем аварийное завершение процесса.
+	fs, err := NewFileStore(path, opts)
+	require.NoError(t, err)
+	require.NoError(t, fs.Save(ctx, URL{ID: "a1", OriginalURL: "https://a.example", UserID: "u1"}))
+	require.NoError(t, fs.Save(ctx, URL{ID: "b1", OriginalURL: "https://b.example", UserID: "u1"}))
+	require.NoError(t, fs.BatchDelete(ctx, []string{"b1"}, "u1"))
+
+	// Оборванная последняя строка журнала.
+	jf, err := os.OpenFile(storage.JournalPath(path), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = jf.WriteString(`{"op":"create","record":{"id":"c1","orig`)
+	require.NoError(t, err)
+	require.NoError(t, jf.Close())
+
+	fs, err = NewFileStore(path, opts)
+	require.NoError(t, err)
+	_, err = fs.Get(ctx, "a1")
+	assert.NoError(t, err)
+	_, err = fs.Get(ctx, "b1")
+	assert.ErrorIs(t, err, ErrDeleted)
+	_, err = fs.Get(ctx, "c1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// После обрезки журнала новые записи читаются корректно.
+	require.NoError(t, fs.Save(ctx, URL{ID: "d1", OriginalURL: "https://d.example", UserID: "u1"}))
+	fs, err = NewFileStore(path, opts)
+	require.NoError(t, err)
+	_, err = fs.Get(ctx, "d1")
+	assert.NoError(t, err)
+
+	// Компакция переносит данные в снимок и очищает журнал.
+	require.NoError(t, fs.Close())
+	info, err := os.Stat(storage.JournalPath(path))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	fs, err = NewFileStore(path, opts)
+	require.NoError(t, err)
+	page, err := fs.ListByUser(ctx, "u1", ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page.URLs, 2)
+}
+
+func TestFileStoreRefusesCorruptJournal(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "url_history.json")
+	opts := FileOptions{SyncPolicy: storage.SyncAlways}
+
+	fs, err := NewFileStore(path, opts)
+	require.NoError(t, err)
+	require.NoError(t, fs.Save(ctx, URL{ID: "a1", OriginalURL: "https://a.example", UserID: "u1"}))
+	require.NoError(t, fs.Save(ctx, URL{ID: "b1", OriginalURL: "https://b.example", UserID: "u1"}))
+
+	// Испорченная строка в середине журнала.
+	jpath := storage.JournalPath(path)
+	data, err := os.ReadFile(jpath)
+	require.NoError(t, err)
+	corrupt := append([]byte("{garbage\n"), data...)
+	require.NoError(t, os.WriteFile(jpath, corrupt, 0644))
+
+	_, err = NewFileStore(path, opts)
+	assert.Error(t, err)
+	after, err := os.ReadFile(jpath)
+	require.NoError(t, err)
+	assert.Equal(t, corrupt, after, "журнал остается нетронутым")
+}
+
+func TestStoreReassign(t *testing.T) {
+	ctx := context.Background()
+
+	perUser := NewMemoryStore()
+	perUser.SetDedupScope(storage.DedupUser)
+	require.NoError(t, perUser.Save(ctx, URL{ID: "a1", OriginalURL: "https://a.example", UserID: "anon"}))
+	require.NoError(t, perUser.Save(ctx, URL{ID: "b1", OriginalURL: "https://b.example", UserID: "anon"}))
+	require.NoError(t, perUser.Save(ctx, URL{ID: "b2", OriginalURL: "https://b.example", UserID: "acct"}))
+
+	// ссылка на URL, который у acct уже есть, остается у anon
+	n, err := perUser.Reassign(ctx, "anon", "acct")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	u, err := perUser.Get(ctx, "a1")
+	require.NoError(t, err)
+	assert.Equal(t, "acct", u.UserID)
+	id, err := perUser.GetIDByOriginalURL(ctx, "acct", "https://a.example")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", id)
+	u, err = perUser.Get(ctx, "b1")
+	require.NoError(t, err)
+	assert.Equal(t, "anon", u.UserID)
+
+	// смена владельца переживает перезапуск через журнал
+	path := filepath.Join(t.TempDir(), "url_history.json")
+	opts := FileOptions{SyncPolicy: storage.SyncAlways}
+	fs, err := NewFileStore(path, opts)
+	require.NoError(t, err)
+	require.NoError(t, fs.Save(ctx, URL{ID: "c1", OriginalURL: "https://c.example", UserID: "anon"}))
+	n, err = fs.Reassign(ctx, "anon", "acct")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	fs, err = NewFileStore(path, opts)
+	require.NoError(t, err)
+	page, err := fs.ListByUser(ctx, "acct", ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Equal(t, "c1", page.URLs[0].ID)
+	page, err = fs.ListByUser(ctx, "anon", ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.URLs)
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, s.Save(ctx, URL{ID: "limited", OriginalURL: "https://a.example", MaxClicks: 2}))
+	require.NoError(t, s.Save(ctx, URL{ID: "stale", OriginalURL: "https://b.example", ExpiresAt: &past}))
+	require.NoError(t, s.Save(ctx, URL{ID: "plain", OriginalURL: "https://c.example"}))
+
+	for i := 0; i < 2; i++ {
+		_, err := s.Resolve(ctx, "limited")
+		require.NoError(t, err)
+	}
+	_, err := s.Resolve(ctx, "limited")
+	assert.ErrorIs(t, err, ErrExpired)
+	_, err = s.Resolve(ctx, "stale")
+	assert.ErrorIs(t, err, ErrExpired)
+
+	purged, err := s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"limited", "stale"}, purged)
+
+	_, err = s.Get(ctx, "limited")
+	assert.ErrorIs(t, err, ErrNotFound)
+	u, err := s.Get(ctx, "plain")
+	require.NoError(t, err)
+	assert.Zero(t, u.Clicks)
+}
+
+func TestInstrumentedStoreCountsOnlyFailures(t *testing.T) {
+	st := Instrumented(NewMemoryStore(), "test")
+	ctx := context.Background()
+
+	require.NoError(t, st.Save(ctx, URL{ID: "a", OriginalURL: "https://a.example"}))
+	require.ErrorIs(t, st.Save(ctx, URL{ID: "b", OriginalURL: "https://a.example"}), ErrDuplicateURL)
+	_, err := st.Get(ctx, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	assert.Zero(t, testutil.ToFloat64(metrics.StorageErrors.WithLabelValues("test", "Save")))
+	assert.Zero(t, testutil.ToFloat64(metrics.StorageErrors.WithLabelValues("test", "Get")))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.StorageDuration, "shortener_storage_operation_duration_seconds"))
+}
+
+func TestListByUserPagination(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	seed := []URL{
+		{ID: "a", OriginalURL: "https://shop.example.com/sale", CreatedAt: base},
+		{ID: "b", OriginalURL: "https://example.com/Blog", CreatedAt: base.Add(time.Minute)},
+		{ID: "c", OriginalURL: "https://notexample.com/blog", CreatedAt: base.Add(time.Minute)},
+		{ID: "d", OriginalURL: "https://other.org/blog", CreatedAt: base.Add(2 * time.Minute)},
+		{ID: "e", OriginalURL: "https://example.com/gone", CreatedAt: base.Add(3 * time.Minute)},
+	}
+
+	for name, newStore := range map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return NewMemoryStore() },
+		"file": func(t *testing.T) Store {
+			fs, err := NewFileStore(filepath.Join(t.TempDir(), "urls.json"), FileOptions{SyncPolicy: storage.SyncAlways})
+			require.NoError(t, err)
+			return fs
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			for _, u := range seed {
+				u.UserID = "u1"
+				require.NoError(t, s.Save(ctx, u))
+			}
+			require.NoError(t, s.Save(ctx, URL{ID: "x", OriginalURL: "https://example.com/x", UserID: "u2"}))
+			require.NoError(t, s.DeleteForUser(ctx, "e", "u1"))
+
+			// collect проходит все страницы и возвращает id в порядке выдачи
+			collect := func(opts ListOptions) []string {
+				var ids []string
+				for {
+					page, err := s.ListByUser(ctx, "u1", opts)
+					require.NoError(t, err)
+					require.LessOrEqual(t, len(page.URLs), opts.Limit)
+					for _, u := range page.URLs {
+						ids = append(ids, u.ID)
+					}
+					if page.Next == nil {
+						return ids
+					}
+					// курсор переживает кодирование в строку
+					opts.After, err = ParseCursor(page.Next.String())
+					require.NoError(t, err)
+				}
+			}
+
+			assert.Equal(t, []string{"a", "b", "c", "d"}, collect(ListOptions{Limit: 2}))
+			assert.Equal(t, []string{"d", "c", "b", "a"}, collect(ListOptions{Limit: 3, Desc: true}))
+			assert.Equal(t, []string{"b", "c", "d"}, collect(ListOptions{Limit: 1, Contains: "BLOG"}))
+			assert.Equal(t, []string{"a", "b"}, collect(ListOptions{Limit: 10, Domain: "example.com"}))
+		})
+	}
+}
+
+func TestParseCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "!!!", "bm8tc2VwYXJhdG9y", "MjAyNS0wMS0wMXxh"} {
+		_, err := ParseCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
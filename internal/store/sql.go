@@ -1 +1,249 @@
 package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/zauremazhikovayandex/url/internal/db/postgres"
+	"github.com/zauremazhikovayandex/url/internal/db/storage"
+	"strings"
+)
+
+// SQLStore реализует Store поверх PostgreSQL.
+type SQLStore struct {
+	instance *postgres.SQLConnection
+	dedup    storage.DedupScope
+}
+
+// NewSQLStore подготавливает БД и индексы уникальности URL для области dedup
+// и возвращает хранилище. Недоступность БД на старте не является фатальной:
+// подключение повторяется лениво. Если индексы не удалось привести к области
+// dedup, возвращается ошибка: иначе уникальность в БД не совпала бы с
+// настройкой сервиса.
+func NewSQLStore(dedup storage.DedupScope) (*SQLStore, error) {
+	instance, err := postgres.SQLInstance()
+	if err != nil {
+		fmt.Println("DB prepare issues", err)
+	} else {
+		postgres.PrepareDB(instance)
+		if err := postgres.ApplyDedupScope(context.Background(), instance.PgSQL, string(dedup)); err != nil {
+			return nil, err
+		}
+	}
+	return &SQLStore{instance: instance, dedup: dedup}, nil
+}
+
+// fromPostgres преобразует запись postgres.URL в URL.
+func fromPostgres(u postgres.URL) URL {
+	return URL{
+		ID:          u.ID,
+		OriginalURL: u.OriginalURL,
+		UserID:      u.UserID,
+		Deleted:     u.Deleted,
+		CreatedAt:   u.CreatedAt,
+		ExpiresAt:   u.ExpiresAt,
+		MaxClicks:   u.MaxClicks,
+		Clicks:      u.Clicks,
+	}
+}
+
+// mapPostgresErr приводит ошибки пакета postgres к ошибкам пакета store.
+func mapPostgresErr(err error) error {
+	switch {
+	case errors.Is(err, postgres.ErrURLDeleted):
+		return ErrDeleted
+	case errors.Is(err, postgres.ErrURLExpired):
+		return ErrExpired
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrNotFound
+	}
+	return err
+}
+
+// Get возвращает запись по id.
+func (s *SQLStore) Get(ctx context.Context, id string) (URL, error) {
+	u, err := postgres.SelectURL(ctx, id)
+	return fromPostgres(u), mapPostgresErr(err)
+}
+
+// Resolve засчитывает переход по ссылке и возвращает исходный URL.
+func (s *SQLStore) Resolve(ctx context.Context, id string) (string, error) {
+	originalURL, err := postgres.ResolveURL(ctx, id)
+	return originalURL, mapPostgresErr(err)
+}
+
+// AddClicks засчитывает накопленные переходы одним UPDATE.
+func (s *SQLStore) AddClicks(ctx context.Context, counts map[string]int64) error {
+	return postgres.AddClicks(ctx, counts)
+}
+
+// PurgeExpired удаляет истекшие ссылки.
+func (s *SQLStore) PurgeExpired(ctx context.Context) ([]string, error) {
+	return postgres.PurgeExpiredURLs(ctx)
+}
+
+// CountURLs возвращает число неудаленных ссылок.
+func (s *SQLStore) CountURLs(ctx context.Context) (int, error) {
+	return postgres.CountURLs(ctx)
+}
+
+// CountUsers возвращает число различных владельцев неудаленных ссылок.
+func (s *SQLStore) CountUsers(ctx context.Context) (int, error) {
+	return postgres.CountUsers(ctx)
+}
+
+// GetIDByOriginalURL возвращает id неудаленной ссылки на исходный URL в области уникальности userID.
+func (s *SQLStore) GetIDByOriginalURL(ctx context.Context, userID, originalURL string) (string, error) {
+	owner := userID
+	if s.dedup == storage.DedupGlobal {
+		owner = ""
+	}
+	id, err := postgres.SelectIDByOriginalURL(ctx, originalURL, owner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return id, err
+}
+
+// ListByUser возвращает страницу активных ссылок пользователя; фильтры и
+// пагинация выполняются в SQL.
+func (s *SQLStore) ListByUser(ctx context.Context, userID string, opts ListOptions) (Page, error) {
+	f := postgres.ListFilter{Desc: opts.Desc, Contains: opts.Contains, Domain: strings.ToLower(opts.Domain)}
+	if opts.Limit > 0 {
+		// лишняя строка показывает, есть ли следующая страница
+		f.Limit = opts.Limit + 1
+	}
+	if opts.After != nil {
+		f.AfterCreatedAt, f.AfterID = &opts.After.CreatedAt, opts.After.ID
+	}
+	rows, err := postgres.SelectURLsByUser(ctx, userID, f)
+	if err != nil {
+		return Page{}, err
+	}
+
+	var page Page
+	if opts.Limit > 0 && len(rows) > opts.Limit {
+		rows = rows[:opts.Limit]
+		page.Next = cursorOf(fromPostgres(rows[len(rows)-1]))
+	}
+	page.URLs = make([]URL, 0, len(rows))
+	for _, r := range rows {
+		page.URLs = append(page.URLs, fromPostgres(r))
+	}
+	return page, nil
+}
+
+// EachByUser обходит ссылки пользователя, читая строки из курсора запроса.
+func (s *SQLStore) EachByUser(ctx context.Context, userID string, fn func(URL) error) error {
+	return postgres.EachURLByUser(ctx, userID, func(u postgres.URL) error {
+		return fn(fromPostgres(u))
+	})
+}
+
+// Reassign передает ссылки другому пользователю одним UPDATE.
+func (s *SQLStore) Reassign(ctx context.Context, from, to string) (int, error) {
+	n, err := postgres.ReassignURLs(ctx, from, to, s.dedup == storage.DedupUser)
+	return int(n), err
+}
+
+// Save сохраняет ссылку. Уникальность обеспечивают ограничения БД:
+// конфликт по первичному ключу приводится к ErrIDConflict,
+// по исходному URL — к ErrDuplicateURL.
+func (s *SQLStore) Save(ctx context.Context, u URL) error {
+	err := postgres.InsertURL(ctx, postgres.URL{
+		ID:          u.ID,
+		OriginalURL: u.OriginalURL,
+		UserID:      u.UserID,
+		ExpiresAt:   u.ExpiresAt,
+		MaxClicks:   u.MaxClicks,
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		if pgErr.ConstraintName == postgres.PrimaryKeyConstraint {
+			return ErrIDConflict
+		}
+		return ErrDuplicateURL
+	}
+	return err
+}
+
+// copyThreshold — размер пачки, начиная с которого SaveBatch загружает ее через COPY.
+const copyThreshold = 256
+
+// SaveBatch вставляет пачку одним запросом в транзакции, большие пачки — через COPY.
+// Пропущенные из-за уникальности строки получают ErrDuplicateURL или ErrIDConflict.
+func (s *SQLStore) SaveBatch(ctx context.Context, urls []URL) ([]error, error) {
+	rows := make([]postgres.URL, len(urls))
+	for i, u := range urls {
+		rows[i] = postgres.URL{
+			ID:          u.ID,
+			OriginalURL: u.OriginalURL,
+			UserID:      u.UserID,
+			ExpiresAt:   u.ExpiresAt,
+			MaxClicks:   u.MaxClicks,
+		}
+	}
+	save := postgres.InsertURLs
+	if len(rows) >= copyThreshold {
+		save = postgres.CopyURLs
+	}
+	inserted, existing, err := save(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(urls))
+	claimed := make(map[string]bool, len(inserted))
+	for i, u := range urls {
+		if orig, ok := inserted[u.ID]; ok && orig == u.OriginalURL && !claimed[u.ID] {
+			claimed[u.ID] = true
+			continue
+		}
+		errs[i] = s.conflictReason(u, existing)
+	}
+	return errs, nil
+}
+
+// conflictReason определяет, почему ссылка не вставлена: исходный URL уже
+// сокращен в области уникальности — ErrDuplicateURL, иначе занят id.
+func (s *SQLStore) conflictReason(u URL, existing []postgres.URL) error {
+	for _, e := range existing {
+		if e.Deleted || e.OriginalURL != u.OriginalURL {
+			continue
+		}
+		if s.dedup == storage.DedupGlobal || (s.dedup == storage.DedupUser && e.UserID == u.UserID) {
+			return ErrDuplicateURL
+		}
+	}
+	return ErrIDConflict
+}
+
+// DeleteForUser помечает ссылку пользователя как удаленную.
+func (s *SQLStore) DeleteForUser(ctx context.Context, id string, userID string) error {
+	return postgres.DeleteURL(ctx, id, userID)
+}
+
+// BatchDelete помечает набор ссылок пользователя как удаленные.
+func (s *SQLStore) BatchDelete(ctx context.Context, ids []string, userID string) error {
+	return postgres.BatchDeleteURLs(ctx, ids, userID)
+}
+
+// DeleteMany помечает удаленными ссылки нескольких пользователей одним запросом.
+func (s *SQLStore) DeleteMany(ctx context.Context, items []Deletion) error {
+	pairs := make([]postgres.UserURL, 0, len(items))
+	for _, it := range items {
+		pairs = append(pairs, postgres.UserURL{UserID: it.UserID, ID: it.ID})
+	}
+	return postgres.DeleteUserURLs(ctx, pairs)
+}
+
+// Close закрывает пул соединений с БД.
+func (s *SQLStore) Close() error {
+	if s.instance != nil {
+		s.instance.CloseSQLInstance()
+	}
+	return nil
+}
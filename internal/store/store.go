@@ -1 +1,110 @@
+// Package store определяет единый интерфейс хранилища коротких ссылок
+// и его реализации: в памяти, в файле и в PostgreSQL.
 package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/zauremazhikovayandex/url/internal/config"
+	"github.com/zauremazhikovayandex/url/internal/db/storage"
+	"time"
+)
+
+// URL представляет запись о короткой ссылке.
+type URL struct {
+	ID          string
+	OriginalURL string
+	UserID      string
+	Deleted     bool
+	CreatedAt   time.Time
+	// ExpiresAt — момент, после которого ссылка перестает работать; nil — бессрочно.
+	ExpiresAt *time.Time
+	// MaxClicks — лимит переходов; 0 — без лимита.
+	MaxClicks int
+	// Clicks — число засчитанных переходов.
+	Clicks int64
+}
+
+// Deletion — запрос пользователя на удаление одной ссылки.
+type Deletion struct {
+	UserID string `json:"user_id"`
+	ID     string `json:"id"`
+}
+
+// Store описывает операции, которые должен поддерживать любой бэкенд хранения.
+type Store interface {
+	// Get возвращает запись по короткому идентификатору.
+	// Возвращает ErrNotFound, если записи нет, ErrDeleted, если она удалена,
+	// и ErrExpired, если истек срок жизни или исчерпан лимит переходов.
+	Get(ctx context.Context, id string) (URL, error)
+	// Resolve атомарно засчитывает переход и возвращает исходный URL;
+	// ошибки те же, что у Get.
+	Resolve(ctx context.Context, id string) (string, error)
+	// AddClicks засчитывает переходы, накопленные вне хранилища (например, на
+	// кэшированном пути), без проверки срока и лимита; удаленные ссылки пропускаются.
+	AddClicks(ctx context.Context, counts map[string]int64) error
+	// GetIDByOriginalURL возвращает идентификатор неудаленной ссылки на исходный URL
+	// в той же области уникальности, что и ссылки пользователя userID.
+	GetIDByOriginalURL(ctx context.Context, userID, originalURL string) (string, error)
+	// ListByUser возвращает страницу активных ссылок пользователя, упорядоченных
+	// по времени создания, с учетом фильтров opts.
+	ListByUser(ctx context.Context, userID string, opts ListOptions) (Page, error)
+	// EachByUser вызывает fn для каждой ссылки пользователя, включая удаленные,
+	// в порядке создания, не загружая их все в память; ошибка fn прерывает обход.
+	EachByUser(ctx context.Context, userID string, fn func(URL) error) error
+	// Save сохраняет новую ссылку; при повторе исходного URL в области уникальности
+	// (см. config.DedupScope) возвращает ErrDuplicateURL.
+	Save(ctx context.Context, u URL) error
+	// SaveBatch сохраняет пачку ссылок за одну операцию. Ошибки отдельных ссылок
+	// (ErrDuplicateURL, ErrIDConflict) возвращаются в срезе по их позициям,
+	// а ошибка операции целиком — вторым значением.
+	SaveBatch(ctx context.Context, urls []URL) ([]error, error)
+	// Reassign передает ссылки пользователя from пользователю to и возвращает их
+	// число. Неудаленные ссылки на URL, который у to уже есть в области
+	// уникальности, остаются у from.
+	Reassign(ctx context.Context, from, to string) (int, error)
+	// DeleteForUser удаляет ссылку, если она принадлежит пользователю.
+	DeleteForUser(ctx context.Context, id string, userID string) error
+	// BatchDelete удаляет набор ссылок пользователя.
+	BatchDelete(ctx context.Context, ids []string, userID string) error
+	// DeleteMany удаляет ссылки разных пользователей за одну операцию;
+	// чужие ссылки пропускаются.
+	DeleteMany(ctx context.Context, items []Deletion) error
+	// PurgeExpired физически удаляет истекшие ссылки и возвращает их id.
+	PurgeExpired(ctx context.Context) ([]string, error)
+	// CountURLs возвращает число неудаленных ссылок.
+	CountURLs(ctx context.Context) (int, error)
+	// CountUsers возвращает число различных владельцев неудаленных ссылок.
+	CountUsers(ctx context.Context) (int, error)
+	// Close освобождает ресурсы хранилища.
+	Close() error
+}
+
+// New создает хранилище в соответствии с config.AppConfig.StorageType.
+func New() (Store, error) {
+	dedup := storage.DedupScope(config.AppConfig.DedupScope)
+	switch config.AppConfig.StorageType {
+	case "DB":
+		s, err := NewSQLStore(dedup)
+		if err != nil {
+			return nil, fmt.Errorf("database storage: %w", err)
+		}
+		return s, nil
+	case "File":
+		fs, err := NewFileStore(config.AppConfig.FileStorage, FileOptions{
+			SyncPolicy:      storage.SyncPolicy(config.AppConfig.FileSyncPolicy),
+			SyncInterval:    time.Second,
+			CompactInterval: config.AppConfig.FileCompactInterval,
+			DedupScope:      dedup,
+		})
+		if err != nil {
+			// без целых данных и журнала не стартуем: иначе компакция потеряет изменения
+			return nil, fmt.Errorf("file storage %s: %w", config.AppConfig.FileStorage, err)
+		}
+		return fs, nil
+	default:
+		ms := NewMemoryStore()
+		ms.SetDedupScope(dedup)
+		return ms, nil
+	}
+}
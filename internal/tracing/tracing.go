@@ -0,0 +1,99 @@
+// Package tracing настраивает OpenTelemetry: экспорт спанов и W3C-пропагацию контекста.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"os"
+)
+
+// ServiceName — имя сервиса в ресурсе трассировки.
+const ServiceName = "url-shortener"
+
+// Экспортеры спанов.
+const (
+	// ExporterNone — трассировка выключена, спаны не записываются.
+	ExporterNone = "none"
+	// ExporterStdout — спаны пишутся в stdout в JSON.
+	ExporterStdout = "stdout"
+	// ExporterFile — спаны дописываются в файл в JSON.
+	ExporterFile = "file"
+	// ExporterOTLP — спаны отправляются по OTLP/HTTP; адрес коллектора
+	// задается стандартными переменными OTEL_EXPORTER_OTLP_*.
+	ExporterOTLP = "otlp"
+)
+
+// Tracer возвращает трейсер сервиса из глобального провайдера.
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// Init настраивает глобальный провайдер спанов с указанным экспортером
+// и W3C traceparent/baggage-пропагацию. Возвращает функцию, которая досылает
+// накопленные спаны и освобождает ресурсы при остановке.
+func Init(ctx context.Context, exporter string, file string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exp    sdktrace.SpanExporter
+		closer io.Closer
+		err    error
+	)
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exp, err = stdouttrace.New()
+	case ExporterFile:
+		var f *os.File
+		f, err = os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		closer = f
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	case ExporterOTLP:
+		exp, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}